@@ -0,0 +1,114 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgCheckViolation       = "23514"
+	pgNotNullViolation     = "23502"
+	pgStringTooLong        = "22001"
+	pgInvalidTextValue     = "22P02"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgAdminShutdown        = "57P01"
+	pgCrashShutdown        = "57P02"
+	pgCannotConnectNow     = "57P03"
+	pgQueryCanceled        = "57014"
+)
+
+// keyDetail extracts the columns from a Postgres detail such as
+// "Key (username)=(bob) already exists."
+var keyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// FromDB converts an error returned by a service into a domain error. Errors that are
+// already domain errors are returned as is; gorm.ErrRecordNotFound becomes a NotFound
+// error for entity (e.g. "book"); Postgres errors are mapped by their SQLSTATE code;
+// anything else is an internal error.
+func FromDB(err error, entity string) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		e := NotFoundEntity(entity)
+		e.Err = err
+		return e
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return Unavailable("timeout", "The request timed out", err)
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return Unavailable("database_unavailable", "The database is unavailable", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return Internal(err)
+	}
+
+	var e *Error
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		e = Conflict("already_exists", humanize(entity)+" already exists")
+		for _, column := range keyColumns(pgErr.Detail) {
+			e.Details = append(e.Details, FieldError{Field: column, Code: "unique", Message: "is already taken"})
+		}
+	case pgForeignKeyViolation:
+		e = Conflict("referenced_row", "The "+strings.ReplaceAll(entity, "_", " ")+" is referenced by, or references, a row that prevents this change")
+		for _, column := range keyColumns(pgErr.Detail) {
+			e.Details = append(e.Details, FieldError{Field: column, Code: "foreign_key", Message: pgErr.Detail})
+		}
+	case pgCheckViolation:
+		e = Validation(FieldError{Field: pgErr.ColumnName, Code: "check", Message: "violates constraint " + pgErr.ConstraintName})
+	case pgNotNullViolation:
+		e = Validation(FieldError{Field: pgErr.ColumnName, Code: "required", Message: "is required"})
+	case pgStringTooLong:
+		e = Validation(FieldError{Field: pgErr.ColumnName, Code: "max", Message: "is too long"})
+	case pgInvalidTextValue:
+		e = Validation(FieldError{Field: pgErr.ColumnName, Code: "invalid_type", Message: "has an invalid value"})
+	case pgSerializationFailure, pgDeadlockDetected:
+		e = Unavailable("retry", "The request conflicted with a concurrent change; retry it", nil)
+	case pgAdminShutdown, pgCrashShutdown, pgCannotConnectNow, pgQueryCanceled:
+		e = Unavailable("database_unavailable", "The database is unavailable", nil)
+	default:
+		if strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "53") {
+			// Connection exceptions and insufficient resources
+			e = Unavailable("database_unavailable", "The database is unavailable", nil)
+		} else {
+			return Internal(err)
+		}
+	}
+	e.Err = err
+	return e
+}
+
+// keyColumns returns the columns named in a Postgres key detail message.
+func keyColumns(detail string) []string {
+	match := keyDetail.FindStringSubmatch(detail)
+	if match == nil {
+		return nil
+	}
+	columns := strings.Split(match[1], ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns
+}
@@ -0,0 +1,135 @@
+// Package apperrors defines the typed errors returned by the services and
+// rendered by the handlers, and maps database errors onto them.
+package apperrors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Kind classifies an error and decides its HTTP status.
+type Kind int
+
+const (
+	KindInternal             Kind = iota // Unexpected failure, 500
+	KindBadRequest                       // Malformed request, 400
+	KindValidation                       // Well-formed request with invalid content, 422
+	KindNotFound                         // Missing resource, 404
+	KindConflict                         // Conflicts with the current state, 409
+	KindForbidden                        // Not allowed for this caller, 403
+	KindUnavailable                      // Dependency down or overloaded, 503
+	KindUnsupportedMediaType             // Request body in a format the route does not accept, 415
+	KindTooManyRequests                  // Over the rate limit of the caller, 429
+	KindPayloadTooLarge                  // Request body over the size accepted, 413
+)
+
+// Status returns the HTTP status code of the kind.
+func (k Kind) Status() int {
+	switch k {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FieldError describes why one field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`   // JSON path of the field, e.g. "rating" or "tags[0].name"
+	Code    string `json:"code"`    // Machine-readable reason, e.g. "required" or "not_found"
+	Message string `json:"message"` // Human-readable explanation
+}
+
+// Error is a domain error with a stable, machine-readable code.
+type Error struct {
+	Kind    Kind
+	Code    string       // Stable code, e.g. "book_not_found"
+	Message string       // Human-readable explanation, safe to show to clients
+	Details []FieldError // Field violations, for validation errors
+	Err     error        // Underlying cause, never shown to clients
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given kind.
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NotFound returns a KindNotFound error.
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+// Conflict returns a KindConflict error.
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+// BadRequest returns a KindBadRequest error.
+func BadRequest(code, message string) *Error {
+	return New(KindBadRequest, code, message)
+}
+
+// Validation returns a KindValidation error listing the rejected fields.
+func Validation(details ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "Validation failed", Details: details}
+}
+
+// Forbidden returns a KindForbidden error.
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+// Unavailable returns a KindUnavailable error caused by err.
+func Unavailable(code, message string, err error) *Error {
+	return &Error{Kind: KindUnavailable, Code: code, Message: message, Err: err}
+}
+
+// Internal returns a KindInternal error caused by err.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "An unexpected error occurred", Err: err}
+}
+
+// NotFoundEntity returns the KindNotFound error for a missing entity, e.g.
+// NotFoundEntity("book") has code "book_not_found" and message "Book not found".
+func NotFoundEntity(entity string) *Error {
+	return NotFound(entity+"_not_found", humanize(entity)+" not found")
+}
+
+// humanize turns an entity name such as "series_entry" into "Series entry".
+func humanize(entity string) string {
+	words := strings.ReplaceAll(entity, "_", " ")
+	if words == "" {
+		return "Resource"
+	}
+	return strings.ToUpper(words[:1]) + words[1:]
+}
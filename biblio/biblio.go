@@ -0,0 +1,125 @@
+// Package biblio maps books onto bibliographic and citation formats: MARCXML, Dublin
+// Core, BibTeX, RIS and CSL-JSON.
+package biblio
+
+import (
+	"io"
+	"strings"
+
+	"gin-books-api/models"
+
+	"golang.org/x/text/language"
+)
+
+// Record holds the fields of a book that the formats describe.
+type Record struct {
+	ID             uint
+	Title          string
+	Description    string
+	Year           int
+	ISBN           string
+	Language       string // BCP 47 tag, e.g. "en"
+	Format         string // hardcover, paperback, ebook or audiobook
+	Author         Name
+	Contributors   []Name // Further authors, read from MARC 700 fields
+	Publisher      string
+	PublisherPlace string
+	Subjects       []string // Category and subject headings
+	Keywords       []string // Tags
+}
+
+// Name is a personal name, split into family and given names where possible.
+type Name struct {
+	Family string
+	Given  string
+}
+
+// Empty reports whether there is no name.
+func (n Name) Empty() bool {
+	return n.Family == "" && n.Given == ""
+}
+
+// Inverted returns the name as "Family, Given", the form catalogues sort by.
+func (n Name) Inverted() string {
+	if n.Given == "" {
+		return n.Family
+	}
+	return n.Family + ", " + n.Given
+}
+
+// splitName splits "Given Family" at the last space.
+func splitName(name string) Name {
+	name = strings.Join(strings.Fields(name), " ")
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		return Name{Family: name[i+1:], Given: name[:i]}
+	}
+	return Name{Family: name}
+}
+
+// NewRecord maps a book, with its author, publisher, category, tags and subjects
+// loaded, onto a record.
+func NewRecord(book models.Book) Record {
+	record := Record{
+		ID:             book.ID,
+		Title:          book.Title,
+		Description:    book.Description,
+		Year:           book.PublishedYear,
+		ISBN:           book.ISBN,
+		Language:       book.Language,
+		Format:         book.Format,
+		Author:         splitName(book.Author.Name),
+		Publisher:      book.Publisher.Name,
+		PublisherPlace: book.Publisher.Address,
+	}
+	if book.Category.Name != "" {
+		record.Subjects = append(record.Subjects, book.Category.Name)
+	}
+	for _, subject := range book.Subjects {
+		record.Subjects = append(record.Subjects, subject.Name)
+	}
+	for _, tag := range book.Tags {
+		record.Keywords = append(record.Keywords, tag.Name)
+	}
+	return record
+}
+
+// Format is an output format for records.
+type Format struct {
+	ContentType string
+	Extension   string
+	Write       func(w io.Writer, records []Record) error
+}
+
+// Formats are the formats accepted by ?format= on the book export routes.
+var Formats = map[string]Format{
+	"marcxml":  {ContentType: "application/marcxml+xml", Extension: "xml", Write: WriteMARCXML},
+	"dc":       {ContentType: "application/xml", Extension: "xml", Write: WriteDublinCore},
+	"bibtex":   {ContentType: "application/x-bibtex", Extension: "bib", Write: WriteBibTeX},
+	"ris":      {ContentType: "application/x-research-info-systems", Extension: "ris", Write: WriteRIS},
+	"csl-json": {ContentType: "application/vnd.citationstyles.csl+json", Extension: "json", Write: WriteCSLJSON},
+}
+
+// bibliographicCodes are the ISO 639-2/B codes that differ from the terminology codes.
+var bibliographicCodes = map[string]string{
+	"bod": "tib", "ces": "cze", "cym": "wel", "deu": "ger", "ell": "gre", "eus": "baq",
+	"fas": "per", "fra": "fre", "hye": "arm", "isl": "ice", "kat": "geo", "mkd": "mac",
+	"mri": "mao", "msa": "may", "mya": "bur", "nld": "dut", "ron": "rum", "slk": "slo",
+	"sqi": "alb", "zho": "chi",
+}
+
+// marcLanguage returns the three-letter MARC code of a BCP 47 tag, or "" if it has none.
+func marcLanguage(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return ""
+	}
+	base, _ := parsed.Base()
+	code := base.ISO3()
+	if b, ok := bibliographicCodes[code]; ok {
+		return b
+	}
+	return code
+}
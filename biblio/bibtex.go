@@ -0,0 +1,89 @@
+package biblio
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// bibtexEscaper escapes the characters that are special in BibTeX field values.
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// bibtexKey returns a citation key such as "bodner2021learning", falling back to the
+// book ID where a part is missing, so that keys are stable and unique per book.
+func bibtexKey(record Record) string {
+	var key strings.Builder
+	for _, part := range []string{record.Author.Family, yearOrEmpty(record.Year), firstWord(record.Title)} {
+		for _, r := range strings.ToLower(part) {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				key.WriteRune(r)
+			}
+		}
+	}
+	return key.String() + "-" + strconv.FormatUint(uint64(record.ID), 10)
+}
+
+// WriteBibTeX writes one @book entry per record.
+func WriteBibTeX(w io.Writer, records []Record) error {
+	out := bufio.NewWriter(w)
+	for i, record := range records {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("@book{" + bibtexKey(record) + ",\n")
+
+		field := func(name, value string) {
+			if value != "" {
+				out.WriteString("  " + name + " = {" + bibtexEscaper.Replace(value) + "},\n")
+			}
+		}
+		field("title", record.Title)
+		field("author", record.Author.Inverted())
+		field("publisher", record.Publisher)
+		field("address", record.PublisherPlace)
+		field("year", yearOrEmpty(record.Year))
+		field("isbn", record.ISBN)
+		field("language", record.Language)
+		field("abstract", record.Description)
+		field("keywords", strings.Join(append(append([]string{}, record.Subjects...), record.Keywords...), ", "))
+		out.WriteString("}\n")
+	}
+	return out.Flush()
+}
+
+func yearOrEmpty(year int) string {
+	if year <= 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+func firstWord(s string) string {
+	for _, word := range strings.Fields(s) {
+		if !isStopWord(word) {
+			return word
+		}
+	}
+	return ""
+}
+
+func isStopWord(word string) bool {
+	switch strings.ToLower(word) {
+	case "a", "an", "the":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package biblio
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// cslItem is an item of CSL-JSON, the input format of Citation Style Language processors.
+type cslItem struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []cslName `json:"author,omitempty"`
+	Issued         *cslDate  `json:"issued,omitempty"`
+	Publisher      string    `json:"publisher,omitempty"`
+	PublisherPlace string    `json:"publisher-place,omitempty"`
+	ISBN           string    `json:"ISBN,omitempty"`
+	Language       string    `json:"language,omitempty"`
+	Abstract       string    `json:"abstract,omitempty"`
+	Medium         string    `json:"medium,omitempty"`
+	Keyword        string    `json:"keyword,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// newCSLItem maps a record onto a CSL-JSON item.
+func newCSLItem(record Record) cslItem {
+	item := cslItem{
+		ID:             "book-" + strconv.FormatUint(uint64(record.ID), 10),
+		Type:           "book",
+		Title:          record.Title,
+		Publisher:      record.Publisher,
+		PublisherPlace: record.PublisherPlace,
+		ISBN:           record.ISBN,
+		Language:       record.Language,
+		Abstract:       record.Description,
+		Medium:         record.Format,
+		Keyword:        strings.Join(append(append([]string{}, record.Subjects...), record.Keywords...), ", "),
+	}
+	switch {
+	case record.Author.Empty():
+	case record.Author.Given == "":
+		item.Author = []cslName{{Literal: record.Author.Family}}
+	default:
+		item.Author = []cslName{{Family: record.Author.Family, Given: record.Author.Given}}
+	}
+	if record.Year > 0 {
+		item.Issued = &cslDate{DateParts: [][]int{{record.Year}}}
+	}
+	return item
+}
+
+// WriteCSLJSON writes records as a CSL-JSON array, even when there is only one.
+func WriteCSLJSON(w io.Writer, records []Record) error {
+	items := make([]cslItem, len(records))
+	for i, record := range records {
+		items[i] = newCSLItem(record)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(items)
+}
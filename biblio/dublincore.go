@@ -0,0 +1,87 @@
+package biblio
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// dublinCore is a record in the oai_dc schema of OAI-PMH, which holds the fifteen
+// Dublin Core elements.
+type dublinCore struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	OAIDC          string   `xml:"xmlns:oai_dc,attr"`
+	DC             string   `xml:"xmlns:dc,attr"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+
+	Title       string   `xml:"dc:title"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Subjects    []string `xml:"dc:subject"`
+	Description string   `xml:"dc:description,omitempty"`
+	Publisher   string   `xml:"dc:publisher,omitempty"`
+	Date        string   `xml:"dc:date,omitempty"`
+	Type        string   `xml:"dc:type"`
+	Identifiers []string `xml:"dc:identifier"`
+	Language    string   `xml:"dc:language,omitempty"`
+}
+
+// dublinCoreCollection wraps several oai_dc records; a single record is written alone.
+type dublinCoreCollection struct {
+	XMLName xml.Name     `xml:"records"`
+	Records []dublinCore `xml:"oai_dc:dc"`
+}
+
+// newDublinCore maps a record onto the Dublin Core elements.
+func newDublinCore(record Record) dublinCore {
+	dc := dublinCore{
+		OAIDC:          "http://www.openarchives.org/OAI/2.0/oai_dc/",
+		DC:             "http://purl.org/dc/elements/1.1/",
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+		Title:          record.Title,
+		Creator:        record.Author.Inverted(),
+		Description:    record.Description,
+		Publisher:      record.Publisher,
+		Type:           "Text", // DCMI Type Vocabulary
+		Language:       record.Language,
+	}
+	if record.Format == "audiobook" {
+		dc.Type = "Sound"
+	}
+	if record.Year > 0 {
+		dc.Date = strconv.Itoa(record.Year)
+	}
+	dc.Subjects = append(dc.Subjects, record.Subjects...)
+	dc.Subjects = append(dc.Subjects, record.Keywords...)
+	if record.ISBN != "" {
+		dc.Identifiers = append(dc.Identifiers, "urn:isbn:"+record.ISBN)
+	}
+	return dc
+}
+
+// WriteDublinCore writes a single record as an oai_dc document, or several records
+// inside a <records> element.
+func WriteDublinCore(w io.Writer, records []Record) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	var err error
+	if len(records) == 1 {
+		err = encoder.Encode(newDublinCore(records[0]))
+	} else {
+		collection := dublinCoreCollection{Records: make([]dublinCore, len(records))}
+		for i, record := range records {
+			collection.Records[i] = newDublinCore(record)
+		}
+		err = encoder.Encode(collection)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
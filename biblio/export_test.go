@@ -0,0 +1,118 @@
+package biblio
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gin-books-api/models"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the export formats")
+
+// schemas are the schemas in testdata that the formats which have one are validated
+// against: XML Schemas with xmllint, and JSON Schemas in process.
+var schemas = map[string]string{
+	"marcxml":  "MARC21slim.xsd",
+	"dc":       "oai_dc.xsd",
+	"csl-json": "csl-data.json",
+}
+
+// goldenBook is a book with every field the formats describe, and characters some
+// formats escape.
+func goldenBook() models.Book {
+	authorID, publisherID, categoryID := uint(3), uint(5), uint(7)
+	return models.Book{
+		ID:            42,
+		Title:         "The Go Programming Language",
+		Description:   "Covers the language & its standard library, 100% of it: {braces}, <tags> and_underscores.",
+		PublishedYear: 2015,
+		AuthorID:      &authorID,
+		PublisherID:   &publisherID,
+		CategoryID:    &categoryID,
+		ISBN:          "9780134190440",
+		Language:      "en",
+		Format:        "paperback",
+		Author:        models.Author{ID: authorID, Name: "Alan A. A. Donovan"},
+		Publisher:     models.Publisher{ID: publisherID, Name: "Addison-Wesley", Address: "Boston"},
+		Category:      models.Category{ID: categoryID, Name: "Programming"},
+		Subjects:      []models.Subject{{ID: 1, Name: "Go (Computer program language)"}},
+		Tags:          []models.Tag{{ID: 2, Name: "golang"}, {ID: 3, Name: "concurrency"}},
+	}
+}
+
+// TestFormatsMatchGoldenFiles renders goldenBook in every format and compares the
+// output byte for byte with testdata/book.<format>.golden. Run with -update to
+// rewrite the golden files after an intended change.
+func TestFormatsMatchGoldenFiles(t *testing.T) {
+	records := []Record{NewRecord(goldenBook())}
+	for name, format := range Formats {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := format.Write(&out, records); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "book."+name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s", golden, out.Bytes())
+			}
+
+			if schema, ok := schemas[name]; ok {
+				validate(t, filepath.Join("testdata", schema), golden)
+			}
+		})
+	}
+}
+
+// validate validates the document at path against the schema at schemaPath.
+func validate(t *testing.T, schemaPath, path string) {
+	t.Helper()
+	if filepath.Ext(schemaPath) == ".json" {
+		schema, err := jsonschema.Compile(schemaPath)
+		if err != nil {
+			t.Fatalf("compiling %s: %v", schemaPath, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := schema.Validate(document); err != nil {
+			t.Errorf("%s is not valid against %s: %v", path, schemaPath, err)
+		}
+		return
+	}
+
+	// Locally the schemas are checked when xmllint is installed; in CI, where the CI
+	// variable is set, a missing xmllint fails the test rather than skipping the checks
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil && os.Getenv("CI") != "" {
+		t.Fatal("xmllint is not installed; install it (libxml2-utils) to validate against " + schemaPath)
+	}
+	if err != nil {
+		t.Skip("xmllint is not installed; not validating against " + schemaPath)
+	}
+	output, err := exec.Command(xmllint, "--noout", "--nonet", "--schema", schemaPath, path).CombinedOutput()
+	if err != nil {
+		t.Errorf("%s is not valid against %s: %v\n%s", path, schemaPath, err, output)
+	}
+}
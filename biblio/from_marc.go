@@ -0,0 +1,172 @@
+package biblio
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gin-books-api/marc"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	yearPattern = regexp.MustCompile(`\d{4}`)
+	isbnPattern = regexp.MustCompile(`^[0-9Xx-]{10,17}`)
+)
+
+// FromMARC maps a MARC 21 bibliographic record onto a record: 020 (ISBN), 100 and 700
+// (authors), 245 (title), 260 or 264 (publisher, place and year), 520 (summary) and
+// 650 (subjects), with the language and year of 008 as fallbacks. The control number
+// (001) belongs to the sender, so the record has no ID.
+func FromMARC(source marc.Record) Record {
+	var record Record
+
+	for _, field := range source.Fields("020") {
+		if isbn := isbnPattern.FindString(strings.TrimSpace(field.Subfield("a"))); isbn != "" {
+			record.ISBN = strings.ReplaceAll(isbn, "-", "")
+			break
+		}
+	}
+
+	if fields := source.Fields("245"); len(fields) > 0 {
+		title := trimISBD(fields[0].Subfield("a"))
+		if subtitle := trimISBD(fields[0].Subfield("b")); subtitle != "" {
+			title += ": " + subtitle
+		}
+		record.Title = title
+	}
+
+	for _, tag := range []string{"100", "700"} {
+		for _, field := range source.Fields(tag) {
+			name := personalName(field.Subfield("a"))
+			if name.Empty() {
+				continue
+			}
+			if record.Author.Empty() {
+				record.Author = name
+			} else {
+				record.Contributors = append(record.Contributors, name)
+			}
+		}
+	}
+
+	// Prefer the publication statement of 264 (second indicator 1) over 260
+	publication := source.Fields("260")
+	for _, field := range source.Fields("264") {
+		if field.Ind2 == "1" {
+			publication = []marc.DataField{field}
+			break
+		}
+	}
+	if len(publication) > 0 {
+		record.PublisherPlace = trimISBD(publication[0].Subfield("a"))
+		record.Publisher = trimISBD(publication[0].Subfield("b"))
+		if year := yearPattern.FindString(publication[0].Subfield("c")); year != "" {
+			record.Year, _ = strconv.Atoi(year)
+		}
+	}
+
+	fixed := source.ControlField("008")
+	if record.Year == 0 && len(fixed) >= 11 {
+		if year, err := strconv.Atoi(fixed[7:11]); err == nil && year > 0 {
+			record.Year = year
+		}
+	}
+
+	var summaries []string
+	for _, field := range source.Fields("520") {
+		if summary := strings.TrimSpace(field.Subfield("a")); summary != "" {
+			summaries = append(summaries, summary)
+		}
+	}
+	record.Description = strings.Join(summaries, "\n\n")
+
+	for _, field := range source.Fields("650") {
+		var parts []string
+		for _, subfield := range field.Subfields {
+			switch subfield.Code {
+			case "a", "v", "x", "y", "z":
+				if part := trimISBD(subfield.Value); part != "" {
+					parts = append(parts, part)
+				}
+			}
+		}
+		if len(parts) > 0 {
+			record.Subjects = append(record.Subjects, strings.Join(parts, " -- "))
+		}
+	}
+
+	code := ""
+	if fields := source.Fields("041"); len(fields) > 0 {
+		code = fields[0].Subfield("a")
+	}
+	if code == "" && len(fixed) >= 38 {
+		code = fixed[35:38]
+	}
+	record.Language = languageTag(code)
+
+	switch {
+	case len(source.Leader) > 6 && source.Leader[6] == 'i':
+		record.Format = "audiobook"
+	case hasCarrier(source, "cr"):
+		record.Format = "ebook"
+	}
+	return record
+}
+
+// trimISBD removes the ISBD punctuation that ends MARC subfields, such as " /" or " :".
+func trimISBD(value string) string {
+	value = strings.TrimSpace(value)
+	value = strings.TrimRight(value, " /:;,=")
+	// Keep the period of a trailing initial or abbreviation, as in "Donovan, Alan A."
+	if strings.HasSuffix(value, ".") {
+		words := strings.Fields(value)
+		if last := strings.TrimSuffix(words[len(words)-1], "."); len(last) > 1 && !strings.Contains(last, ".") {
+			value = strings.TrimSuffix(value, ".")
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// personalName parses a name in the inverted form of 100 and 700 $a ("Family, Given").
+func personalName(value string) Name {
+	value = trimISBD(value)
+	if family, given, ok := strings.Cut(value, ","); ok {
+		return Name{Family: strings.TrimSpace(family), Given: strings.TrimSpace(given)}
+	}
+	return splitName(value)
+}
+
+// Natural returns the name as "Given Family", the form the catalogue stores.
+func (n Name) Natural() string {
+	return strings.TrimSpace(n.Given + " " + n.Family)
+}
+
+// languageTag returns the BCP 47 tag of a three-letter MARC language code.
+func languageTag(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) != 3 || code == "und" || code == "zxx" || code == "mul" {
+		return ""
+	}
+	for terminology, bibliographic := range bibliographicCodes {
+		if bibliographic == code {
+			code = terminology
+			break
+		}
+	}
+	base, err := language.ParseBase(code)
+	if err != nil {
+		return ""
+	}
+	return base.String()
+}
+
+func hasCarrier(record marc.Record, carrier string) bool {
+	for _, field := range record.Fields("338") {
+		if field.Subfield("b") == carrier {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package biblio
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gin-books-api/marc"
+)
+
+// rdaTypes are the RDA content, media and carrier types (fields 336-338) of each format.
+var rdaTypes = map[string][3][2]string{
+	"":          {{"text", "txt"}, {"unmediated", "n"}, {"volume", "nc"}},
+	"hardcover": {{"text", "txt"}, {"unmediated", "n"}, {"volume", "nc"}},
+	"paperback": {{"text", "txt"}, {"unmediated", "n"}, {"volume", "nc"}},
+	"ebook":     {{"text", "txt"}, {"computer", "c"}, {"online resource", "cr"}},
+	"audiobook": {{"spoken word", "spw"}, {"audio", "s"}, {"audio disc", "sd"}},
+}
+
+// MARCRecord maps a record onto a MARC 21 bibliographic record.
+func MARCRecord(record Record) marc.Record {
+	// Language material, or a nonmusical sound recording for audiobooks
+	recordType := byte('a')
+	if record.Format == "audiobook" {
+		recordType = 'i'
+	}
+	result := marc.Record{Leader: fmt.Sprintf("00000n%cm a2200000 i 4500", recordType)}
+
+	result.AddControlField("001", strconv.FormatUint(uint64(record.ID), 10))
+	result.AddControlField("008", fixedLengthData(record, recordType))
+
+	result.AddDataField("020", ' ', ' ', "a", record.ISBN)
+	result.AddDataField("041", '0', ' ', "a", marcLanguage(record.Language))
+	titleAdded := byte('0')
+	if !record.Author.Empty() {
+		result.AddDataField("100", '1', ' ', "a", record.Author.Inverted())
+		titleAdded = '1'
+	}
+	result.AddDataField("245", titleAdded, nonfilingCharacters(record.Title), "a", record.Title)
+	var year string
+	if record.Year > 0 {
+		year = strconv.Itoa(record.Year)
+	}
+	result.AddDataField("264", ' ', '1', "a", record.PublisherPlace, "b", record.Publisher, "c", year)
+
+	types, ok := rdaTypes[record.Format]
+	if !ok {
+		types = rdaTypes[""]
+	}
+	for i, source := range []string{"rdacontent", "rdamedia", "rdacarrier"} {
+		result.AddDataField(strconv.Itoa(336+i), ' ', ' ', "a", types[i][0], "b", types[i][1], "2", source)
+	}
+
+	result.AddDataField("520", ' ', ' ', "a", record.Description)
+	for _, subject := range record.Subjects {
+		result.AddDataField("650", ' ', '4', "a", subject)
+	}
+	for _, keyword := range record.Keywords {
+		result.AddDataField("653", ' ', ' ', "a", keyword)
+	}
+	return result
+}
+
+// nonfilingCharacters returns the second indicator of field 245: the number of
+// characters of a leading article that sorting skips, as in "The ".
+func nonfilingCharacters(title string) byte {
+	for _, article := range []string{"The ", "An ", "A "} {
+		if len(title) > len(article) && strings.EqualFold(title[:len(article)], article) {
+			return byte('0' + len(article))
+		}
+	}
+	return '0'
+}
+
+// fixedLengthData builds the 40 characters of field 008. Positions the catalogue has
+// no data for are blank or filled with "|" (no attempt to code).
+func fixedLengthData(record Record, recordType byte) string {
+	dates := "nuuuu    "
+	if record.Year > 0 {
+		dates = fmt.Sprintf("s%04d    ", record.Year)
+	}
+
+	// Positions 18-34 depend on the type of material; only the form of item of
+	// books (position 23) is coded
+	material := []byte("|||||||||||||||||")
+	if recordType == 'a' {
+		material[5] = ' '
+		if record.Format == "ebook" {
+			material[5] = 'o'
+		}
+	}
+
+	lang := marcLanguage(record.Language)
+	if lang == "" {
+		lang = "und"
+	}
+	return "      " + dates + "xx " + string(material) + lang + " d"
+}
+
+// WriteMARCXML writes records as a MARCXML collection.
+func WriteMARCXML(w io.Writer, records []Record) error {
+	marcRecords := make([]marc.Record, len(records))
+	for i, record := range records {
+		marcRecords[i] = MARCRecord(record)
+	}
+	return marc.WriteXML(w, marcRecords)
+}
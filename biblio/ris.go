@@ -0,0 +1,52 @@
+package biblio
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// risTypes are the RIS reference types of each format.
+var risTypes = map[string]string{
+	"ebook":     "EBOOK",
+	"audiobook": "SOUND",
+}
+
+// WriteRIS writes one RIS reference per record. RIS lines are "TAG  - value" and end
+// with CRLF; every reference starts with TY and ends with ER.
+func WriteRIS(w io.Writer, records []Record) error {
+	out := bufio.NewWriter(w)
+	for _, record := range records {
+		tag := func(name, value string) {
+			// A value cannot span lines
+			value = strings.Join(strings.Fields(value), " ")
+			if value != "" {
+				out.WriteString(name + "  - " + value + "\r\n")
+			}
+		}
+
+		referenceType, ok := risTypes[record.Format]
+		if !ok {
+			referenceType = "BOOK"
+		}
+		tag("TY", referenceType)
+		tag("ID", strconv.FormatUint(uint64(record.ID), 10))
+		tag("TI", record.Title)
+		tag("AU", record.Author.Inverted())
+		tag("PY", yearOrEmpty(record.Year))
+		tag("PB", record.Publisher)
+		tag("CY", record.PublisherPlace)
+		tag("SN", record.ISBN)
+		tag("LA", record.Language)
+		tag("AB", record.Description)
+		for _, keyword := range record.Subjects {
+			tag("KW", keyword)
+		}
+		for _, keyword := range record.Keywords {
+			tag("KW", keyword)
+		}
+		out.WriteString("ER  - \r\n")
+	}
+	return out.Flush()
+}
@@ -3,11 +3,16 @@ package cache
 import (
     "context"
     "encoding/json"
-    "log"
+    "log/slog"
     "time"
 
     "gin-books-api/configs"
+    "gin-books-api/metrics"
+    "gin-books-api/tracing"
     "github.com/go-redis/redis/v8"
+    "go.opentelemetry.io/otel/attribute"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
 )
 
 // CacheExpiration defines the default expiration time for cache entries
@@ -16,28 +21,73 @@ const CacheExpiration = 10 * time.Second
 // GetCachedData retrieves data from Redis cache.
 // Returns true if data is successfully retrieved and unmarshaled into dest.
 func GetCachedData(ctx context.Context, key string, dest interface{}) bool {
+    ctx, span := startSpan(ctx, "GET", key)
     cacheData, err := config.RedisClient.Get(ctx, key).Result()
     if err == redis.Nil {
         // Cache miss
+        metrics.ObserveCacheLookup(key, metrics.CacheMiss)
+        span.SetAttributes(attribute.Bool("cache.hit", false))
+        tracing.End(span, nil)
         return false
     } else if err != nil {
-        log.Printf("Redis GET error for key %s: %v", key, err)
+        slog.WarnContext(ctx, "Redis GET failed", "key", key, "error", err)
+        metrics.ObserveCacheLookup(key, metrics.CacheError)
+        tracing.End(span, err)
         return false
     }
 
     if err := json.Unmarshal([]byte(cacheData), dest); err != nil {
-        log.Printf("JSON Unmarshal error for key %s: %v", key, err)
+        slog.WarnContext(ctx, "Failed to decode cached data", "key", key, "error", err)
+        metrics.ObserveCacheLookup(key, metrics.CacheError)
+        tracing.End(span, err)
         return false
     }
+    metrics.ObserveCacheLookup(key, metrics.CacheHit)
+    span.SetAttributes(attribute.Bool("cache.hit", true))
+    tracing.End(span, nil)
     return true
 }
 
 // SetCachedData stores data in Redis cache.
 // Returns an error if the operation fails.
-func SetCachedData(ctx context.Context, key string, data interface{}, expiration time.Duration) error {
+func SetCachedData(ctx context.Context, key string, data interface{}, expiration time.Duration) (err error) {
+    ctx, span := startSpan(ctx, "SET", key)
+    defer func() { tracing.End(span, err) }()
+
     jsonData, err := json.Marshal(data)
     if err != nil {
         return err
     }
-    return config.RedisClient.Set(ctx, key, jsonData, expiration).Err()
-}
\ No newline at end of file
+    if err := config.RedisClient.Set(ctx, key, jsonData, expiration).Err(); err != nil {
+        metrics.ObserveCacheWriteError(key)
+        return err
+    }
+    return nil
+}
+// ClaimKey stores data under key unless the key already exists.
+// Returns true if the key was claimed by this call.
+func ClaimKey(ctx context.Context, key string, data interface{}, expiration time.Duration) (bool, error) {
+    jsonData, err := json.Marshal(data)
+    if err != nil {
+        return false, err
+    }
+    return config.RedisClient.SetNX(ctx, key, jsonData, expiration).Result()
+}
+
+// ExpireKey sets the expiration of key, if it exists.
+func ExpireKey(ctx context.Context, key string, expiration time.Duration) error {
+    return config.RedisClient.Expire(ctx, key, expiration).Err()
+}
+
+// DeleteCachedData removes key from Redis cache.
+func DeleteCachedData(ctx context.Context, key string) error {
+    return config.RedisClient.Del(ctx, key).Err()
+}
+
+// startSpan starts the span of a cache command on key. Spans carry the family of the
+// key rather than the key, which may hold IDs of users.
+func startSpan(ctx context.Context, command, key string) (context.Context, trace.Span) {
+    return tracing.Start(ctx, "cache "+command, trace.WithSpanKind(trace.SpanKindClient),
+        trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationName(command),
+            attribute.String("cache.family", metrics.CacheFamily(key))))
+}
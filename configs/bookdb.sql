@@ -14,7 +14,15 @@ CREATE TABLE publishers (
 
 CREATE TABLE categories (
     id SERIAL PRIMARY KEY,
-    name VARCHAR(255) NOT NULL
+    name VARCHAR(255) NOT NULL,
+    parent_id INT REFERENCES categories(id) ON DELETE RESTRICT
+);
+
+CREATE TABLE works (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    original_language VARCHAR(35)
 );
 
 CREATE TABLE books (
@@ -25,7 +33,30 @@ CREATE TABLE books (
     author_id INT REFERENCES authors(id) ON DELETE SET NULL,
     publisher_id INT REFERENCES publishers(id) ON DELETE SET NULL,
     category_id INT REFERENCES categories(id) ON DELETE SET NULL,  -- Added category_id column
-    availability BOOLEAN DEFAULT TRUE 
+    work_id INT REFERENCES works(id) ON DELETE SET NULL,
+    isbn VARCHAR(17),
+    language VARCHAR(35),
+    format VARCHAR(50),
+    availability BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_books_isbn ON books(isbn);
+CREATE INDEX idx_books_created_at ON books(created_at);
+
+CREATE TABLE series (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT
+);
+
+CREATE TABLE series_entries (
+    id SERIAL PRIMARY KEY,
+    series_id INT NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    position NUMERIC(8, 2) NOT NULL,
+    UNIQUE (series_id, book_id)
 );
 
 CREATE TABLE users (
@@ -51,4 +82,50 @@ CREATE TABLE borrowed_books (
     user_id INT REFERENCES users(id) ON DELETE CASCADE,
     borrowed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
     due_date TIMESTAMP NOT NULL
-);
\ No newline at end of file
+);
+
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL
+);
+
+CREATE TABLE book_tags (
+    book_id INT REFERENCES books(id) ON DELETE CASCADE,
+    tag_id INT REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, tag_id)
+);
+
+CREATE TABLE subjects (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(50),
+    parent_id INT REFERENCES subjects(id) ON DELETE SET NULL
+);
+
+CREATE TABLE book_subjects (
+    book_id INT REFERENCES books(id) ON DELETE CASCADE,
+    subject_id INT REFERENCES subjects(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, subject_id)
+);
+
+CREATE TABLE audit_logs (
+    id SERIAL PRIMARY KEY,
+    actor_id INT,
+    action VARCHAR(20) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    diff JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_logs_entity ON audit_logs(entity, entity_id);
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs(actor_id);
+
+-- Versions the schema was migrated to; bump models.SchemaVersion and add a row with
+-- every change to this file, so that /readyz reports instances on an older schema
+CREATE TABLE schema_migrations (
+    version INT PRIMARY KEY,
+    migrated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+INSERT INTO schema_migrations (version) VALUES (1);
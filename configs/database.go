@@ -3,12 +3,15 @@ package config
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
@@ -24,18 +27,17 @@ func InitRedis() {
 
 	_, err := RedisClient.Ping(ctx).Result()
 	if err != nil {
-		fmt.Println("Failed to connect to Redis: ", err)
+		slog.Warn("Failed to connect to Redis; serving without the cache, see /readyz", "error", err)
 		return
 	}
 }
 
-func InitDB() {
-	// Load .env file
-	errload := godotenv.Load()
-	if errload != nil {
-		fmt.Println("Error loading .env file")
-	}
+// LoadEnv loads the environment variables of the .env file, if there is one.
+func LoadEnv() error {
+	return godotenv.Load()
+}
 
+func InitDB() {
 	// Get environment variables
 	host := os.Getenv("DB_HOST")
 	user := os.Getenv("DB_USER")
@@ -50,9 +52,9 @@ func InitDB() {
 		host, user, password, dbname, port, sslmode, timezone)
 	var err error
 
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger()})
 	if err != nil {
-		fmt.Println("Failed to connect to database: ", err)
+		slog.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
 	}
 }
@@ -60,3 +62,14 @@ func InitDB() {
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// gormLogger returns the logger of gorm, which writes its warnings, such as slow
+// queries, through the default slog logger. Missing rows are not logged, since they
+// are reported to clients as 404s.
+func gormLogger() logger.Interface {
+	return logger.New(slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "gin-books-api/models"
+
+// AuthorRequest is the payload for creating or replacing an author.
+type AuthorRequest struct {
+	Name  string `json:"name" binding:"required,max=255"`
+	Bio   string `json:"bio" binding:"max=10000"`
+	Email string `json:"email" binding:"required,email,max=255"`
+}
+
+// References implements Request.
+func (r *AuthorRequest) References() []Reference {
+	return nil
+}
+
+// Model converts the request into an author.
+func (r *AuthorRequest) Model() models.Author {
+	return models.Author{Name: r.Name, Bio: r.Bio, Email: r.Email}
+}
+
+// NewAuthorRequest returns the request that would recreate author as it is.
+func NewAuthorRequest(author models.Author) AuthorRequest {
+	return AuthorRequest{Name: author.Name, Bio: author.Bio, Email: author.Email}
+}
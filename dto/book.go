@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"strconv"
+
+	"gin-books-api/models"
+)
+
+// TagRequest names a tag. Unknown tags are created.
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// SubjectReference points to an existing subject heading.
+type SubjectReference struct {
+	ID uint `json:"id" binding:"required,min=1"`
+}
+
+// BookRequest is the payload for creating or replacing a book.
+type BookRequest struct {
+	Title         string             `json:"title" binding:"required,max=255"`
+	Description   string             `json:"description" binding:"max=10000"`
+	PublishedYear int                `json:"published_year" binding:"omitempty,min=1,max=2100"`
+	AuthorID      *uint              `json:"author_id" binding:"omitempty,min=1"`
+	PublisherID   *uint              `json:"publisher_id" binding:"omitempty,min=1"`
+	CategoryID    *uint              `json:"category_id" binding:"omitempty,min=1"`
+	WorkID        *uint              `json:"work_id" binding:"omitempty,min=1"`
+	ISBN          string             `json:"isbn" binding:"omitempty,isbn"`
+	Language      string             `json:"language" binding:"omitempty,bcp47_language_tag"`
+	Format        string             `json:"format" binding:"omitempty,oneof=hardcover paperback ebook audiobook"`
+	Availability  *bool              `json:"availability"` // Defaults to true
+	Tags          []TagRequest       `json:"tags" binding:"omitempty,dive"`
+	Subjects      []SubjectReference `json:"subjects" binding:"omitempty,dive"`
+}
+
+// References implements Request.
+func (r *BookRequest) References() []Reference {
+	var refs []Reference
+	refs = optionalReference(refs, "author_id", "authors", r.AuthorID)
+	refs = optionalReference(refs, "publisher_id", "publishers", r.PublisherID)
+	refs = optionalReference(refs, "category_id", "categories", r.CategoryID)
+	refs = optionalReference(refs, "work_id", "works", r.WorkID)
+	for i, subject := range r.Subjects {
+		refs = append(refs, Reference{Field: "subjects[" + strconv.Itoa(i) + "].id", Table: "subjects", ID: subject.ID})
+	}
+	return refs
+}
+
+// Model converts the request into a book.
+func (r *BookRequest) Model() models.Book {
+	book := models.Book{
+		Title:         r.Title,
+		Description:   r.Description,
+		PublishedYear: r.PublishedYear,
+		AuthorID:      r.AuthorID,
+		PublisherID:   r.PublisherID,
+		CategoryID:    r.CategoryID,
+		WorkID:        r.WorkID,
+		ISBN:          r.ISBN,
+		Language:      r.Language,
+		Format:        r.Format,
+		Availability:  r.Availability == nil || *r.Availability,
+	}
+	for _, tag := range r.Tags {
+		book.Tags = append(book.Tags, models.Tag{Name: tag.Name})
+	}
+	for _, subject := range r.Subjects {
+		book.Subjects = append(book.Subjects, models.Subject{ID: subject.ID})
+	}
+	return book
+}
+
+// NewBookRequest returns the request that would recreate book as it is.
+func NewBookRequest(book models.Book) BookRequest {
+	availability := book.Availability
+	req := BookRequest{
+		Title:         book.Title,
+		Description:   book.Description,
+		PublishedYear: book.PublishedYear,
+		AuthorID:      book.AuthorID,
+		PublisherID:   book.PublisherID,
+		CategoryID:    book.CategoryID,
+		WorkID:        book.WorkID,
+		ISBN:          book.ISBN,
+		Language:      book.Language,
+		Format:        book.Format,
+		Availability:  &availability,
+		Tags:          []TagRequest{},
+		Subjects:      []SubjectReference{},
+	}
+	for _, tag := range book.Tags {
+		req.Tags = append(req.Tags, TagRequest{Name: tag.Name})
+	}
+	for _, subject := range book.Subjects {
+		req.Subjects = append(req.Subjects, SubjectReference{ID: subject.ID})
+	}
+	return req
+}
@@ -0,0 +1,234 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/models"
+)
+
+// Content types accepted by the book import.
+const (
+	ContentTypeCSV    = "text/csv"
+	ContentTypeJSON   = "application/json"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// BookImportRow is one book of a bulk import. Authors, publishers and categories are
+// given by name and created when no row with that name exists.
+type BookImportRow struct {
+	Title         string   `json:"title" binding:"required,max=255"`
+	Description   string   `json:"description" binding:"max=10000"`
+	PublishedYear int      `json:"published_year" binding:"omitempty,min=1,max=2100"`
+	ISBN          string   `json:"isbn" binding:"omitempty,isbn"`
+	Language      string   `json:"language" binding:"omitempty,bcp47_language_tag"`
+	Format        string   `json:"format" binding:"omitempty,oneof=hardcover paperback ebook audiobook"`
+	Availability  *bool    `json:"availability"` // Defaults to true
+	Author        string   `json:"author" binding:"max=255"`
+	Publisher     string   `json:"publisher" binding:"max=255"`
+	Category      string   `json:"category" binding:"max=255"`
+	Tags          []string `json:"tags" binding:"omitempty,dive,max=100"`
+}
+
+// Model converts the row into a book, without its author, publisher and category.
+func (r *BookImportRow) Model() models.Book {
+	book := models.Book{
+		Title:         r.Title,
+		Description:   r.Description,
+		PublishedYear: r.PublishedYear,
+		ISBN:          r.ISBN,
+		Language:      r.Language,
+		Format:        r.Format,
+		Availability:  r.Availability == nil || *r.Availability,
+	}
+	for _, tag := range r.Tags {
+		book.Tags = append(book.Tags, models.Tag{Name: tag})
+	}
+	return book
+}
+
+// RowError reports a row that could not be decoded. Decoding can go on with the next row.
+type RowError struct {
+	Details []apperrors.FieldError
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("invalid row: %d field errors", len(e.Details))
+}
+
+// BookImportDecoder reads import rows one at a time, so that an import never holds the
+// whole file in memory.
+type BookImportDecoder interface {
+	// Next decodes the next row into row. It returns io.EOF after the last row and a
+	// *RowError when only this row is unusable. Any other error means the rest of the
+	// input cannot be read.
+	Next(row *BookImportRow) error
+}
+
+// NewBookImportDecoder returns the decoder for an import body of the given content type:
+// CSV with a header row, a JSON array or newline-delimited JSON.
+func NewBookImportDecoder(contentType string, r io.Reader) (BookImportDecoder, error) {
+	switch contentType {
+	case ContentTypeCSV:
+		return newCSVBookDecoder(r)
+	case ContentTypeJSON:
+		return newJSONArrayBookDecoder(r)
+	case ContentTypeNDJSON, "application/ndjson":
+		return &ndjsonBookDecoder{dec: json.NewDecoder(r)}, nil
+	}
+	return nil, apperrors.New(apperrors.KindUnsupportedMediaType, "unsupported_media_type",
+		"Import accepts "+ContentTypeCSV+", "+ContentTypeJSON+" or "+ContentTypeNDJSON)
+}
+
+// csvBookDecoder reads CSV whose header names the BookImportRow fields. Tags are
+// separated by semicolons.
+type csvBookDecoder struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+var csvBookColumns = map[string]bool{
+	"title": true, "description": true, "published_year": true, "isbn": true, "language": true,
+	"format": true, "availability": true, "author": true, "publisher": true, "category": true, "tags": true,
+}
+
+func newCSVBookDecoder(r io.Reader) (*csvBookDecoder, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, apperrors.BadRequest("malformed_body", "CSV import must start with a header row")
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !csvBookColumns[name] {
+			return nil, apperrors.BadRequest("unknown_column", fmt.Sprintf("Unknown CSV column %q", name))
+		}
+		columns[i] = name
+	}
+	return &csvBookDecoder{reader: reader, columns: columns}, nil
+}
+
+func (d *csvBookDecoder) Next(row *BookImportRow) error {
+	record, err := d.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && !errors.Is(parseErr.Err, csv.ErrQuote) {
+			return &RowError{Details: []apperrors.FieldError{{Code: "malformed_row", Message: parseErr.Error()}}}
+		}
+		return err
+	}
+
+	*row = BookImportRow{}
+	var details []apperrors.FieldError
+	for i, value := range record {
+		if i >= len(d.columns) {
+			details = append(details, apperrors.FieldError{Code: "malformed_row", Message: "has more fields than the header"})
+			break
+		}
+		value = strings.TrimSpace(value)
+		switch d.columns[i] {
+		case "title":
+			row.Title = value
+		case "description":
+			row.Description = value
+		case "published_year":
+			if value == "" {
+				continue
+			}
+			year, err := strconv.Atoi(value)
+			if err != nil {
+				details = append(details, apperrors.FieldError{Field: "published_year", Code: "invalid_type", Message: "must be of type int"})
+			}
+			row.PublishedYear = year
+		case "isbn":
+			row.ISBN = value
+		case "language":
+			row.Language = value
+		case "format":
+			row.Format = value
+		case "availability":
+			if value == "" {
+				continue
+			}
+			available, err := strconv.ParseBool(value)
+			if err != nil {
+				details = append(details, apperrors.FieldError{Field: "availability", Code: "invalid_type", Message: "must be of type bool"})
+			}
+			row.Availability = &available
+		case "author":
+			row.Author = value
+		case "publisher":
+			row.Publisher = value
+		case "category":
+			row.Category = value
+		case "tags":
+			for _, tag := range strings.Split(value, ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					row.Tags = append(row.Tags, tag)
+				}
+			}
+		}
+	}
+	if len(details) > 0 {
+		return &RowError{Details: details}
+	}
+	return nil
+}
+
+// jsonArrayBookDecoder reads the elements of a JSON array one at a time.
+type jsonArrayBookDecoder struct {
+	dec  *json.Decoder
+	done bool
+}
+
+func newJSONArrayBookDecoder(r io.Reader) (*jsonArrayBookDecoder, error) {
+	dec := json.NewDecoder(r)
+	if token, err := dec.Token(); err != nil || token != json.Delim('[') {
+		return nil, apperrors.BadRequest("malformed_body", "JSON import must be an array of books")
+	}
+	return &jsonArrayBookDecoder{dec: dec}, nil
+}
+
+func (d *jsonArrayBookDecoder) Next(row *BookImportRow) error {
+	if d.done || !d.dec.More() {
+		d.done = true
+		return io.EOF
+	}
+	return decodeBookImportRow(d.dec, row)
+}
+
+// ndjsonBookDecoder reads one JSON object per line.
+type ndjsonBookDecoder struct {
+	dec *json.Decoder
+}
+
+func (d *ndjsonBookDecoder) Next(row *BookImportRow) error {
+	return decodeBookImportRow(d.dec, row)
+}
+
+// decodeBookImportRow decodes the next JSON value. A value of the wrong type only fails
+// its own row, since the decoder has consumed it; a syntax error ends the import.
+func decodeBookImportRow(dec *json.Decoder, row *BookImportRow) error {
+	*row = BookImportRow{}
+	err := dec.Decode(row)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &RowError{Details: []apperrors.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}}
+	}
+	return err
+}
@@ -0,0 +1,24 @@
+package dto
+
+import "gin-books-api/models"
+
+// CategoryRequest is the payload for creating, replacing or moving a category.
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentID *uint  `json:"parent_id" binding:"omitempty,min=1"`
+}
+
+// References implements Request.
+func (r *CategoryRequest) References() []Reference {
+	return optionalReference(nil, "parent_id", "categories", r.ParentID)
+}
+
+// Model converts the request into a category.
+func (r *CategoryRequest) Model() models.Category {
+	return models.Category{Name: r.Name, ParentID: r.ParentID}
+}
+
+// NewCategoryRequest returns the request that would recreate category as it is.
+func NewCategoryRequest(category models.Category) CategoryRequest {
+	return CategoryRequest{Name: category.Name, ParentID: category.ParentID}
+}
@@ -0,0 +1,44 @@
+// Package dto defines the request payloads accepted by the API, with their
+// validation rules, and converts them into models.
+package dto
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Reference is a foreign key carried by a request, which must point to an existing row.
+type Reference struct {
+	Field string // JSON field of the request, e.g. "author_id"
+	Table string // Referenced table, e.g. "authors"
+	ID    uint
+}
+
+// Request is a payload whose foreign keys are checked before it is written.
+type Request interface {
+	References() []Reference
+}
+
+func init() {
+	// Report validation errors with the JSON field names clients send
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// optionalReference returns the reference for a nullable foreign key, if it is set.
+func optionalReference(refs []Reference, field, table string, id *uint) []Reference {
+	if id == nil {
+		return refs
+	}
+	return append(refs, Reference{Field: field, Table: table, ID: *id})
+}
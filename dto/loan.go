@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"time"
+
+	"gin-books-api/models"
+)
+
+// LoanRequest is the payload for lending a book to a user.
+type LoanRequest struct {
+	BookID  uint       `json:"book_id" binding:"required,min=1"`
+	UserID  uint       `json:"user_id" binding:"required,min=1"`
+	DueDate *time.Time `json:"due_date"` // Defaults to services.LoanPeriod from now
+}
+
+// References implements Request.
+func (r *LoanRequest) References() []Reference {
+	return []Reference{
+		{Field: "book_id", Table: "books", ID: r.BookID},
+		{Field: "user_id", Table: "users", ID: r.UserID},
+	}
+}
+
+// Model converts the request into a loan.
+func (r *LoanRequest) Model() models.BorrowedBook {
+	loan := models.BorrowedBook{BookID: r.BookID, UserID: r.UserID}
+	if r.DueDate != nil {
+		loan.DueDate = *r.DueDate
+	}
+	return loan
+}
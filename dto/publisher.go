@@ -0,0 +1,25 @@
+package dto
+
+import "gin-books-api/models"
+
+// PublisherRequest is the payload for creating or replacing a publisher.
+type PublisherRequest struct {
+	Name    string `json:"name" binding:"required,max=255"`
+	Address string `json:"address" binding:"max=1000"`
+	Phone   string `json:"phone" binding:"max=50"`
+}
+
+// References implements Request.
+func (r *PublisherRequest) References() []Reference {
+	return nil
+}
+
+// Model converts the request into a publisher.
+func (r *PublisherRequest) Model() models.Publisher {
+	return models.Publisher{Name: r.Name, Address: r.Address, Phone: r.Phone}
+}
+
+// NewPublisherRequest returns the request that would recreate publisher as it is.
+func NewPublisherRequest(publisher models.Publisher) PublisherRequest {
+	return PublisherRequest{Name: publisher.Name, Address: publisher.Address, Phone: publisher.Phone}
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "gin-books-api/models"
+
+// ReviewRequest is the payload for creating or replacing a review.
+type ReviewRequest struct {
+	BookID  uint   `json:"book_id" binding:"required,min=1"`
+	UserID  uint   `json:"user_id" binding:"required,min=1"`
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Comment string `json:"comment" binding:"max=10000"`
+}
+
+// References implements Request.
+func (r *ReviewRequest) References() []Reference {
+	return []Reference{
+		{Field: "book_id", Table: "books", ID: r.BookID},
+		{Field: "user_id", Table: "users", ID: r.UserID},
+	}
+}
+
+// Model converts the request into a review.
+func (r *ReviewRequest) Model() models.Review {
+	return models.Review{BookID: r.BookID, UserID: r.UserID, Rating: r.Rating, Comment: r.Comment}
+}
+
+// NewReviewRequest returns the request that would recreate review as it is.
+func NewReviewRequest(review models.Review) ReviewRequest {
+	return ReviewRequest{BookID: review.BookID, UserID: review.UserID, Rating: review.Rating, Comment: review.Comment}
+}
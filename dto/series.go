@@ -0,0 +1,40 @@
+package dto
+
+import "gin-books-api/models"
+
+// SeriesRequest is the payload for creating or replacing a series.
+type SeriesRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=10000"`
+}
+
+// References implements Request.
+func (r *SeriesRequest) References() []Reference {
+	return nil
+}
+
+// Model converts the request into a series.
+func (r *SeriesRequest) Model() models.Series {
+	return models.Series{Name: r.Name, Description: r.Description}
+}
+
+// SeriesEntryRequest is the payload of POST /series/:id/books.
+type SeriesEntryRequest struct {
+	BookID   uint     `json:"book_id" binding:"required,min=1"`
+	Position *float64 `json:"position" binding:"required,gt=0"` // Fractional positions such as 2.5 are allowed
+}
+
+// References implements Request.
+func (r *SeriesEntryRequest) References() []Reference {
+	return []Reference{{Field: "book_id", Table: "books", ID: r.BookID}}
+}
+
+// Model converts the request into a series entry.
+func (r *SeriesEntryRequest) Model() models.SeriesEntry {
+	return models.SeriesEntry{BookID: r.BookID, Position: *r.Position}
+}
+
+// NewSeriesRequest returns the request that would recreate series as it is.
+func NewSeriesRequest(series models.Series) SeriesRequest {
+	return SeriesRequest{Name: series.Name, Description: series.Description}
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "gin-books-api/models"
+
+// SubjectRequest is the payload for creating, replacing or moving a subject heading.
+type SubjectRequest struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	Code     string `json:"code" binding:"max=50"`
+	ParentID *uint  `json:"parent_id" binding:"omitempty,min=1"`
+}
+
+// References implements Request.
+func (r *SubjectRequest) References() []Reference {
+	return optionalReference(nil, "parent_id", "subjects", r.ParentID)
+}
+
+// Model converts the request into a subject.
+func (r *SubjectRequest) Model() models.Subject {
+	return models.Subject{Name: r.Name, Code: r.Code, ParentID: r.ParentID}
+}
+
+// NewSubjectRequest returns the request that would recreate subject as it is.
+func NewSubjectRequest(subject models.Subject) SubjectRequest {
+	return SubjectRequest{Name: subject.Name, Code: subject.Code, ParentID: subject.ParentID}
+}
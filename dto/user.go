@@ -0,0 +1,32 @@
+package dto
+
+import "gin-books-api/models"
+
+// UserRequest is the payload for creating or replacing a user.
+type UserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=255"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,min=8,max=255"`
+	Active   *bool  `json:"active"` // Defaults to true
+}
+
+// References implements Request.
+func (r *UserRequest) References() []Reference {
+	return nil
+}
+
+// Model converts the request into a user.
+func (r *UserRequest) Model() models.User {
+	return models.User{
+		Username: r.Username,
+		Email:    r.Email,
+		Password: r.Password,
+		Active:   r.Active == nil || *r.Active,
+	}
+}
+
+// NewUserRequest returns the request that would recreate user as it is.
+func NewUserRequest(user models.User) UserRequest {
+	active := user.Active
+	return UserRequest{Username: user.Username, Email: user.Email, Password: user.Password, Active: &active}
+}
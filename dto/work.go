@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"strconv"
+
+	"gin-books-api/models"
+)
+
+// WorkRequest is the payload for creating or replacing a work.
+type WorkRequest struct {
+	Title            string `json:"title" binding:"required,max=255"`
+	Description      string `json:"description" binding:"max=10000"`
+	OriginalLanguage string `json:"original_language" binding:"omitempty,bcp47_language_tag"`
+}
+
+// References implements Request.
+func (r *WorkRequest) References() []Reference {
+	return nil
+}
+
+// Model converts the request into a work.
+func (r *WorkRequest) Model() models.Work {
+	return models.Work{Title: r.Title, Description: r.Description, OriginalLanguage: r.OriginalLanguage}
+}
+
+// NewWorkRequest returns the request that would recreate work as it is.
+func NewWorkRequest(work models.Work) WorkRequest {
+	return WorkRequest{Title: work.Title, Description: work.Description, OriginalLanguage: work.OriginalLanguage}
+}
+
+// MergeWorkRequest is the payload of POST /works/:id/merge.
+type MergeWorkRequest struct {
+	WorkID uint `json:"work_id" binding:"required,min=1"` // Work whose editions are merged into :id
+}
+
+// References implements Request.
+func (r *MergeWorkRequest) References() []Reference {
+	return []Reference{{Field: "work_id", Table: "works", ID: r.WorkID}}
+}
+
+// SplitWorkRequest is the payload of POST /works/:id/split.
+type SplitWorkRequest struct {
+	BookIDs          []uint `json:"book_ids" binding:"required,min=1,unique,dive,min=1"` // Editions moved into the new work
+	Title            string `json:"title" binding:"max=255"`
+	Description      string `json:"description" binding:"max=10000"`
+	OriginalLanguage string `json:"original_language" binding:"omitempty,bcp47_language_tag"`
+}
+
+// References implements Request.
+func (r *SplitWorkRequest) References() []Reference {
+	refs := make([]Reference, 0, len(r.BookIDs))
+	for i, id := range r.BookIDs {
+		refs = append(refs, Reference{Field: "book_ids[" + strconv.Itoa(i) + "]", Table: "books", ID: id})
+	}
+	return refs
+}
+
+// Model converts the request into the new work.
+func (r *SplitWorkRequest) Model() models.Work {
+	return models.Work{Title: r.Title, Description: r.Description, OriginalLanguage: r.OriginalLanguage}
+}
@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/services"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Page sizes of connections: first defaults to defaultFirst and may not exceed maxFirst.
+// Lists of the rows related to a node, such as the books of an author, hold the first
+// rows by ID, defaultListFirst unless first says otherwise.
+const (
+	defaultFirst     = 20
+	defaultListFirst = 10
+	maxFirst         = 100
+)
+
+// connection is a page of a list (the Relay cursor connection specification), ordered by ID.
+type connection struct {
+	Edges      []edge        `json:"edges"`
+	Nodes      []interface{} `json:"nodes"`
+	PageInfo   pageInfo      `json:"pageInfo"`
+	TotalCount int64         `json:"totalCount"`
+}
+
+type edge struct {
+	Cursor string      `json:"cursor"`
+	Node   interface{} `json:"node"`
+}
+
+type pageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	},
+})
+
+// connectionType returns the connection and edge types of a list of node.
+func connectionType(node *graphql.Object) *graphql.Object {
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: node.Name() + "Edge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(node)},
+		},
+	})
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: node.Name() + "Connection",
+		Fields: graphql.Fields{
+			"edges":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+			"nodes":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(node)))},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+}
+
+// connectionArgs are the arguments of the fields returning a connection.
+var connectionArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultFirst, Description: "Number of nodes, at most " + strconv.Itoa(maxFirst)},
+	"after": &graphql.ArgumentConfig{Type: graphql.String, Description: "Cursor of the node the page starts after"},
+}
+
+// listArgs are the arguments of the fields listing the rows related to a node.
+var listArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListFirst, Description: "Number of rows, the first by ID, at most " + strconv.Itoa(maxFirst)},
+}
+
+// argFirst returns the first argument of a field, between 0 and maxFirst.
+func argFirst(p graphql.ResolveParams) (int, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 0 || first > maxFirst {
+		return 0, fieldError{apperrors.BadRequest("invalid_first", "first must be between 0 and "+strconv.Itoa(maxFirst))}
+	}
+	return first, nil
+}
+
+// resolveConnection returns the page of the rows of T selected by the first and after
+// arguments.
+func resolveConnection[T any](p graphql.ResolveParams, entity string, id func(T) uint) (interface{}, error) {
+	first, err := argFirst(p)
+	if err != nil {
+		return nil, err
+	}
+	var after uint
+	if cursor, ok := p.Args["after"].(string); ok {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil {
+			return nil, fieldError{apperrors.BadRequest("invalid_cursor", "after is not a cursor of this API")}
+		}
+	}
+
+	// One row more than asked tells whether there is a next page
+	rows, total, err := services.FetchPage[T](p.Context, after, first+1)
+	if err != nil {
+		return nil, resolverError(p.Context, err, entity)
+	}
+	conn := connection{
+		Edges:      []edge{},
+		Nodes:      []interface{}{},
+		TotalCount: total,
+		PageInfo:   pageInfo{HasNextPage: len(rows) > first, HasPreviousPage: after > 0},
+	}
+	if len(rows) > first {
+		rows = rows[:first]
+	}
+	for _, row := range rows {
+		conn.Edges = append(conn.Edges, edge{Cursor: encodeCursor(id(row)), Node: row})
+		conn.Nodes = append(conn.Nodes, row)
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+// Cursors are opaque to clients; they encode the ID of the row they point to.
+const cursorPrefix = "id:"
+
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	digits, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return 0, errors.New("cursor without the " + cursorPrefix + " prefix")
+	}
+	id, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"gin-books-api/apperrors"
+)
+
+// fieldError is an error reported in the errors of a response. Its message is safe to
+// show to clients; the code, and the field violations of a validation error, are given
+// as extensions.
+type fieldError struct {
+	err *apperrors.Error
+}
+
+// Error implements error.
+func (e fieldError) Error() string {
+	return e.err.Message
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e fieldError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.err.Code, "status": e.err.Kind.Status()}
+	if len(e.err.Details) > 0 {
+		details := make([]apperrors.FieldError, len(e.err.Details))
+		for i, detail := range e.err.Details {
+			detail.Field = camelCase(detail.Field)
+			details[i] = detail
+		}
+		extensions["errors"] = details
+	}
+	return extensions
+}
+
+// resolverError converts an error returned by a service for entity, e.g. "book", as
+// utils.ErrorResponse does for the REST API, logging the cause of internal errors.
+func resolverError(ctx context.Context, err error, entity string) error {
+	appErr := apperrors.FromDB(err, entity)
+	if appErr.Kind.Status() >= http.StatusInternalServerError && appErr.Err != nil {
+		slog.ErrorContext(ctx, "GraphQL resolver failed", "entity", entity, "error", appErr.Err)
+	}
+	return fieldError{appErr}
+}
+
+// camelCase converts the JSON field names of the REST API, such as "author_id" or
+// "tags[0].name", into the GraphQL ones.
+func camelCase(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// snakeCase converts a GraphQL field name, such as "authorId", into the JSON one.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
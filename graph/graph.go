@@ -0,0 +1,111 @@
+// Package graph serves the GraphQL API: queries over books, authors, publishers,
+// categories, reviews, loans and users, with cursor connections for lists, and
+// mutations backed by the same services and validation as the REST API. The relations
+// of the rows of a response are loaded in batches, one query per relation and level.
+package graph
+
+import (
+	"context"
+
+	"gin-books-api/apperrors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Request is a GraphQL request, as sent in a POST body.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Do runs a request. Queries deeper than MaxDepth or costlier than MaxComplexity are
+// rejected before anything is resolved, as are mutations when readOnly is set, for
+// requests made with GET.
+func Do(ctx context.Context, req Request, readOnly bool) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	operation := findOperation(doc, req.OperationName)
+	if operation == nil {
+		return errorResult(apperrors.BadRequest("unknown_operation", "The request names no operation of the document"))
+	}
+	if readOnly && operation.Operation != ast.OperationTypeQuery {
+		return errorResult(apperrors.BadRequest("mutation_over_get", "Mutations must be sent with POST"))
+	}
+	if err := checkLimits(doc, operation, req.Variables); err != nil {
+		return errorResult(err)
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx),
+	})
+	for i := range result.Errors {
+		if result.Errors[i].Extensions == nil {
+			result.Errors[i].Extensions = extensionsOf(result.Errors[i])
+		}
+	}
+	return result
+}
+
+// findOperation returns the operation of doc to run: the one named, or the only one.
+func findOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil
+			}
+			found = operation
+		} else if operation.Name != nil && operation.Name.Value == name {
+			return operation
+		}
+	}
+	return found
+}
+
+func errorResult(err *apperrors.Error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+		Message:    err.Message,
+		Extensions: fieldError{err}.Extensions(),
+	}}}
+}
+
+// extensionsOf returns the extensions of the error a resolver returned. The executor
+// keeps them for errors returned by resolvers, but not for those returned by thunks,
+// which it wraps several times over.
+func extensionsOf(err error) map[string]interface{} {
+	for err != nil {
+		switch e := err.(type) {
+		case gqlerrors.ExtendedError:
+			return e.Extensions()
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return nil
+		}
+	}
+	return nil
+}
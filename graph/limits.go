@@ -0,0 +1,172 @@
+package graph
+
+import (
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// MaxDepth is the deepest nesting of fields a query may select. A connection takes
+	// three levels (books, edges, node), or two through nodes.
+	MaxDepth = 12
+
+	// MaxComplexity bounds the estimated cost of a query: each field costs one, and the
+	// fields under a list cost as many times as the list may hold items, which its first
+	// argument bounds.
+	MaxComplexity = 5000
+
+	// listComplexity is the number of items estimated for lists without a first argument.
+	listComplexity = 10
+)
+
+// limits computes the depth and complexity of an operation.
+type limits struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkLimits rejects operations deeper than MaxDepth or costlier than MaxComplexity.
+// The fields of introspection, such as __schema, are not counted.
+func checkLimits(doc *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) *apperrors.Error {
+	l := limits{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			l.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	depth, complexity := l.selectionSet(operation.SelectionSet, root, 1, map[string]bool{})
+	if depth > MaxDepth {
+		return apperrors.BadRequest("query_too_deep",
+			"The query is nested "+strconv.Itoa(depth)+" levels deep, more than the limit of "+strconv.Itoa(MaxDepth))
+	}
+	if complexity > MaxComplexity {
+		return apperrors.BadRequest("query_too_complex",
+			"The query has a complexity of "+strconv.Itoa(complexity)+", more than the limit of "+strconv.Itoa(MaxComplexity))
+	}
+	return nil
+}
+
+// selectionSet returns the depth and complexity of the fields selected on parent, at
+// the given depth. visiting holds the fragments being expanded, which validation
+// keeps from spreading themselves.
+func (l limits) selectionSet(set *ast.SelectionSet, parent *graphql.Object, depth int, visiting map[string]bool) (int, int) {
+	if set == nil || parent == nil {
+		return 0, 0
+	}
+
+	maxDepth, complexity := 0, 0
+	add := func(d, c int) {
+		maxDepth = max(maxDepth, d)
+		complexity += c
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			field := parent.Fields()[name]
+			if strings.HasPrefix(name, "__") || field == nil {
+				continue
+			}
+			d, c := depth, 0
+			if selection.SelectionSet != nil {
+				d, c = l.selectionSet(selection.SelectionSet, objectOf(field.Type), depth+1, visiting)
+			}
+			add(d, 1+l.multiplier(selection, field, parent)*c)
+		case *ast.InlineFragment:
+			typ := parent
+			if selection.TypeCondition != nil {
+				typ, _ = schema.Type(selection.TypeCondition.Name.Value).(*graphql.Object)
+			}
+			add(l.selectionSet(selection.SelectionSet, typ, depth, visiting))
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := l.fragments[name]
+			if fragment == nil || visiting[name] {
+				continue
+			}
+			typ, _ := schema.Type(fragment.TypeCondition.Name.Value).(*graphql.Object)
+			visiting[name] = true
+			add(l.selectionSet(fragment.SelectionSet, typ, depth, visiting))
+			delete(visiting, name)
+		}
+	}
+	return maxDepth, complexity
+}
+
+// multiplier returns the number of times the fields under field are estimated to be
+// resolved: the first argument of a connection or a list of related rows, an estimate
+// for other lists, or one.
+func (l limits) multiplier(selection *ast.Field, field *graphql.FieldDefinition, parent *graphql.Object) int {
+	for _, arg := range field.Args {
+		if arg.Name() == "first" {
+			return l.first(selection, arg)
+		}
+	}
+	// The edges and nodes of a connection hold the page its field was counted for
+	if strings.HasSuffix(parent.Name(), "Connection") {
+		return 1
+	}
+	if _, ok := unwrap(field.Type, false).(*graphql.List); ok {
+		return listComplexity
+	}
+	return 1
+}
+
+// first returns the first argument of a field, or its default value.
+func (l limits) first(selection *ast.Field, arg *graphql.Argument) int {
+	for _, given := range selection.Arguments {
+		if given.Name.Value != "first" {
+			continue
+		}
+		switch value := given.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return max(n, 0)
+			}
+		case *ast.Variable:
+			switch n := l.variables[value.Name.Value].(type) {
+			case float64:
+				return max(int(n), 0)
+			case int:
+				return max(n, 0)
+			}
+		}
+	}
+	if n, ok := arg.DefaultValue.(int); ok {
+		return n
+	}
+	return defaultFirst
+}
+
+// objectOf returns the object type of the items of a field, or nil for scalars.
+func objectOf(typ graphql.Type) *graphql.Object {
+	object, _ := unwrap(typ, true).(*graphql.Object)
+	return object
+}
+
+// unwrap strips the non-null wrappers of typ, and also the lists if lists is set.
+func unwrap(typ graphql.Type, lists bool) graphql.Type {
+	for {
+		switch t := typ.(type) {
+		case *graphql.NonNull:
+			typ = t.OfType
+		case *graphql.List:
+			if !lists {
+				return typ
+			}
+			typ = t.OfType
+		default:
+			return typ
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// measure returns the depth and complexity of the only operation of query.
+func measure(t *testing.T, query string, variables map[string]interface{}) (int, int) {
+	t.Helper()
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		t.Fatalf("parse %q: %v", query, err)
+	}
+	l := limits{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			l.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	operation := findOperation(doc, "")
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	return l.selectionSet(operation.SelectionSet, root, 1, map[string]bool{})
+}
+
+func TestComplexityCountsListsByTheirFirstArgument(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		depth     int
+		cost      int
+	}{
+		{"connection", `{ books(first: 5) { nodes { title } } }`, nil, 3, 1 + 5*(1+1)},
+		{"default page", `{ books { nodes { title } } }`, nil, 3, 1 + defaultFirst*(1+1)},
+		{"nested list", `{ book(id: "1") { reviews(first: 3) { comment } } }`, nil, 3, 1 + 1 + 3*1},
+		{"nested default", `{ book(id: "1") { reviews { comment } } }`, nil, 3, 1 + 1 + defaultListFirst*1},
+		{"variable", `query($n: Int) { authors(first: $n) { edges { node { name } } } }`, map[string]interface{}{"n": float64(7)}, 4, 1 + 7*(1+1+1)},
+		{"fragment", `{ book(id: "1") { ...B } } fragment B on Book { title author { name } }`, nil, 3, 1 + 1 + 1 + 1},
+		{"introspection", `{ __schema { types { name } } book(id: "1") { title } }`, nil, 2, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			depth, cost := measure(t, test.query, test.variables)
+			if depth != test.depth || cost != test.cost {
+				t.Errorf("depth, complexity = %d, %d, want %d, %d", depth, cost, test.depth, test.cost)
+			}
+		})
+	}
+}
+
+func TestCheckLimitsRefusesCostlyQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{"deep relations", `{ publishers(first: 100) { nodes { books { reviews { user { loans { book { title } } } } } } } }`, "query_too_complex"},
+		{"large nested pages", `{ authors(first: 50) { nodes { books(first: 100) { title } } } }`, "query_too_complex"},
+		{"too deep", `{ book(id: "1") { category { ` + strings.Repeat("parent { ", 12) + "name" + strings.Repeat(" }", 15), "query_too_deep"},
+		{"small", `{ authors(first: 10) { nodes { name books(first: 5) { title } } } }`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: test.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := ""
+			if err := checkLimits(doc, findOperation(doc, ""), nil); err != nil {
+				code = err.Code
+			}
+			if code != test.code {
+				t.Errorf("checkLimits = %q, want %q", code, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"gin-books-api/models"
+	"gin-books-api/services"
+)
+
+// loader batches the lookups of a relation made while a request is resolved. Resolvers
+// queue their key and return a thunk; the executor runs the thunks once every field at
+// the same depth has been resolved, and the first of them fetches all queued keys in one
+// query. Results are kept for the rest of the request.
+type loader[V any] struct {
+	fetch func(ctx context.Context, keys []uint) (map[uint]V, error)
+
+	mu      sync.Mutex
+	queued  []uint
+	loaded  map[uint]bool
+	results map[uint]V
+	errs    map[uint]error
+}
+
+func newLoader[V any](fetch func(ctx context.Context, keys []uint) (map[uint]V, error)) *loader[V] {
+	return &loader[V]{fetch: fetch, loaded: map[uint]bool{}, results: map[uint]V{}, errs: map[uint]error{}}
+}
+
+// load queues key and returns a thunk of its value, which reports false if there is none.
+func (l *loader[V]) load(ctx context.Context, key uint) func() (V, bool, error) {
+	l.mu.Lock()
+	if !l.loaded[key] {
+		l.queued = append(l.queued, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, bool, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.loaded[key] {
+			l.dispatch(ctx)
+		}
+		value, ok := l.results[key]
+		return value, ok, l.errs[key]
+	}
+}
+
+// dispatch fetches the queued keys. The caller holds l.mu.
+func (l *loader[V]) dispatch(ctx context.Context) {
+	keys := make([]uint, 0, len(l.queued))
+	for _, key := range l.queued {
+		if !l.loaded[key] {
+			l.loaded[key] = true
+			keys = append(keys, key)
+		}
+	}
+	l.queued = nil
+	if len(keys) == 0 {
+		return
+	}
+
+	results, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+		} else if value, ok := results[key]; ok {
+			l.results[key] = value
+		}
+	}
+}
+
+// byID fetches rows of T by their IDs.
+func byID[T any](id func(T) uint) func(context.Context, []uint) (map[uint]T, error) {
+	return func(ctx context.Context, keys []uint) (map[uint]T, error) {
+		rows, err := services.FetchByIDs[T](ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		results := make(map[uint]T, len(rows))
+		for _, row := range rows {
+			results[id(row)] = row
+		}
+		return results, nil
+	}
+}
+
+// byColumn fetches the first rows of T, up to limit for each key, referencing the keys
+// through column, grouped by key.
+func byColumn[T any](column string, key func(T) uint) func(limit int) func(context.Context, []uint) (map[uint][]T, error) {
+	return func(limit int) func(context.Context, []uint) (map[uint][]T, error) {
+		return func(ctx context.Context, keys []uint) (map[uint][]T, error) {
+			rows, err := services.FetchByColumn[T](ctx, column, keys, limit)
+			if err != nil {
+				return nil, err
+			}
+			results := make(map[uint][]T, len(keys))
+			for _, row := range rows {
+				results[key(row)] = append(results[key(row)], row)
+			}
+			return results, nil
+		}
+	}
+}
+
+// relation batches the lookups of a one-to-many relation, such as the books of authors,
+// with a loader for each number of rows asked for: lookups of the same page size are
+// fetched together, each key limited to that many rows.
+type relation[V any] struct {
+	fetch func(limit int) func(context.Context, []uint) (map[uint][]V, error)
+
+	mu      sync.Mutex
+	loaders map[int]*loader[[]V]
+}
+
+func newRelation[V any](fetch func(limit int) func(context.Context, []uint) (map[uint][]V, error)) *relation[V] {
+	return &relation[V]{fetch: fetch, loaders: map[int]*loader[[]V]{}}
+}
+
+// first returns the loader of the first limit rows of each key.
+func (r *relation[V]) first(limit int) *loader[[]V] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.loaders[limit]
+	if !ok {
+		l = newLoader(r.fetch(limit))
+		r.loaders[limit] = l
+	}
+	return l
+}
+
+// loaders are the loaders of a request, one for each relation.
+type loaders struct {
+	books      *loader[models.Book]
+	authors    *loader[models.Author]
+	publishers *loader[models.Publisher]
+	categories *loader[models.Category]
+	users      *loader[models.User]
+	reviews    *loader[models.Review]
+	loans      *loader[models.BorrowedBook]
+	ratings    *loader[services.BookRating]
+
+	booksByAuthor    *relation[models.Book]
+	booksByPublisher *relation[models.Book]
+	booksByCategory  *relation[models.Book]
+	subcategories    *relation[models.Category]
+	reviewsByBook    *relation[models.Review]
+	reviewsByUser    *relation[models.Review]
+	loansByBook      *relation[models.BorrowedBook]
+	loansByUser      *relation[models.BorrowedBook]
+}
+
+func newLoaders() *loaders {
+	return &loaders{
+		books:      newLoader(byID(func(b models.Book) uint { return b.ID })),
+		authors:    newLoader(byID(func(a models.Author) uint { return a.ID })),
+		publishers: newLoader(byID(func(p models.Publisher) uint { return p.ID })),
+		categories: newLoader(byID(func(c models.Category) uint { return c.ID })),
+		users:      newLoader(byID(func(u models.User) uint { return u.ID })),
+		reviews:    newLoader(byID(func(r models.Review) uint { return r.ID })),
+		loans:      newLoader(byID(func(l models.BorrowedBook) uint { return l.ID })),
+		ratings:    newLoader(services.FetchBookRatings),
+
+		booksByAuthor:    newRelation(byColumn("author_id", func(b models.Book) uint { return *b.AuthorID })),
+		booksByPublisher: newRelation(byColumn("publisher_id", func(b models.Book) uint { return *b.PublisherID })),
+		booksByCategory:  newRelation(byColumn("category_id", func(b models.Book) uint { return *b.CategoryID })),
+		subcategories:    newRelation(byColumn("parent_id", func(c models.Category) uint { return *c.ParentID })),
+		reviewsByBook:    newRelation(byColumn("book_id", func(r models.Review) uint { return r.BookID })),
+		reviewsByUser:    newRelation(byColumn("user_id", func(r models.Review) uint { return r.UserID })),
+		loansByBook:      newRelation(byColumn("book_id", func(l models.BorrowedBook) uint { return l.BookID })),
+		loansByUser:      newRelation(byColumn("user_id", func(l models.BorrowedBook) uint { return l.UserID })),
+	}
+}
+
+type loadersKey struct{}
+
+// withLoaders returns a copy of ctx carrying fresh loaders for a request.
+func withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, newLoaders())
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLoaderBatchesQueuedKeys(t *testing.T) {
+	var fetched [][]uint
+	l := newLoader(func(ctx context.Context, keys []uint) (map[uint]string, error) {
+		fetched = append(fetched, append([]uint(nil), keys...))
+		results := map[uint]string{}
+		for _, key := range keys {
+			if key != 3 {
+				results[key] = "row"
+			}
+		}
+		return results, nil
+	})
+	ctx := context.Background()
+
+	thunks := []func() (string, bool, error){l.load(ctx, 1), l.load(ctx, 2), l.load(ctx, 3), l.load(ctx, 1)}
+	for i, thunk := range thunks {
+		value, ok, err := thunk()
+		if want := i != 2; ok != want || err != nil || (ok && value != "row") {
+			t.Errorf("thunk %d = %q, %v, %v, want found %v", i, value, ok, err, want)
+		}
+	}
+	if len(fetched) != 1 {
+		t.Fatalf("fetches = %v, want one for every queued key", fetched)
+	}
+	sort.Slice(fetched[0], func(i, j int) bool { return fetched[0][i] < fetched[0][j] })
+	if !reflect.DeepEqual(fetched[0], []uint{1, 2, 3}) {
+		t.Errorf("keys fetched = %v, want [1 2 3]", fetched[0])
+	}
+
+	// Keys already loaded, found or not, are not fetched again
+	l.load(ctx, 2)()
+	l.load(ctx, 3)()
+	if len(fetched) != 1 {
+		t.Errorf("fetches = %v after loading keys again, want no more", fetched)
+	}
+}
+
+func TestLoaderReportsTheErrorOfItsBatch(t *testing.T) {
+	failure := errors.New("database down")
+	l := newLoader(func(ctx context.Context, keys []uint) (map[uint]int, error) {
+		return nil, failure
+	})
+	ctx := context.Background()
+	first, second := l.load(ctx, 1), l.load(ctx, 2)
+	for _, thunk := range []func() (int, bool, error){first, second} {
+		if _, ok, err := thunk(); ok || !errors.Is(err, failure) {
+			t.Errorf("thunk = %v, %v, want the error of the fetch", ok, err)
+		}
+	}
+}
+
+func TestRelationLimitsEachKey(t *testing.T) {
+	var limits []int
+	r := newRelation(func(limit int) func(context.Context, []uint) (map[uint][]int, error) {
+		return func(ctx context.Context, keys []uint) (map[uint][]int, error) {
+			limits = append(limits, limit)
+			results := map[uint][]int{}
+			for _, key := range keys {
+				for i := 0; i < limit; i++ {
+					results[key] = append(results[key], i)
+				}
+			}
+			return results, nil
+		}
+	})
+	ctx := context.Background()
+
+	if r.first(5) != r.first(5) {
+		t.Error("lookups of the same page size have loaders of their own")
+	}
+	small, large := r.first(2).load(ctx, 1), r.first(5).load(ctx, 1)
+	if rows, _, _ := small(); len(rows) != 2 {
+		t.Errorf("rows of first 2 = %v", rows)
+	}
+	if rows, _, _ := large(); len(rows) != 5 {
+		t.Errorf("rows of first 5 = %v", rows)
+	}
+	sort.Ints(limits)
+	if !reflect.DeepEqual(limits, []int{2, 5}) {
+		t.Errorf("limits fetched = %v, want [2 5]", limits)
+	}
+}
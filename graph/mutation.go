@@ -0,0 +1,334 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/dto"
+	"gin-books-api/models"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/graphql-go/graphql"
+)
+
+// resource is an entity written through the mutations, backed by its services.
+type resource[M any] struct {
+	name   string // Entity name, e.g. "book"
+	input  graphql.InputObjectConfigFieldMap
+	fetch  func(ctx context.Context, cacheKey string, id int) (*M, error)
+	create func(ctx context.Context, model *M) error
+	patch  func(ctx context.Context, id int, model *M, columns []string) error
+	delete func(p graphql.ResolveParams, id int) error
+}
+
+// addMutations adds the create, update and delete mutations of a resource, such as
+// createBook, updateBook and deleteBook. Inputs are validated by the request type of
+// the REST API, R; updates change only the fields their input sets, as a merge patch
+// does.
+func addMutations[M any, R any, PR interface {
+	*R
+	dto.Request
+	Model() M
+}](fields graphql.Fields, output *graphql.Object, r resource[M], newRequest func(M) R) {
+	name := strings.ToUpper(r.name[:1]) + r.name[1:]
+	input := graphql.NewInputObject(graphql.InputObjectConfig{Name: name + "Input", Fields: r.input})
+	inputArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(input)}
+	idArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}
+
+	fields["create"+name] = &graphql.Field{
+		Type: graphql.NewNonNull(output),
+		Args: graphql.FieldConfigArgument{"input": inputArg},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			var req R
+			if _, err := decodeInput(p.Context, p.Args["input"].(map[string]interface{}), PR(&req)); err != nil {
+				return nil, resolverError(p.Context, err, r.name)
+			}
+			model := PR(&req).Model()
+			if err := r.create(p.Context, &model); err != nil {
+				return nil, resolverError(p.Context, err, r.name)
+			}
+			return model, nil
+		},
+	}
+
+	fields["update"+name] = &graphql.Field{
+		Type: graphql.NewNonNull(output),
+		Args: graphql.FieldConfigArgument{"id": idArg, "input": inputArg},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id, err := argID(p)
+			if err != nil {
+				return nil, err
+			}
+			current, err := r.fetch(p.Context, r.name+"_"+strconv.Itoa(int(id)), int(id))
+			if err != nil {
+				return nil, resolverError(p.Context, err, r.name)
+			}
+			req := newRequest(*current)
+			columns, err := decodeInput(p.Context, p.Args["input"].(map[string]interface{}), PR(&req))
+			if err != nil {
+				return nil, resolverError(p.Context, err, r.name)
+			}
+			model := PR(&req).Model()
+			if err := r.patch(p.Context, int(id), &model, columns); err != nil {
+				return nil, resolverError(p.Context, err, r.name)
+			}
+			return model, nil
+		},
+	}
+
+	deleteArgs := graphql.FieldConfigArgument{"id": idArg}
+	if r.name == "category" {
+		deleteArgs["strategy"] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(categoryDeleteStrategy)}
+	}
+	fields["delete"+name] = &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.ID),
+		Description: "Returns the ID of the deleted " + strings.ReplaceAll(r.name, "_", " "),
+		Args:        deleteArgs,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id, err := argID(p)
+			if err != nil {
+				return nil, err
+			}
+			if err := r.delete(p, int(id)); err != nil {
+				return nil, resolverError(p.Context, err, r.name)
+			}
+			return id, nil
+		},
+	}
+}
+
+var categoryDeleteStrategy = graphql.NewEnum(graphql.EnumConfig{
+	Name: "CategoryDeleteStrategy",
+	Values: graphql.EnumValueConfigMap{
+		"REPARENT": &graphql.EnumValueConfig{Value: services.CategoryDeleteReparent, Description: "Move subcategories and books to the parent category"},
+		"REFUSE":   &graphql.EnumValueConfig{Value: services.CategoryDeleteRefuse, Description: "Only delete categories without subcategories or books"},
+	},
+})
+
+func newMutation(bookType, authorType, publisherType, categoryType, reviewType, loanType, userType *graphql.Object) *graphql.Object {
+	fields := graphql.Fields{}
+
+	addMutations(fields, bookType, resource[models.Book]{
+		name: "book",
+		input: graphql.InputObjectConfigFieldMap{
+			"title":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"description":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"publishedYear": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"authorId":      &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"publisherId":   &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"categoryId":    &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"workId":        &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"isbn":          &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"language":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"format":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"availability":  &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+			"tags":          &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Names of the tags; unknown tags are created"},
+			"subjects":      &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.ID)), Description: "IDs of the subject headings"},
+		},
+		fetch:  services.FetchBookFromDB,
+		create: services.CreateBook,
+		patch:  services.PatchBook,
+		delete: func(p graphql.ResolveParams, id int) error { return services.DeleteBook(p.Context, id) },
+	}, dto.NewBookRequest)
+
+	addMutations(fields, authorType, resource[models.Author]{
+		name: "author",
+		input: graphql.InputObjectConfigFieldMap{
+			"name":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"bio":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+		fetch:  services.FetchAuthorFromDB,
+		create: services.CreateAuthor,
+		patch:  services.PatchAuthor,
+		delete: func(p graphql.ResolveParams, id int) error { return services.DeleteAuthor(p.Context, id) },
+	}, dto.NewAuthorRequest)
+
+	addMutations(fields, publisherType, resource[models.Publisher]{
+		name: "publisher",
+		input: graphql.InputObjectConfigFieldMap{
+			"name":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"address": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"phone":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+		fetch:  services.FetchPublisherFromDB,
+		create: services.CreatePublisher,
+		patch:  services.PatchPublisher,
+		delete: func(p graphql.ResolveParams, id int) error { return services.DeletePublisher(p.Context, id) },
+	}, dto.NewPublisherRequest)
+
+	addMutations(fields, categoryType, resource[models.Category]{
+		name: "category",
+		input: graphql.InputObjectConfigFieldMap{
+			"name":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"parentId": &graphql.InputObjectFieldConfig{Type: graphql.ID},
+		},
+		fetch:  services.FetchCategoryFromDB,
+		create: services.CreateCategory,
+		patch:  services.PatchCategory,
+		delete: func(p graphql.ResolveParams, id int) error {
+			return services.DeleteCategory(p.Context, id, p.Args["strategy"].(string))
+		},
+	}, dto.NewCategoryRequest)
+
+	addMutations(fields, reviewType, resource[models.Review]{
+		name: "review",
+		input: graphql.InputObjectConfigFieldMap{
+			"bookId":  &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"userId":  &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"rating":  &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"comment": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+		fetch:  services.FetchReviewFromDB,
+		create: services.CreateReview,
+		patch:  services.PatchReview,
+		delete: func(p graphql.ResolveParams, id int) error { return services.DeleteReview(p.Context, id) },
+	}, dto.NewReviewRequest)
+
+	addMutations(fields, userType, resource[models.User]{
+		name: "user",
+		input: graphql.InputObjectConfigFieldMap{
+			"username": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"password": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"active":   &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		},
+		fetch:  services.FetchUserFromDB,
+		create: services.CreateUser,
+		patch:  services.PatchUser,
+		delete: func(p graphql.ResolveParams, id int) error { return services.DeleteUser(p.Context, id) },
+	}, dto.NewUserRequest)
+
+	addLoanMutations(fields, loanType)
+
+	return graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: fields})
+}
+
+// addLoanMutations adds borrowBook and returnBook. Loans are not updated: a book is
+// lent, then returned.
+func addLoanMutations(fields graphql.Fields, loanType *graphql.Object) {
+	input := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "LoanInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"bookId":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+			"userId":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+			"dueDate": &graphql.InputObjectFieldConfig{Type: graphql.DateTime, Description: "Defaults to the loan period from now"},
+		},
+	})
+
+	fields["borrowBook"] = &graphql.Field{
+		Type: graphql.NewNonNull(loanType),
+		Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(input)}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			var req dto.LoanRequest
+			if _, err := decodeInput(p.Context, p.Args["input"].(map[string]interface{}), &req); err != nil {
+				return nil, resolverError(p.Context, err, "loan")
+			}
+			loan := req.Model()
+			if err := services.BorrowBook(p.Context, &loan); err != nil {
+				return nil, resolverError(p.Context, err, "loan")
+			}
+			return loan, nil
+		},
+	}
+
+	fields["returnBook"] = &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.ID),
+		Description: "Ends a loan and returns its ID",
+		Args:        graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id, err := argID(p)
+			if err != nil {
+				return nil, err
+			}
+			if err := services.ReturnBook(p.Context, int(id)); err != nil {
+				return nil, resolverError(p.Context, err, "loan")
+			}
+			return id, nil
+		},
+	}
+}
+
+// decodeInput sets the fields of req, which holds the current state of the record for
+// updates, from the input of a mutation, then validates req as bindRequest validates a
+// REST body and checks that the rows it references exist. It returns the JSON keys the
+// input set; these match the column names.
+func decodeInput(ctx context.Context, input map[string]interface{}, req dto.Request) ([]string, error) {
+	current, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(current, &body); err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0, len(input))
+	for name, value := range input {
+		key := snakeCase(name)
+		body[key] = inputValue(name, value)
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	value := reflect.ValueOf(req).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.NewDecoder(bytes.NewReader(encoded)).Decode(req); err != nil {
+		if details := utils.ValidationErrors(err); details != nil {
+			return nil, apperrors.Validation(details...)
+		}
+		return nil, apperrors.BadRequest("malformed_input", "Invalid input")
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return nil, apperrors.Validation(utils.ValidationErrors(err)...)
+	}
+	if err := services.CheckReferences(ctx, req); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// inputValue converts the value of an input field into its JSON form in the REST API:
+// IDs, which GraphQL passes as strings, become numbers, and tags and subjects become
+// objects.
+func inputValue(name string, value interface{}) interface{} {
+	switch {
+	case name == "tags":
+		var tags []map[string]interface{}
+		for _, tag := range value.([]interface{}) {
+			tags = append(tags, map[string]interface{}{"name": tag})
+		}
+		return tags
+	case name == "subjects":
+		var subjects []map[string]interface{}
+		for _, id := range value.([]interface{}) {
+			subjects = append(subjects, map[string]interface{}{"id": inputID(id)})
+		}
+		return subjects
+	case strings.HasSuffix(name, "Id"):
+		return inputID(value)
+	}
+	return value
+}
+
+// inputID returns the number an ID stands for. Other IDs are left as they are, to be
+// reported as invalid when the request is decoded.
+func inputID(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return id
+		}
+	}
+	return value
+}
@@ -0,0 +1,335 @@
+package graph
+
+import (
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/models"
+	"gin-books-api/services"
+
+	"github.com/graphql-go/graphql"
+)
+
+// schema is the GraphQL schema of the API.
+var schema = newSchema()
+
+func newSchema() graphql.Schema {
+	var bookType, authorType, publisherType, categoryType, reviewType, loanType, userType *graphql.Object
+
+	ratingType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Rating",
+		Description: "The reviews of a book, summarised",
+		Fields: graphql.Fields{
+			"count":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveRating(func(r services.BookRating) interface{} { return r.Count })},
+			"average": &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Resolve: resolveRating(func(r services.BookRating) interface{} { return r.Average })},
+		},
+	})
+
+	bookType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Book",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"publishedYear": &graphql.Field{Type: graphql.Int, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if year := p.Source.(models.Book).PublishedYear; year != 0 {
+						return year, nil
+					}
+					return nil, nil
+				}},
+				"isbn":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"language":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"format":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"availability": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Book).CreatedAt, nil
+				}},
+				"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Book).UpdatedAt, nil
+				}},
+				"author": &graphql.Field{Type: authorType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveOne(p, loadersFrom(p.Context).authors, p.Source.(models.Book).AuthorID, "author")
+				}},
+				"publisher": &graphql.Field{Type: publisherType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveOne(p, loadersFrom(p.Context).publishers, p.Source.(models.Book).PublisherID, "publisher")
+				}},
+				"category": &graphql.Field{Type: categoryType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveOne(p, loadersFrom(p.Context).categories, p.Source.(models.Book).CategoryID, "category")
+				}},
+				"rating": &graphql.Field{Type: graphql.NewNonNull(ratingType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					book := p.Source.(models.Book)
+					thunk := loadersFrom(p.Context).ratings.load(p.Context, book.ID)
+					return func() (interface{}, error) {
+						rating, _, err := thunk()
+						if err != nil {
+							return nil, resolverError(p.Context, err, "review")
+						}
+						return rating, nil
+					}, nil
+				}},
+				"reviews": &graphql.Field{Type: listOf(reviewType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).reviewsByBook, p.Source.(models.Book).ID, "review")
+				}},
+				"loans": &graphql.Field{Type: listOf(loanType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).loansByBook, p.Source.(models.Book).ID, "loan")
+				}},
+			}
+		}),
+	})
+
+	authorType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Author",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":    &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"bio":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"email": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"books": &graphql.Field{Type: listOf(bookType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).booksByAuthor, p.Source.(models.Author).ID, "book")
+				}},
+			}
+		}),
+	})
+
+	publisherType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Publisher",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"address": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"phone":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"books": &graphql.Field{Type: listOf(bookType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).booksByPublisher, p.Source.(models.Publisher).ID, "book")
+				}},
+			}
+		}),
+	})
+
+	categoryType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"parent": &graphql.Field{Type: categoryType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveOne(p, loadersFrom(p.Context).categories, p.Source.(models.Category).ParentID, "category")
+				}},
+				"children": &graphql.Field{Type: listOf(categoryType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).subcategories, p.Source.(models.Category).ID, "category")
+				}},
+				"books": &graphql.Field{
+					Type:        listOf(bookType),
+					Description: "The books filed directly under the category, not under its subcategories",
+					Args:        listArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return resolveMany(p, loadersFrom(p.Context).booksByCategory, p.Source.(models.Category).ID, "book")
+					},
+				},
+			}
+		}),
+	})
+
+	reviewType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Review",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"rating":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"comment": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"book": &graphql.Field{Type: bookType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Source.(models.Review).BookID
+					return resolveOne(p, loadersFrom(p.Context).books, &id, "book")
+				}},
+				"user": &graphql.Field{Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Source.(models.Review).UserID
+					return resolveOne(p, loadersFrom(p.Context).users, &id, "user")
+				}},
+			}
+		}),
+	})
+
+	loanType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Loan",
+		Description: "A book borrowed by a user",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"borrowedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.BorrowedBook).BorrowedAt, nil
+				}},
+				"dueDate": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.BorrowedBook).DueDate, nil
+				}},
+				"book": &graphql.Field{Type: bookType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Source.(models.BorrowedBook).BookID
+					return resolveOne(p, loadersFrom(p.Context).books, &id, "book")
+				}},
+				"user": &graphql.Field{Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Source.(models.BorrowedBook).UserID
+					return resolveOne(p, loadersFrom(p.Context).users, &id, "user")
+				}},
+			}
+		}),
+	})
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"email":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"active":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"reviews": &graphql.Field{Type: listOf(reviewType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).reviewsByUser, p.Source.(models.User).ID, "review")
+				}},
+				"loans": &graphql.Field{Type: listOf(loanType), Args: listArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveMany(p, loadersFrom(p.Context).loansByUser, p.Source.(models.User).ID, "loan")
+				}},
+			}
+		}),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"book": nodeField(bookType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).books, &id, "book")
+			}),
+			"books": connectionField(bookType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "book", func(b models.Book) uint { return b.ID })
+			}),
+			"author": nodeField(authorType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).authors, &id, "author")
+			}),
+			"authors": connectionField(authorType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "author", func(a models.Author) uint { return a.ID })
+			}),
+			"publisher": nodeField(publisherType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).publishers, &id, "publisher")
+			}),
+			"publishers": connectionField(publisherType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "publisher", func(p models.Publisher) uint { return p.ID })
+			}),
+			"category": nodeField(categoryType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).categories, &id, "category")
+			}),
+			"categories": connectionField(categoryType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "category", func(c models.Category) uint { return c.ID })
+			}),
+			"review": nodeField(reviewType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).reviews, &id, "review")
+			}),
+			"reviews": connectionField(reviewType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "review", func(r models.Review) uint { return r.ID })
+			}),
+			"loan": nodeField(loanType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).loans, &id, "loan")
+			}),
+			"loans": connectionField(loanType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "loan", func(l models.BorrowedBook) uint { return l.ID })
+			}),
+			"user": nodeField(userType, func(p graphql.ResolveParams, id uint) (interface{}, error) {
+				return resolveOne(p, loadersFrom(p.Context).users, &id, "user")
+			}),
+			"users": connectionField(userType, func(p graphql.ResolveParams) (interface{}, error) {
+				return resolveConnection(p, "user", func(u models.User) uint { return u.ID })
+			}),
+		},
+	})
+
+	s, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: newMutation(bookType, authorType, publisherType, categoryType, reviewType, loanType, userType),
+	})
+	if err != nil {
+		panic("graph: invalid schema: " + err.Error())
+	}
+	return s
+}
+
+// listOf is the type of a list of node, which is never null and holds no null.
+func listOf(node *graphql.Object) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(node)))
+}
+
+// nodeField returns the field looking up a node by ID, which is null if there is none.
+func nodeField(node *graphql.Object, resolve func(p graphql.ResolveParams, id uint) (interface{}, error)) *graphql.Field {
+	return &graphql.Field{
+		Type: node,
+		Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id, err := argID(p)
+			if err != nil {
+				return nil, err
+			}
+			return resolve(p, id)
+		},
+	}
+}
+
+// connectionField returns the field listing the nodes of a type a page at a time.
+func connectionField(node *graphql.Object, resolve graphql.FieldResolveFn) *graphql.Field {
+	return &graphql.Field{Type: graphql.NewNonNull(connectionType(node)), Args: connectionArgs, Resolve: resolve}
+}
+
+// argID returns the id argument of a field.
+func argID(p graphql.ResolveParams) (uint, error) {
+	id, err := strconv.ParseUint(p.Args["id"].(string), 10, 64)
+	if err != nil || id == 0 {
+		return 0, fieldError{apperrors.BadRequest("invalid_id", "id must be a positive integer")}
+	}
+	return uint(id), nil
+}
+
+// resolveOne resolves a relation to the row with the given ID, batched with the other
+// lookups of l. A nil ID, or one without a row, resolves to null.
+func resolveOne[V any](p graphql.ResolveParams, l *loader[V], id *uint, entity string) (interface{}, error) {
+	if id == nil {
+		return nil, nil
+	}
+	thunk := l.load(p.Context, *id)
+	return func() (interface{}, error) {
+		value, ok, err := thunk()
+		if err != nil {
+			return nil, resolverError(p.Context, err, entity)
+		}
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	}, nil
+}
+
+// resolveMany resolves a relation to the rows referencing the given ID, up to the first
+// argument, batched with the other lookups of r.
+func resolveMany[V any](p graphql.ResolveParams, r *relation[V], id uint, entity string) (interface{}, error) {
+	first, err := argFirst(p)
+	if err != nil {
+		return nil, err
+	}
+	if first == 0 {
+		return []V{}, nil
+	}
+	thunk := r.first(first).load(p.Context, id)
+	return func() (interface{}, error) {
+		rows, _, err := thunk()
+		if err != nil {
+			return nil, resolverError(p.Context, err, entity)
+		}
+		if rows == nil {
+			rows = []V{}
+		}
+		return rows, nil
+	}, nil
+}
+
+// resolveRating resolves a field of a Rating. Books without reviews have a zero rating.
+func resolveRating(field func(services.BookRating) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return field(p.Source.(services.BookRating)), nil
+	}
+}
@@ -0,0 +1,289 @@
+package grpcserver
+
+import (
+	"context"
+
+	"gin-books-api/cache"
+	"gin-books-api/models"
+	libraryv1 "gin-books-api/proto/library/v1"
+	"gin-books-api/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// catalogueServer implements CatalogueService over the services of the REST API.
+type catalogueServer struct {
+	libraryv1.UnimplementedCatalogueServiceServer
+}
+
+func (catalogueServer) GetBook(ctx context.Context, req *libraryv1.GetBookRequest) (*libraryv1.Book, error) {
+	book, err := get(ctx, "book", req.GetId(), services.FetchBookFromDB)
+	if err != nil {
+		return nil, err
+	}
+	return bookMessage(*book), nil
+}
+
+func (catalogueServer) ListBooks(req *libraryv1.ListBooksRequest, stream grpc.ServerStreamingServer[libraryv1.Book]) error {
+	if req.GetPage() == 0 && req.GetPageSize() == 0 && req.GetSeriesId() == 0 {
+		return list(stream, "book", func(b models.Book) uint { return b.ID }, bookMessage)
+	}
+
+	// The books GET /api/v1/books lists for the same query: a page of the books of a
+	// series, in reading order, or of all books, read as the REST handler reads them
+	ctx := stream.Context()
+	var books []models.Book
+	var err error
+	switch {
+	case req.GetSeriesId() != 0:
+		books, err = services.FetchBooksBySeries(ctx, int(req.GetSeriesId()))
+	case !cache.GetCachedData(ctx, "books_all", &books):
+		books, err = services.FetchBooksFromDB(ctx, "books_all")
+	}
+	if err != nil {
+		return statusError(ctx, err, "book")
+	}
+	if req.GetPage() != 0 || req.GetPageSize() != 0 {
+		books = page(books, int(req.GetPage()), int(req.GetPageSize()), bookPageSize)
+	}
+	for _, book := range books {
+		if err := stream.Send(bookMessage(book)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (catalogueServer) CreateBook(ctx context.Context, req *libraryv1.CreateBookRequest) (*libraryv1.Book, error) {
+	bookReq := bookRequest(req.GetBook())
+	if err := validate(ctx, bookReq); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	book := bookReq.Model()
+	if err := services.CreateBook(ctx, &book); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	return bookMessage(book), nil
+}
+
+func (catalogueServer) UpdateBook(ctx context.Context, req *libraryv1.UpdateBookRequest) (*libraryv1.Book, error) {
+	bookReq := bookRequest(req.GetBook())
+	if err := checkID(req.GetId(), "book"); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	if err := validate(ctx, bookReq); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	book := bookReq.Model()
+	if err := services.UpdateBook(ctx, int(req.GetId()), &book); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	return bookMessage(book), nil
+}
+
+func (catalogueServer) DeleteBook(ctx context.Context, req *libraryv1.DeleteBookRequest) (*emptypb.Empty, error) {
+	if err := checkID(req.GetId(), "book"); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	if err := services.DeleteBook(ctx, int(req.GetId())); err != nil {
+		return nil, statusError(ctx, err, "book")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (catalogueServer) GetAuthor(ctx context.Context, req *libraryv1.GetAuthorRequest) (*libraryv1.Author, error) {
+	author, err := get(ctx, "author", req.GetId(), services.FetchAuthorFromDB)
+	if err != nil {
+		return nil, err
+	}
+	return authorMessage(*author), nil
+}
+
+func (catalogueServer) ListAuthors(req *libraryv1.ListAuthorsRequest, stream grpc.ServerStreamingServer[libraryv1.Author]) error {
+	return list(stream, "author", func(a models.Author) uint { return a.ID }, authorMessage)
+}
+
+func (catalogueServer) CreateAuthor(ctx context.Context, req *libraryv1.CreateAuthorRequest) (*libraryv1.Author, error) {
+	authorReq := authorRequest(req.GetAuthor())
+	if err := validate(ctx, authorReq); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	author := authorReq.Model()
+	if err := services.CreateAuthor(ctx, &author); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	return authorMessage(author), nil
+}
+
+func (catalogueServer) UpdateAuthor(ctx context.Context, req *libraryv1.UpdateAuthorRequest) (*libraryv1.Author, error) {
+	authorReq := authorRequest(req.GetAuthor())
+	if err := checkID(req.GetId(), "author"); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	if err := validate(ctx, authorReq); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	author := authorReq.Model()
+	if err := services.UpdateAuthor(ctx, int(req.GetId()), &author); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	return authorMessage(author), nil
+}
+
+func (catalogueServer) DeleteAuthor(ctx context.Context, req *libraryv1.DeleteAuthorRequest) (*emptypb.Empty, error) {
+	if err := checkID(req.GetId(), "author"); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	if err := services.DeleteAuthor(ctx, int(req.GetId())); err != nil {
+		return nil, statusError(ctx, err, "author")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (catalogueServer) GetPublisher(ctx context.Context, req *libraryv1.GetPublisherRequest) (*libraryv1.Publisher, error) {
+	publisher, err := get(ctx, "publisher", req.GetId(), services.FetchPublisherFromDB)
+	if err != nil {
+		return nil, err
+	}
+	return publisherMessage(*publisher), nil
+}
+
+func (catalogueServer) ListPublishers(req *libraryv1.ListPublishersRequest, stream grpc.ServerStreamingServer[libraryv1.Publisher]) error {
+	return list(stream, "publisher", func(p models.Publisher) uint { return p.ID }, publisherMessage)
+}
+
+func (catalogueServer) CreatePublisher(ctx context.Context, req *libraryv1.CreatePublisherRequest) (*libraryv1.Publisher, error) {
+	publisherReq := publisherRequest(req.GetPublisher())
+	if err := validate(ctx, publisherReq); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	publisher := publisherReq.Model()
+	if err := services.CreatePublisher(ctx, &publisher); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	return publisherMessage(publisher), nil
+}
+
+func (catalogueServer) UpdatePublisher(ctx context.Context, req *libraryv1.UpdatePublisherRequest) (*libraryv1.Publisher, error) {
+	publisherReq := publisherRequest(req.GetPublisher())
+	if err := checkID(req.GetId(), "publisher"); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	if err := validate(ctx, publisherReq); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	publisher := publisherReq.Model()
+	if err := services.UpdatePublisher(ctx, int(req.GetId()), &publisher); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	return publisherMessage(publisher), nil
+}
+
+func (catalogueServer) DeletePublisher(ctx context.Context, req *libraryv1.DeletePublisherRequest) (*emptypb.Empty, error) {
+	if err := checkID(req.GetId(), "publisher"); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	if err := services.DeletePublisher(ctx, int(req.GetId())); err != nil {
+		return nil, statusError(ctx, err, "publisher")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (catalogueServer) GetCategory(ctx context.Context, req *libraryv1.GetCategoryRequest) (*libraryv1.Category, error) {
+	category, err := get(ctx, "category", req.GetId(), services.FetchCategoryFromDB)
+	if err != nil {
+		return nil, err
+	}
+	return categoryMessage(*category), nil
+}
+
+func (catalogueServer) ListCategories(req *libraryv1.ListCategoriesRequest, stream grpc.ServerStreamingServer[libraryv1.Category]) error {
+	return list(stream, "category", func(c models.Category) uint { return c.ID }, categoryMessage)
+}
+
+func (catalogueServer) CreateCategory(ctx context.Context, req *libraryv1.CreateCategoryRequest) (*libraryv1.Category, error) {
+	categoryReq := categoryRequest(req.GetCategory())
+	if err := validate(ctx, categoryReq); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	category := categoryReq.Model()
+	if err := services.CreateCategory(ctx, &category); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	return categoryMessage(category), nil
+}
+
+func (catalogueServer) UpdateCategory(ctx context.Context, req *libraryv1.UpdateCategoryRequest) (*libraryv1.Category, error) {
+	categoryReq := categoryRequest(req.GetCategory())
+	if err := checkID(req.GetId(), "category"); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	if err := validate(ctx, categoryReq); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	category := categoryReq.Model()
+	if err := services.UpdateCategory(ctx, int(req.GetId()), &category); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	return categoryMessage(category), nil
+}
+
+func (catalogueServer) DeleteCategory(ctx context.Context, req *libraryv1.DeleteCategoryRequest) (*emptypb.Empty, error) {
+	if err := checkID(req.GetId(), "category"); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	if err := services.DeleteCategory(ctx, int(req.GetId()), req.GetStrategy()); err != nil {
+		return nil, statusError(ctx, err, "category")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (catalogueServer) GetReview(ctx context.Context, req *libraryv1.GetReviewRequest) (*libraryv1.Review, error) {
+	review, err := get(ctx, "review", req.GetId(), services.FetchReviewFromDB)
+	if err != nil {
+		return nil, err
+	}
+	return reviewMessage(*review), nil
+}
+
+func (catalogueServer) ListReviews(req *libraryv1.ListReviewsRequest, stream grpc.ServerStreamingServer[libraryv1.Review]) error {
+	return list(stream, "review", func(r models.Review) uint { return r.ID }, reviewMessage)
+}
+
+func (catalogueServer) CreateReview(ctx context.Context, req *libraryv1.CreateReviewRequest) (*libraryv1.Review, error) {
+	reviewReq := reviewRequest(req.GetReview())
+	if err := validate(ctx, reviewReq); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	review := reviewReq.Model()
+	if err := services.CreateReview(ctx, &review); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	return reviewMessage(review), nil
+}
+
+func (catalogueServer) UpdateReview(ctx context.Context, req *libraryv1.UpdateReviewRequest) (*libraryv1.Review, error) {
+	reviewReq := reviewRequest(req.GetReview())
+	if err := checkID(req.GetId(), "review"); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	if err := validate(ctx, reviewReq); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	review := reviewReq.Model()
+	if err := services.UpdateReview(ctx, int(req.GetId()), &review); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	return reviewMessage(review), nil
+}
+
+func (catalogueServer) DeleteReview(ctx context.Context, req *libraryv1.DeleteReviewRequest) (*emptypb.Empty, error) {
+	if err := checkID(req.GetId(), "review"); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	if err := services.DeleteReview(ctx, int(req.GetId())); err != nil {
+		return nil, statusError(ctx, err, "review")
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,79 @@
+package grpcserver
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"gin-books-api/middleware"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// trustedProxies are the addresses of the reverse proxies whose X-Forwarded-For the
+// gateway believes.
+type trustedProxies []netip.Prefix
+
+// client returns the client of an RPC: its peer address, and the user of its x-user-id
+// metadata. RPCs of the gateway come from the loopback interface, with the address of
+// the HTTP client last in x-forwarded-for; it is taken from there, skipping the trusted
+// proxies as the REST API skips them.
+func (p trustedProxies) client(ctx context.Context) middleware.Client {
+	var ip netip.Addr
+	if remote, ok := peer.FromContext(ctx); ok && remote.Addr != nil {
+		if addrPort, err := netip.ParseAddrPort(remote.Addr.String()); err == nil {
+			ip = addrPort.Addr().Unmap()
+		}
+	}
+	if ip.IsLoopback() {
+		var forwarded []string
+		for _, value := range metadata.ValueFromIncomingContext(ctx, "x-forwarded-for") {
+			forwarded = append(forwarded, strings.Split(value, ",")...)
+		}
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+			if err != nil {
+				break
+			}
+			ip = addr.Unmap()
+			if !p.trusts(ip) {
+				break
+			}
+		}
+	}
+
+	client := middleware.Client{IP: ip.String()}
+	for _, value := range metadata.ValueFromIncomingContext(ctx, "x-user-id") {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			client.UserID = id
+			break
+		}
+	}
+	return client
+}
+
+// trusts reports whether ip is the address of a trusted proxy.
+func (p trustedProxies) trusts(ip netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxies parses addresses and CIDR ranges, such as 10.0.0.0/8, skipping those
+// that are invalid.
+func parseProxies(proxies []string) trustedProxies {
+	var prefixes trustedProxies
+	for _, proxy := range proxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,38 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"gin-books-api/middleware"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// rpcContext returns the context of an RPC from addr with the incoming metadata pairs.
+func rpcContext(addr string, pairs ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 50000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+}
+
+func TestClient(t *testing.T) {
+	proxies := parseProxies([]string{"10.0.0.0/8", "invalid"})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want middleware.Client
+	}{
+		{"direct", rpcContext("192.0.2.1", "x-forwarded-for", "203.0.113.9", "x-user-id", "7"), middleware.Client{IP: "192.0.2.1", UserID: 7}},
+		{"gateway", rpcContext("127.0.0.1", "x-forwarded-for", "198.51.100.4"), middleware.Client{IP: "198.51.100.4"}},
+		{"gateway behind a trusted proxy", rpcContext("::1", "x-forwarded-for", "203.0.113.9, 10.1.2.3"), middleware.Client{IP: "203.0.113.9"}},
+		{"gateway behind an untrusted proxy", rpcContext("127.0.0.1", "x-forwarded-for", "203.0.113.9, 198.51.100.4"), middleware.Client{IP: "198.51.100.4"}},
+		{"invalid user", rpcContext("192.0.2.1", "x-user-id", "seven"), middleware.Client{IP: "192.0.2.1"}},
+	}
+	for _, test := range tests {
+		if got := proxies.client(test.ctx); got != test.want {
+			t.Errorf("%s: client() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package grpcserver
+
+import (
+	"gin-books-api/dto"
+	"gin-books-api/models"
+	libraryv1 "gin-books-api/proto/library/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func bookMessage(book models.Book) *libraryv1.Book {
+	message := &libraryv1.Book{
+		Id:            uint32(book.ID),
+		Title:         book.Title,
+		Description:   book.Description,
+		PublishedYear: int32(book.PublishedYear),
+		AuthorId:      optionalID(book.AuthorID),
+		PublisherId:   optionalID(book.PublisherID),
+		CategoryId:    optionalID(book.CategoryID),
+		WorkId:        optionalID(book.WorkID),
+		Isbn:          book.ISBN,
+		Language:      book.Language,
+		Format:        book.Format,
+		Availability:  book.Availability,
+		CreatedAt:     timestamppb.New(book.CreatedAt),
+		UpdatedAt:     timestamppb.New(book.UpdatedAt),
+	}
+	for _, tag := range book.Tags {
+		message.Tags = append(message.Tags, &libraryv1.Tag{Id: uint32(tag.ID), Name: tag.Name})
+	}
+	for _, subject := range book.Subjects {
+		message.Subjects = append(message.Subjects, &libraryv1.Subject{
+			Id:       uint32(subject.ID),
+			Name:     subject.Name,
+			Code:     subject.Code,
+			ParentId: optionalID(subject.ParentID),
+		})
+	}
+	return message
+}
+
+func bookRequest(input *libraryv1.BookInput) *dto.BookRequest {
+	req := &dto.BookRequest{
+		Title:         input.GetTitle(),
+		Description:   input.GetDescription(),
+		PublishedYear: int(input.GetPublishedYear()),
+		ISBN:          input.GetIsbn(),
+		Language:      input.GetLanguage(),
+		Format:        input.GetFormat(),
+		Availability:  input.Availability,
+	}
+	if input != nil {
+		req.AuthorID = modelID(input.AuthorId)
+		req.PublisherID = modelID(input.PublisherId)
+		req.CategoryID = modelID(input.CategoryId)
+		req.WorkID = modelID(input.WorkId)
+	}
+	for _, tag := range input.GetTags() {
+		req.Tags = append(req.Tags, dto.TagRequest{Name: tag.GetName()})
+	}
+	for _, subject := range input.GetSubjects() {
+		req.Subjects = append(req.Subjects, dto.SubjectReference{ID: uint(subject.GetId())})
+	}
+	return req
+}
+
+func authorMessage(author models.Author) *libraryv1.Author {
+	return &libraryv1.Author{Id: uint32(author.ID), Name: author.Name, Bio: author.Bio, Email: author.Email}
+}
+
+func authorRequest(input *libraryv1.AuthorInput) *dto.AuthorRequest {
+	return &dto.AuthorRequest{Name: input.GetName(), Bio: input.GetBio(), Email: input.GetEmail()}
+}
+
+func publisherMessage(publisher models.Publisher) *libraryv1.Publisher {
+	return &libraryv1.Publisher{Id: uint32(publisher.ID), Name: publisher.Name, Address: publisher.Address, Phone: publisher.Phone}
+}
+
+func publisherRequest(input *libraryv1.PublisherInput) *dto.PublisherRequest {
+	return &dto.PublisherRequest{Name: input.GetName(), Address: input.GetAddress(), Phone: input.GetPhone()}
+}
+
+func categoryMessage(category models.Category) *libraryv1.Category {
+	return &libraryv1.Category{Id: uint32(category.ID), Name: category.Name, ParentId: optionalID(category.ParentID)}
+}
+
+func categoryRequest(input *libraryv1.CategoryInput) *dto.CategoryRequest {
+	req := &dto.CategoryRequest{Name: input.GetName()}
+	if input != nil {
+		req.ParentID = modelID(input.ParentId)
+	}
+	return req
+}
+
+func reviewMessage(review models.Review) *libraryv1.Review {
+	return &libraryv1.Review{
+		Id:      uint32(review.ID),
+		BookId:  uint32(review.BookID),
+		UserId:  uint32(review.UserID),
+		Rating:  int32(review.Rating),
+		Comment: review.Comment,
+	}
+}
+
+func reviewRequest(input *libraryv1.ReviewInput) *dto.ReviewRequest {
+	return &dto.ReviewRequest{
+		BookID:  uint(input.GetBookId()),
+		UserID:  uint(input.GetUserId()),
+		Rating:  int(input.GetRating()),
+		Comment: input.GetComment(),
+	}
+}
+
+func loanMessage(loan models.BorrowedBook) *libraryv1.Loan {
+	return &libraryv1.Loan{
+		Id:         uint32(loan.ID),
+		BookId:     uint32(loan.BookID),
+		UserId:     uint32(loan.UserID),
+		BorrowedAt: timestamppb.New(loan.BorrowedAt),
+		DueDate:    timestamppb.New(loan.DueDate),
+	}
+}
+
+func loanRequest(input *libraryv1.LoanInput) *dto.LoanRequest {
+	req := &dto.LoanRequest{BookID: uint(input.GetBookId()), UserID: uint(input.GetUserId())}
+	if input.GetDueDate() != nil {
+		dueDate := input.GetDueDate().AsTime()
+		req.DueDate = &dueDate
+	}
+	return req
+}
+
+// optionalID converts a nullable foreign key of a model into its message field.
+func optionalID(id *uint) *uint32 {
+	if id == nil {
+		return nil
+	}
+	value := uint32(*id)
+	return &value
+}
+
+// modelID converts an optional ID field of a message into a nullable foreign key.
+func modelID(id *uint32) *uint {
+	if id == nil {
+		return nil
+	}
+	value := uint(*id)
+	return &value
+}
@@ -0,0 +1,146 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/utils"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the domain of the ErrorInfo details of errors.
+const errorDomain = "gin-books-api"
+
+// statusError converts an error returned by a service for entity, e.g. "book", into a
+// gRPC status. The status carries an ErrorInfo whose reason is the code of the error
+// and whose metadata holds the HTTP status of the REST API, and, for validation errors,
+// the field violations. Since violations have no code of their own, the code of the
+// n-th is in the metadata as violation_n.
+func statusError(ctx context.Context, err error, entity string) error {
+	appErr := apperrors.FromDB(err, entity)
+	httpStatus := appErr.Kind.Status()
+	if httpStatus >= http.StatusInternalServerError && appErr.Err != nil {
+		method, _ := grpc.Method(ctx)
+		slog.ErrorContext(ctx, "RPC failed", "method", method, "error", appErr.Err)
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason:   appErr.Code,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"status": strconv.Itoa(httpStatus)},
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for i, detail := range appErr.Details {
+		info.Metadata["violation_"+strconv.Itoa(i)] = detail.Code
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: detail.Field, Description: detail.Message})
+	}
+
+	st := status.New(grpcCode(appErr), appErr.Message)
+	details := []protoadapt.MessageV1{info}
+	if len(violations) > 0 {
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcCode returns the gRPC code of an error.
+func grpcCode(err *apperrors.Error) codes.Code {
+	switch err.Kind {
+	case apperrors.KindBadRequest, apperrors.KindValidation, apperrors.KindUnsupportedMediaType:
+		return codes.InvalidArgument
+	case apperrors.KindNotFound:
+		return codes.NotFound
+	case apperrors.KindConflict:
+		if err.Code == "already_exists" {
+			return codes.AlreadyExists
+		}
+		return codes.FailedPrecondition
+	case apperrors.KindForbidden:
+		return codes.PermissionDenied
+	case apperrors.KindTooManyRequests, apperrors.KindPayloadTooLarge:
+		return codes.ResourceExhausted
+	case apperrors.KindUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// gatewayError renders the error of a request to the JSON mapping as the problem
+// response the REST API would send, using the details added by statusError.
+func gatewayError(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	problem := utils.Problem{
+		Type:     "about:blank",
+		Status:   runtime.HTTPStatusFromCode(st.Code()),
+		Detail:   st.Message(),
+		Instance: r.URL.Path,
+		Code:     strings.ToLower(st.Code().String()),
+	}
+
+	var violationCodes map[string]string
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if detail.Domain != errorDomain {
+				continue
+			}
+			problem.Code = detail.Reason
+			if httpStatus, err := strconv.Atoi(detail.Metadata["status"]); err == nil {
+				problem.Status = httpStatus
+			}
+			violationCodes = detail.Metadata
+		case *errdetails.BadRequest:
+			for i, violation := range detail.FieldViolations {
+				problem.Errors = append(problem.Errors, utils.FieldError{
+					Field:   violation.Field,
+					Code:    violationCodes["violation_"+strconv.Itoa(i)],
+					Message: violation.Description,
+				})
+			}
+		}
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	// Send the request ID and rate limits as the REST API does; an RPC that failed
+	// before sending its headers has them in its trailers
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		for _, values := range []map[string][]string{md.TrailerMD, md.HeaderMD} {
+			for key, value := range values {
+				if header, ok := responseHeaders[key]; ok && len(value) > 0 {
+					w.Header().Set(header, value[0])
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", utils.ProblemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		slog.ErrorContext(ctx, "Failed to write gateway error", "error", err)
+	}
+}
+
+// routingError renders a request that matches no RPC as the REST API renders one that
+// matches no route.
+func routingError(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, httpStatus int) {
+	err := apperrors.NotFound("route_not_found", "No route matches "+r.Method+" "+r.URL.Path)
+	if httpStatus == http.StatusBadRequest {
+		err = apperrors.BadRequest("invalid_request", "Invalid request")
+	}
+	gatewayError(ctx, mux, marshaler, w, r, statusError(ctx, err, ""))
+}
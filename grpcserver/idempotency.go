@@ -0,0 +1,125 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/middleware"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// idempotency honours the idempotency-key metadata of the RPCs mapped to POST, such as
+// CreateBook, as middleware.Idempotency honours the Idempotency-Key header: a retry of
+// an RPC gets the response or error of the first one, with idempotent-replayed
+// metadata, instead of creating a duplicate. Keys are scoped by user and shared with
+// the REST API, so that a key used for a REST request is refused for an RPC.
+type idempotency struct {
+	proxies trustedProxies
+}
+
+func (i idempotency) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	keys := metadata.ValueFromIncomingContext(ctx, "idempotency-key")
+	message, ok := req.(proto.Message)
+	if method, _ := route(info.FullMethod); len(keys) == 0 || !ok || method != http.MethodPost {
+		return handler(ctx, req)
+	}
+	if err := middleware.CheckIdempotencyKey(keys[0]); err != nil {
+		return nil, statusError(ctx, err, "")
+	}
+	fingerprint, err := rpcFingerprint(info.FullMethod, message)
+	if err != nil {
+		return nil, statusError(ctx, apperrors.Internal(err), "")
+	}
+
+	// The response is stored even if the client goes away while it is being made
+	storeCtx := context.WithoutCancel(ctx)
+	claim, stored, err := middleware.ClaimIdempotencyKey(storeCtx, i.proxies.client(ctx).UserID, keys[0], fingerprint)
+	switch {
+	case err != nil:
+		return nil, statusError(ctx, err, "")
+	case stored != nil:
+		return replayRPC(ctx, info.FullMethod, stored)
+	case claim == nil:
+		return handler(ctx, req)
+	}
+	// Frees the key if the handler panics
+	defer claim.Release(storeCtx)
+
+	resp, err := handler(ctx, req)
+	if response, ok := storedRPC(resp, err); ok {
+		claim.Store(storeCtx, response)
+	} else {
+		claim.Release(storeCtx)
+	}
+	return resp, err
+}
+
+// rpcFingerprint identifies an RPC by its method and request.
+func rpcFingerprint(fullMethod string, req proto.Message) (string, error) {
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(fullMethod + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// storedRPC returns the record of the outcome of an RPC: the response, with status 200,
+// or the status of the error, with the HTTP status of its code. Errors of the server
+// are not stored, so that the RPC can be retried.
+func storedRPC(resp interface{}, err error) (middleware.IdempotentResponse, bool) {
+	if err != nil {
+		st := status.Convert(err)
+		httpStatus := runtime.HTTPStatusFromCode(st.Code())
+		if httpStatus >= http.StatusInternalServerError {
+			return middleware.IdempotentResponse{}, false
+		}
+		body, err := proto.Marshal(st.Proto())
+		return middleware.IdempotentResponse{Status: httpStatus, Body: body}, err == nil
+	}
+	message, ok := resp.(proto.Message)
+	if !ok {
+		return middleware.IdempotentResponse{}, false
+	}
+	body, err := proto.Marshal(message)
+	return middleware.IdempotentResponse{Status: http.StatusOK, Body: body}, err == nil
+}
+
+// replayRPC returns the outcome stored for an earlier RPC of fullMethod.
+func replayRPC(ctx context.Context, fullMethod string, stored *middleware.IdempotentResponse) (interface{}, error) {
+	grpc.SetHeader(ctx, metadata.Pairs("idempotent-replayed", "true"))
+	if stored.Status != http.StatusOK {
+		var st spb.Status
+		if err := proto.Unmarshal(stored.Body, &st); err != nil {
+			return nil, statusError(ctx, apperrors.Internal(err), "")
+		}
+		return nil, status.FromProto(&st).Err()
+	}
+
+	method := methodDescriptor(fullMethod)
+	if method == nil {
+		return nil, statusError(ctx, apperrors.Internal(errors.New("no descriptor of "+fullMethod)), "")
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		return nil, statusError(ctx, apperrors.Internal(err), "")
+	}
+	resp := messageType.New().Interface()
+	if err := proto.Unmarshal(stored.Body, resp); err != nil {
+		return nil, statusError(ctx, apperrors.Internal(err), "")
+	}
+	return resp, nil
+}
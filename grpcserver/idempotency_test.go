@@ -0,0 +1,113 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	"gin-books-api/apperrors"
+	config "gin-books-api/configs"
+	libraryv1 "gin-books-api/proto/library/v1"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// useMiniredis points the cache at an in-process Redis for the duration of the test.
+func useMiniredis(t *testing.T) {
+	server := miniredis.RunT(t)
+	previous := config.RedisClient
+	config.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		config.RedisClient.Close()
+		config.RedisClient = previous
+	})
+}
+
+func TestIdempotencyReplaysCreates(t *testing.T) {
+	useMiniredis(t)
+	interceptor := idempotency{}.unary
+	info := &grpc.UnaryServerInfo{FullMethod: "/library.v1.CatalogueService/CreateBook"}
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return &libraryv1.Book{Id: uint32(calls), Title: req.(*libraryv1.CreateBookRequest).GetBook().GetTitle()}, nil
+	}
+	request := &libraryv1.CreateBookRequest{Book: &libraryv1.BookInput{Title: "Dune"}}
+	ctx := rpcContext("192.0.2.1", "idempotency-key", "book-1")
+
+	first, err := interceptor(ctx, request, info, handler)
+	if err != nil {
+		t.Fatalf("first RPC: %v", err)
+	}
+	retry, err := interceptor(ctx, request, info, handler)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if calls != 1 || !proto.Equal(first.(proto.Message), retry.(proto.Message)) {
+		t.Errorf("retry = %v after %d calls, want %v after one call", retry, calls, first)
+	}
+
+	// A different request reusing the key is refused
+	other := &libraryv1.CreateBookRequest{Book: &libraryv1.BookInput{Title: "Emma"}}
+	if _, err := interceptor(ctx, other, info, handler); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RPC reusing the key: error = %v, want InvalidArgument", err)
+	}
+
+	// Another user has keys of its own
+	if _, err := interceptor(rpcContext("192.0.2.1", "x-user-id", "8", "idempotency-key", "book-1"), request, info, handler); err != nil || calls != 2 {
+		t.Errorf("RPC of another user: error = %v after %d calls, want a new book", err, calls)
+	}
+}
+
+func TestIdempotencyReplaysClientErrors(t *testing.T) {
+	useMiniredis(t)
+	interceptor := idempotency{}.unary
+	info := &grpc.UnaryServerInfo{FullMethod: "/library.v1.LendingService/BorrowBook"}
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, statusError(ctx, apperrors.Conflict("book_on_loan", "The book is on loan"), "loan")
+		}
+		return nil, statusError(ctx, apperrors.Internal(nil), "loan")
+	}
+	request := &libraryv1.BorrowBookRequest{Loan: &libraryv1.LoanInput{BookId: 1, UserId: 7}}
+	ctx := rpcContext("192.0.2.1", "idempotency-key", "loan-1")
+
+	for range 2 {
+		if _, err := interceptor(ctx, request, info, handler); status.Code(err) != codes.FailedPrecondition || calls != 1 {
+			t.Fatalf("error = %v after %d calls, want the first FailedPrecondition replayed", err, calls)
+		}
+	}
+
+	// Errors of the server are not stored
+	ctx = rpcContext("192.0.2.1", "idempotency-key", "loan-2")
+	for want := 2; want <= 3; want++ {
+		if _, err := interceptor(ctx, request, info, handler); status.Code(err) != codes.Internal || calls != want {
+			t.Fatalf("error = %v after %d calls, want Internal after %d calls", err, calls, want)
+		}
+	}
+}
+
+func TestIdempotencyIgnoresOtherRPCs(t *testing.T) {
+	useMiniredis(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/library.v1.CatalogueService/UpdateBook"}
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return &libraryv1.Book{Id: 1}, nil
+	}
+	ctx := rpcContext("192.0.2.1", "idempotency-key", "book-1")
+	for range 2 {
+		if _, err := (idempotency{}).unary(ctx, &libraryv1.UpdateBookRequest{Id: 1}, info, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("UpdateBook called %d times, want 2", calls)
+	}
+}
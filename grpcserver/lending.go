@@ -0,0 +1,51 @@
+package grpcserver
+
+import (
+	"context"
+
+	"gin-books-api/models"
+	libraryv1 "gin-books-api/proto/library/v1"
+	"gin-books-api/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// lendingServer implements LendingService over the services of the REST API.
+type lendingServer struct {
+	libraryv1.UnimplementedLendingServiceServer
+}
+
+func (lendingServer) GetLoan(ctx context.Context, req *libraryv1.GetLoanRequest) (*libraryv1.Loan, error) {
+	loan, err := get(ctx, "loan", req.GetId(), services.FetchLoanFromDB)
+	if err != nil {
+		return nil, err
+	}
+	return loanMessage(*loan), nil
+}
+
+func (lendingServer) ListLoans(req *libraryv1.ListLoansRequest, stream grpc.ServerStreamingServer[libraryv1.Loan]) error {
+	return list(stream, "loan", func(l models.BorrowedBook) uint { return l.ID }, loanMessage)
+}
+
+func (lendingServer) BorrowBook(ctx context.Context, req *libraryv1.BorrowBookRequest) (*libraryv1.Loan, error) {
+	loanReq := loanRequest(req.GetLoan())
+	if err := validate(ctx, loanReq); err != nil {
+		return nil, statusError(ctx, err, "loan")
+	}
+	loan := loanReq.Model()
+	if err := services.BorrowBook(ctx, &loan); err != nil {
+		return nil, statusError(ctx, err, "loan")
+	}
+	return loanMessage(loan), nil
+}
+
+func (lendingServer) ReturnBook(ctx context.Context, req *libraryv1.ReturnBookRequest) (*emptypb.Empty, error) {
+	if err := checkID(req.GetId(), "loan"); err != nil {
+		return nil, statusError(ctx, err, "loan")
+	}
+	if err := services.ReturnBook(ctx, int(req.GetId())); err != nil {
+		return nil, statusError(ctx, err, "loan")
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,56 @@
+package grpcserver
+
+import (
+	"context"
+
+	"gin-books-api/middleware"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// rateLimits limits the RPCs of each client as the REST API limits its requests, each
+// RPC counting against the group of the route it is mapped to.
+type rateLimits struct {
+	limiter *middleware.RateLimiter
+	proxies trustedProxies
+}
+
+func (l rateLimits) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	decision, limited := l.limiter.Take(ctx, routePath(info.FullMethod), l.proxies.client(ctx))
+	if !limited {
+		return handler(ctx, req)
+	}
+	header := rateLimitMetadata(decision)
+	if err := decision.Err(); err != nil {
+		grpc.SendHeader(ctx, header)
+		return nil, statusError(ctx, err, "")
+	}
+	grpc.SetHeader(ctx, header)
+	return handler(ctx, req)
+}
+
+func (l rateLimits) stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := stream.Context()
+	decision, limited := l.limiter.Take(ctx, routePath(info.FullMethod), l.proxies.client(ctx))
+	if !limited {
+		return handler(srv, stream)
+	}
+	header := rateLimitMetadata(decision)
+	if err := decision.Err(); err != nil {
+		stream.SendHeader(header)
+		return statusError(ctx, err, "")
+	}
+	stream.SetHeader(header)
+	return handler(srv, stream)
+}
+
+// rateLimitMetadata returns the headers of a decision as response metadata, such as
+// ratelimit-remaining.
+func rateLimitMetadata(decision middleware.RateDecision) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range decision.Headers() {
+		md.Set(name, values...)
+	}
+	return md
+}
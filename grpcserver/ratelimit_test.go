@@ -0,0 +1,39 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gin-books-api/middleware"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitRefusesRPCsOverTheRate(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimits{Groups: map[string]middleware.RateLimitPolicy{
+		"books": {Anonymous: middleware.Rate{Requests: 1, Window: time.Hour}},
+	}})
+	limits := rateLimits{limiter: limiter}
+	info := &grpc.UnaryServerInfo{FullMethod: "/library.v1.CatalogueService/GetBook"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "book", nil }
+
+	ctx := rpcContext("192.0.2.1")
+	if _, err := limits.unary(ctx, nil, info, handler); err != nil {
+		t.Fatalf("first RPC: %v", err)
+	}
+	_, err := limits.unary(ctx, nil, info, handler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second RPC error = %v, want ResourceExhausted", err)
+	}
+
+	// Other groups and other clients have rates of their own
+	if _, err := limits.unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/library.v1.CatalogueService/GetAuthor"}, handler); err != nil {
+		t.Errorf("RPC of another group: %v", err)
+	}
+	if _, err := limits.unary(rpcContext("192.0.2.2"), nil, info, handler); err != nil {
+		t.Errorf("RPC of another client: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package grpcserver
+
+import (
+	"context"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/dto"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc"
+)
+
+// listPageSize is the number of rows a list RPC reads at a time while streaming.
+const listPageSize = 100
+
+// bookPageSize is the page size of ListBooks when its request sets only the page, as
+// for GET /api/v1/books.
+const bookPageSize = 5
+
+// get returns the row of entity, e.g. "book", with the given ID: from the cache if it
+// holds the row, as GET /api/v1/books/:id does, or else from the database.
+func get[M any](ctx context.Context, entity string, id uint32, fetch func(context.Context, string, int) (*M, error)) (*M, error) {
+	if err := checkID(id, entity); err != nil {
+		return nil, statusError(ctx, err, entity)
+	}
+
+	cacheKey := entity + "_" + strconv.FormatUint(uint64(id), 10)
+	var row M
+	if cache.GetCachedData(ctx, cacheKey, &row) {
+		return &row, nil
+	}
+	fetched, err := fetch(ctx, cacheKey, int(id))
+	if err != nil {
+		return nil, statusError(ctx, err, entity)
+	}
+	return fetched, nil
+}
+
+// list streams every row of M as a message P, ordered by ID, reading a page at a time
+// so that long lists are not held in memory.
+func list[M, P any](stream grpc.ServerStreamingServer[P], entity string, id func(M) uint, message func(M) *P) error {
+	ctx := stream.Context()
+	var after uint
+	for {
+		rows, _, err := services.FetchPage[M](ctx, after, listPageSize)
+		if err != nil {
+			return statusError(ctx, err, entity)
+		}
+		for _, row := range rows {
+			if err := stream.Send(message(row)); err != nil {
+				return err
+			}
+		}
+		if len(rows) < listPageSize {
+			return nil
+		}
+		after = id(rows[len(rows)-1])
+	}
+}
+
+// page returns the rows of page number, from 1, of pageSize rows; either defaults when
+// zero, to 1 and defaultSize, as ?page= and ?pageSize= do for the REST API.
+func page[M any](rows []M, number, pageSize, defaultSize int) []M {
+	number = max(number, 1)
+	if pageSize == 0 {
+		pageSize = defaultSize
+	}
+	start := min((number-1)*pageSize, len(rows))
+	end := min(start+pageSize, len(rows))
+	return rows[start:end]
+}
+
+// validate checks req as bindRequest checks the body of a REST request: against its
+// binding rules, then for references to rows that do not exist.
+func validate(ctx context.Context, req dto.Request) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		if details := utils.ValidationErrors(err); details != nil {
+			return apperrors.Validation(details...)
+		}
+		return apperrors.BadRequest("invalid_request", "Invalid request")
+	}
+	return services.CheckReferences(ctx, req)
+}
+
+// checkID rejects the zero ID, which no row has, as the REST API rejects a malformed one.
+func checkID(id uint32, entity string) error {
+	if id == 0 {
+		return apperrors.BadRequest("invalid_id", "Invalid "+entity+" ID")
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package grpcserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	rows := []int{1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		number, pageSize int
+		want             []int
+	}{
+		{1, 3, []int{1, 2, 3}},
+		{3, 3, []int{7}},
+		{4, 3, []int{}},
+		{0, 2, []int{1, 2}},
+		{2, 0, []int{6, 7}},
+	}
+	for _, test := range tests {
+		if got := page(rows, test.number, test.pageSize, 5); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("page(%d, %d) = %v, want %v", test.number, test.pageSize, got, test.want)
+		}
+	}
+}
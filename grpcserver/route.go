@@ -0,0 +1,53 @@
+package grpcserver
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// methodDescriptor returns the descriptor of an RPC, such as
+// /library.v1.CatalogueService/GetBook, or nil if it is not registered.
+func methodDescriptor(fullMethod string) protoreflect.MethodDescriptor {
+	name := strings.ReplaceAll(strings.TrimPrefix(fullMethod, "/"), "/", ".")
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil
+	}
+	method, _ := descriptor.(protoreflect.MethodDescriptor)
+	return method
+}
+
+// route returns the HTTP method and path an RPC is mapped to by its google.api.http
+// option, such as GET /api/v1/books/{id} for /library.v1.CatalogueService/GetBook, or
+// empty strings if it is not mapped.
+func route(fullMethod string) (string, string) {
+	method := methodDescriptor(fullMethod)
+	if method == nil {
+		return "", ""
+	}
+	rule, _ := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, pattern.Patch
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, pattern.Delete
+	}
+	return "", ""
+}
+
+// routePath returns the path an RPC is mapped to, or "" if it is not mapped.
+func routePath(fullMethod string) string {
+	_, path := route(fullMethod)
+	return path
+}
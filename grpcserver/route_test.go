@@ -0,0 +1,19 @@
+package grpcserver
+
+import "testing"
+
+func TestRoute(t *testing.T) {
+	tests := map[string][2]string{
+		"/library.v1.CatalogueService/GetBook":      {"GET", "/api/v1/books/{id}"},
+		"/library.v1.CatalogueService/ListAuthors":  {"GET", "/api/v1/authors"},
+		"/library.v1.CatalogueService/CreateReview": {"POST", "/api/v1/reviews"},
+		"/library.v1.LendingService/BorrowBook":     {"POST", "/api/v1/loans"},
+		"/library.v1.LendingService/Missing":        {"", ""},
+		"/grpc.health.v1.Health/Check":              {"", ""},
+	}
+	for fullMethod, want := range tests {
+		if method, path := route(fullMethod); method != want[0] || path != want[1] {
+			t.Errorf("route(%q) = %s %s, want %s %s", fullMethod, method, path, want[0], want[1])
+		}
+	}
+}
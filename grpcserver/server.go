@@ -0,0 +1,278 @@
+// Package grpcserver serves the catalogue and lending operations over gRPC, backed by
+// the same services and validation as the REST API. The same port serves a JSON
+// mapping of the RPCs on the paths of /api/v1, through grpc-gateway, whose errors are
+// the problem responses of the REST API.
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gin-books-api/logging"
+	"gin-books-api/middleware"
+	libraryv1 "gin-books-api/proto/library/v1"
+	"gin-books-api/services"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Options configure the gRPC server as the REST API is configured.
+type Options struct {
+	// RateLimiter limits the RPCs of each client, sharing its counts with the REST API;
+	// nil for no limit
+	RateLimiter *middleware.RateLimiter
+
+	// TrustedProxies are the addresses and CIDR ranges of the reverse proxies whose
+	// X-Forwarded-For the gateway believes, as in gin.Engine.SetTrustedProxies
+	TrustedProxies []string
+}
+
+// NewServer returns a gRPC server with the catalogue and lending services registered.
+func NewServer(options Options) *grpc.Server {
+	proxies := parseProxies(options.TrustedProxies)
+	unary := []grpc.UnaryServerInterceptor{unaryContext}
+	stream := []grpc.StreamServerInterceptor{streamContext}
+	if options.RateLimiter != nil {
+		limits := rateLimits{limiter: options.RateLimiter, proxies: proxies}
+		unary = append(unary, limits.unary)
+		stream = append(stream, limits.stream)
+	}
+	unary = append(unary, idempotency{proxies: proxies}.unary)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+	libraryv1.RegisterCatalogueServiceServer(server, catalogueServer{})
+	libraryv1.RegisterLendingServiceServer(server, lendingServer{})
+	return server
+}
+
+// Server serves gRPC and the JSON mapping on one port. Requests with the
+// application/grpc content type go to the gRPC server; the others go to the gateway,
+// which calls the gRPC server through a client connection.
+type Server struct {
+	listener net.Listener
+	grpc     *grpc.Server
+	http     *http.Server
+	conn     *grpc.ClientConn
+
+	// Requests being served, including those of the HTTP/2 connections without TLS,
+	// which are hijacked from the HTTP server
+	inFlight atomic.Int64
+}
+
+// Listen listens on addr, e.g. ":9090", and returns the server to serve there.
+func Listen(addr string, options Options) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newServer(listener, options)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newServer(listener net.Listener, options Options) (*Server, error) {
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient("localhost:"+port, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := runtime.NewServeMux(
+		runtime.WithErrorHandler(gatewayError),
+		runtime.WithRoutingErrorHandler(routingError),
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+	)
+	ctx := context.Background()
+	if err := libraryv1.RegisterCatalogueServiceHandler(ctx, gateway, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := libraryv1.RegisterLendingServiceHandler(ctx, gateway, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &Server{listener: listener, grpc: NewServer(options), conn: conn}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			s.grpc.ServeHTTP(w, r)
+			return
+		}
+		gateway.ServeHTTP(w, r)
+	})
+	// Shutting down the HTTP server sends GOAWAY on the HTTP/2 connections as well
+	h2 := &http2.Server{}
+	s.http = &http.Server{Handler: h2c.NewHandler(handler, h2)}
+	if err := http2.ConfigureServer(s.http, h2); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Serve serves requests until Shutdown is called, and then returns
+// http.ErrServerClosed.
+func (s *Server) Serve() error {
+	return s.http.Serve(s.listener)
+}
+
+// Shutdown stops accepting connections, tells HTTP/2 clients, gRPC ones included, to
+// start no new streams, and waits for the requests and RPCs under way until ctx is
+// done. It then stops the RPCs left and closes the connection of the gateway.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+
+	// The HTTP server does not wait for the requests of hijacked connections
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for s.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	// GracefulStop cannot drain the RPCs served through ServeHTTP, so the server is only
+	// stopped once they are done
+	s.grpc.Stop()
+	if closeErr := s.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// headerMatcher forwards X-User-ID, X-Request-ID and Idempotency-Key to the RPCs as
+// x-user-id, x-request-id and idempotency-key metadata, besides the headers
+// grpc-gateway forwards by default.
+func headerMatcher(key string) (string, bool) {
+	switch http.CanonicalHeaderKey(key) {
+	case "X-User-Id":
+		return "x-user-id", true
+	case "X-Request-Id":
+		return "x-request-id", true
+	case "Idempotency-Key":
+		return "idempotency-key", true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// responseHeaders are the response metadata returned by the gateway as the headers the
+// REST API sends, rather than as Grpc-Metadata-* headers.
+var responseHeaders = map[string]string{
+	"x-request-id":        "X-Request-ID",
+	"ratelimit-limit":     "RateLimit-Limit",
+	"ratelimit-remaining": "RateLimit-Remaining",
+	"ratelimit-reset":     "RateLimit-Reset",
+	"ratelimit-policy":    "RateLimit-Policy",
+	"retry-after":         "Retry-After",
+	"idempotent-replayed": "Idempotent-Replayed",
+}
+
+// outgoingHeaderMatcher returns the metadata of responseHeaders as their headers, and
+// the other metadata as grpc-gateway does by default.
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if header, ok := responseHeaders[key]; ok {
+		return header, true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// requestContext returns the context of an RPC with the request ID of the x-request-id
+// metadata, or a new one, and the acting user of the x-user-id metadata, which plays
+// the part of the X-User-ID header of REST requests. Both are logged with every record
+// of the RPC.
+func requestContext(ctx context.Context) (context.Context, string) {
+	requestID := middleware.NewRequestID()
+	if values := metadata.ValueFromIncomingContext(ctx, "x-request-id"); len(values) > 0 && middleware.ValidRequestID(values[0]) {
+		requestID = values[0]
+	}
+	ctx = logging.With(ctx, slog.String("request_id", requestID))
+
+	for _, value := range metadata.ValueFromIncomingContext(ctx, "x-user-id") {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil && id > 0 {
+			ctx = services.WithActor(ctx, uint(id))
+			ctx = logging.With(ctx, slog.Uint64("user_id", id))
+			break
+		}
+	}
+	return ctx, requestID
+}
+
+// logRPC logs an RPC once it has been served, as middleware.AccessLog logs requests.
+func logRPC(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelWarn
+	switch code {
+	case codes.OK:
+		level = slog.LevelInfo
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+		level = slog.LevelError
+	}
+	slog.LogAttrs(ctx, level, "rpc",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+	)
+}
+
+func unaryContext(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, requestID := requestContext(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+	resp, err := handler(ctx, req)
+	logRPC(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamContext(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, requestID := requestContext(stream.Context())
+	stream.SetHeader(metadata.Pairs("x-request-id", requestID))
+	err := handler(srv, contextStream{ServerStream: stream, ctx: ctx})
+	logRPC(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// contextStream is a server stream with the context given by requestContext.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLogs retrieves audit log entries, newest first. Filters by ?entity=book and
+// ?id=42 and implements pagination.
+func GetAuditLogs(c *gin.Context) {
+	page, pageSize, ok := bindPage(c, 20)
+	if !ok {
+		return
+	}
+	filter := services.AuditFilter{Page: page, PageSize: pageSize}
+
+	filter.Entity = c.Query("entity")
+	if idParam := c.Query("id"); idParam != "" {
+		id, err := strconv.Atoi(idParam)
+		if err != nil || id <= 0 {
+			utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid entity ID"))
+			return
+		}
+		if filter.Entity == "" {
+			utils.ErrorResponse(c, apperrors.BadRequest("invalid_parameter", "The id filter requires an entity"))
+			return
+		}
+		filter.EntityID = uint(id)
+	}
+
+	respondAuditLogs(c, filter)
+}
+
+// GetUserActivity retrieves the changes made by a user, newest first. Implements pagination.
+func GetUserActivity(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid user ID"))
+		return
+	}
+
+	page, pageSize, ok := bindPage(c, 20)
+	if !ok {
+		return
+	}
+	filter := services.AuditFilter{Page: page, PageSize: pageSize}
+
+	if _, err := services.FetchUserFromDB(requestContext(c), "user_"+idParam, id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
+		return
+	}
+
+	filter.ActorID = uint(id)
+	respondAuditLogs(c, filter)
+}
+
+// respondAuditLogs sends the requested page of audit log entries.
+func respondAuditLogs(c *gin.Context, filter services.AuditFilter) {
+	logs, total, err := services.FetchAuditLogs(requestContext(c), filter)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "audit_log"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, gin.H{
+		"page":       filter.Page,
+		"pageSize":   filter.PageSize,
+		"total":      total,
+		"totalPages": (int(total) + filter.PageSize - 1) / filter.PageSize,
+		"data":       logs,
+	})
+}
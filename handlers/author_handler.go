@@ -1,52 +1,82 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
+	"gin-books-api/dto"
 	"gin-books-api/models"
 	"gin-books-api/services"
 	"gin-books-api/utils"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // GetAuthors retrieves all authors and implements caching.
 func GetAuthors(c *gin.Context) {
-	ctx := context.Background()
+	authors, ok := fetchAuthors(c)
+	if !ok {
+		return
+	}
+	utils.JSONResponse(c, http.StatusOK, authors)
+}
+
+// GetAuthorsPage retrieves authors a page at a time, in the pagination envelope of
+// GET /books. It replaces GetAuthors from v2 of the API.
+func GetAuthorsPage(c *gin.Context) {
+	page, pageSize, ok := bindPage(c, 20)
+	if !ok {
+		return
+	}
+	authors, ok := fetchAuthors(c)
+	if !ok {
+		return
+	}
+	utils.JSONResponse(c, http.StatusOK, paginate(authors, page, pageSize))
+}
+
+// fetchAuthors returns all authors from the cache or the database. On failure it sends
+// the error response.
+func fetchAuthors(c *gin.Context) ([]models.Author, bool) {
+	ctx := requestContext(c)
 	cacheKey := "authors_all"
 
 	// Attempt to retrieve cached data
 	var authors []models.Author
 	if cache.GetCachedData(ctx, cacheKey, &authors) {
 		c.Header("X-Data-Source", "cache")
-		utils.JSONResponse(c, http.StatusOK, authors)
-		return
+		return authors, true
 	}
 
 	// If not cached, fetch from database
 	authors, err := services.FetchAuthorsFromDB(ctx, cacheKey)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve authors")
-		return
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
+		return nil, false
 	}
 
 	c.Header("X-Data-Source", "database")
-	utils.JSONResponse(c, http.StatusOK, authors)
+	return authors, true
 }
 
 // GetAuthorByID retrieves an author by its ID and implements caching.
+// Returns schema.org JSON-LD instead when the client accepts application/ld+json.
 func GetAuthorByID(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	idParam := c.Param("id")
 
 	// Validate the author ID
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid author ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid author ID"))
+		return
+	}
+
+	// Linked-data clients ask for schema.org JSON-LD
+	if wantsJSONLD(c) {
+		writeAuthorJSONLD(c, id)
 		return
 	}
 
@@ -63,11 +93,7 @@ func GetAuthorByID(c *gin.Context) {
 	// If not cached, fetch from database
 	authorPtr, err := services.FetchAuthorFromDB(ctx, cacheKey, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Author not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the author")
-		}
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
 		return
 	}
 
@@ -77,14 +103,14 @@ func GetAuthorByID(c *gin.Context) {
 
 // CreateAuthor creates a new author and stores it in the database.
 func CreateAuthor(c *gin.Context) {
-	var author models.Author
-	if err := c.ShouldBindJSON(&author); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.AuthorRequest
+	if !bindRequest(c, &req) {
 		return
 	}
+	author := req.Model()
 
-	if err := services.CreateAuthor(context.Background(), &author); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create author")
+	if err := services.CreateAuthor(requestContext(c), &author); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
 		return
 	}
 
@@ -96,18 +122,50 @@ func UpdateAuthor(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid author ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid author ID"))
 		return
 	}
 
-	var author models.Author
-	if err := c.ShouldBindJSON(&author); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.AuthorRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	author := req.Model()
+
+	if err := services.UpdateAuthor(requestContext(c), id, &author); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, author)
+}
+
+// PatchAuthor applies a JSON Merge Patch or JSON Patch to a author and writes only the
+// columns it changes.
+func PatchAuthor(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid author ID"))
+		return
+	}
+
+	current, err := services.FetchAuthorFromDB(ctx, "author_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
+		return
+	}
+
+	req := dto.NewAuthorRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
 		return
 	}
+	author := req.Model()
 
-	if err := services.UpdateAuthor(context.Background(), id, &author); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update author")
+	if err := services.PatchAuthor(ctx, id, &author, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
 		return
 	}
 
@@ -119,16 +177,12 @@ func DeleteAuthor(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid author ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid author ID"))
 		return
 	}
 
-	if err := services.DeleteAuthor(context.Background(), id); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Author not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete author")
-		}
+	if err := services.DeleteAuthor(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
 		return
 	}
 
@@ -1,101 +1,86 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
+	"gin-books-api/dto"
 	"gin-books-api/models"
 	"gin-books-api/services"
 	"gin-books-api/utils"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // GetBooks retrieves all books along with their publishers, categories, authors, and reviews.
 // Implements pagination and caching.
 func GetBooks(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	cacheKey := "books_all"
 
 	// Pagination parameters
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil || page <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid page number")
+	page, pageSize, ok := bindPage(c, 5)
+	if !ok {
 		return
 	}
 
-	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
-	if err != nil || pageSize <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid page size")
+	// Filter by series, in reading order
+	if seriesParam := c.Query("series_id"); seriesParam != "" {
+		seriesID, err := strconv.Atoi(seriesParam)
+		if err != nil || seriesID <= 0 {
+			utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+			return
+		}
+
+		books, err := services.FetchBooksBySeries(ctx, seriesID)
+		if err != nil {
+			utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+			return
+		}
+
+		c.Header("X-Data-Source", "database")
+		utils.JSONResponse(c, http.StatusOK, paginate(books, page, pageSize))
 		return
 	}
 
 	// Attempt to retrieve cached data
 	var books []models.Book
 	if cache.GetCachedData(ctx, cacheKey, &books) {
-		// Apply pagination to cached data
-		start := (page - 1) * pageSize
-		end := start + pageSize
-		if start > len(books) {
-			start = len(books)
-		}
-		if end > len(books) {
-			end = len(books)
-		}
-		paginatedBooks := books[start:end]
-
 		c.Header("X-Data-Source", "cache")
-		utils.JSONResponse(c, http.StatusOK, gin.H{
-			"page":       page,
-			"pageSize":   pageSize,
-			"total":      len(books),
-			"totalPages": (len(books) + pageSize - 1) / pageSize,
-			"data":       paginatedBooks,
-		})
+		utils.JSONResponse(c, http.StatusOK, paginate(books, page, pageSize))
 		return
 	}
 
 	// If not cached, fetch from database
-	books, err = services.FetchBooksFromDB(ctx, cacheKey)
+	books, err := services.FetchBooksFromDB(ctx, cacheKey)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve books")
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
 		return
 	}
 
-	// Apply pagination
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	if start > len(books) {
-		start = len(books)
-	}
-	if end > len(books) {
-		end = len(books)
-	}
-	paginatedBooks := books[start:end]
-
 	c.Header("X-Data-Source", "database")
-	utils.JSONResponse(c, http.StatusOK, gin.H{
-		"page":       page,
-		"pageSize":   pageSize,
-		"total":      len(books),
-		"totalPages": (len(books) + pageSize - 1) / pageSize,
-		"data":       paginatedBooks,
-	})
+	utils.JSONResponse(c, http.StatusOK, paginate(books, page, pageSize))
 }
 
 // GetBookByID retrieves a book by its ID along with its publisher, categories, author, and reviews.
-// Implements caching and input validation.
+// Implements caching and input validation. Returns schema.org JSON-LD instead when the
+// client accepts application/ld+json.
 func GetBookByID(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	idParam := c.Param("id")
 
 	// Validate the book ID
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
+		return
+	}
+
+	// Linked-data clients ask for schema.org JSON-LD
+	if wantsJSONLD(c) {
+		writeBookJSONLD(c, id)
 		return
 	}
 
@@ -112,11 +97,7 @@ func GetBookByID(c *gin.Context) {
 	// If not cached, fetch from database
 	bookPtr, err := services.FetchBookFromDB(ctx, cacheKey, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Book not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the book")
-		}
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
 		return
 	}
 
@@ -126,14 +107,14 @@ func GetBookByID(c *gin.Context) {
 
 // CreateBook creates a new book and stores it in the database.
 func CreateBook(c *gin.Context) {
-	var book models.Book
-	if err := c.ShouldBindJSON(&book); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.BookRequest
+	if !bindRequest(c, &req) {
 		return
 	}
+	book := req.Model()
 
-	if err := services.CreateBook(context.Background(), &book); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create book")
+	if err := services.CreateBook(requestContext(c), &book); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
 		return
 	}
 
@@ -145,39 +126,85 @@ func UpdateBook(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
 		return
 	}
 
-	var book models.Book
-	if err := c.ShouldBindJSON(&book); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.BookRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	book := req.Model()
+
+	if err := services.UpdateBook(requestContext(c), id, &book); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, book)
+}
+
+// PatchBook applies a JSON Merge Patch or JSON Patch to a book and writes only the
+// columns it changes.
+func PatchBook(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
+		return
+	}
+
+	current, err := services.FetchBookFromDB(ctx, "book_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+
+	req := dto.NewBookRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
 		return
 	}
+	book := req.Model()
 
-	if err := services.UpdateBook(context.Background(), id, &book); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update book")
+	if err := services.PatchBook(ctx, id, &book, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
 		return
 	}
 
 	utils.JSONResponse(c, http.StatusOK, book)
 }
 
+// GetBookSeries retrieves the series a book belongs to, with the previous and next entries.
+func GetBookSeries(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
+		return
+	}
+
+	navigation, err := services.FetchBookSeriesNavigation(requestContext(c), id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, navigation)
+}
+
 // DeleteBook deletes a book by its ID.
 func DeleteBook(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
 		return
 	}
 
-	if err := services.DeleteBook(context.Background(), id); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Book not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete book")
-		}
+	if err := services.DeleteBook(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
 		return
 	}
 
@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"context"
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
+	"gin-books-api/dto"
 	"gin-books-api/models"
 	"gin-books-api/services"
 	"gin-books-api/utils"
@@ -10,12 +11,11 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-// GetCategories retrieves all categories and implements caching.
+// GetCategories retrieves all categories as a tree and implements caching.
 func GetCategories(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	cacheKey := "categories_all"
 
 	// Attempt to retrieve cached data
@@ -29,7 +29,7 @@ func GetCategories(c *gin.Context) {
 	// If not cached, fetch from database
 	categories, err := services.FetchCategoriesFromDB(ctx, cacheKey)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve categories")
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
 		return
 	}
 
@@ -39,13 +39,13 @@ func GetCategories(c *gin.Context) {
 
 // GetCategoryByID retrieves a category by its ID and implements caching.
 func GetCategoryByID(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	idParam := c.Param("id")
 
 	// Validate the category ID
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid category ID"))
 		return
 	}
 
@@ -62,11 +62,7 @@ func GetCategoryByID(c *gin.Context) {
 	// If not cached, fetch from database
 	categoryPtr, err := services.FetchCategoryFromDB(ctx, cacheKey, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Category not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the category")
-		}
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
 		return
 	}
 
@@ -74,16 +70,34 @@ func GetCategoryByID(c *gin.Context) {
 	utils.JSONResponse(c, http.StatusOK, categoryPtr)
 }
 
+// GetCategoryBooks retrieves the books of a category and of all of its subcategories.
+func GetCategoryBooks(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid category ID"))
+		return
+	}
+
+	books, err := services.FetchCategoryBooks(requestContext(c), id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, books)
+}
+
 // CreateCategory creates a new category and stores it in the database.
 func CreateCategory(c *gin.Context) {
-	var category models.Category
-	if err := c.ShouldBindJSON(&category); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.CategoryRequest
+	if !bindRequest(c, &req) {
 		return
 	}
+	category := req.Model()
 
-	if err := services.CreateCategory(context.Background(), &category); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create category")
+	if err := services.CreateCategory(requestContext(c), &category); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
 		return
 	}
 
@@ -95,39 +109,70 @@ func UpdateCategory(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid category ID"))
 		return
 	}
 
-	var category models.Category
-	if err := c.ShouldBindJSON(&category); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.CategoryRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	category := req.Model()
+
+	if err := services.UpdateCategory(requestContext(c), id, &category); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, category)
+}
+
+// PatchCategory applies a JSON Merge Patch or JSON Patch to a category and writes only the
+// columns it changes.
+func PatchCategory(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid category ID"))
+		return
+	}
+
+	current, err := services.FetchCategoryFromDB(ctx, "category_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
+		return
+	}
+
+	req := dto.NewCategoryRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
 		return
 	}
+	category := req.Model()
 
-	if err := services.UpdateCategory(context.Background(), id, &category); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update category")
+	if err := services.PatchCategory(ctx, id, &category, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
 		return
 	}
 
 	utils.JSONResponse(c, http.StatusOK, category)
 }
 
-// DeleteCategory deletes an category by its ID.
+// DeleteCategory deletes a category by its ID. The required strategy query parameter
+// decides what happens to its subcategories and books: "reparent" moves them to the
+// parent category, "refuse" rejects the delete unless the category is empty.
 func DeleteCategory(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid category ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid category ID"))
 		return
 	}
 
-	if err := services.DeleteCategory(context.Background(), id); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Category not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete category")
-		}
+	strategy := c.Query("strategy")
+	if err := services.DeleteCategory(requestContext(c), id, strategy); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
 		return
 	}
 
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/biblio"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCitationBatch is the number of books one batch export may ask for.
+const maxCitationBatch = 500
+
+// ExportBookCitation returns a book as MARCXML, Dublin Core, BibTeX, RIS or CSL-JSON.
+func ExportBookCitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
+		return
+	}
+
+	writeCitations(c, []uint{uint(id)}, "book-"+c.Param("id"))
+}
+
+// ExportBookCitations returns the books listed in ?ids=1,2,3 in one document.
+func ExportBookCitations(c *gin.Context) {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, part := range strings.Split(c.Query("ids"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || id == 0 {
+			utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID "+strconv.Quote(part)))
+			return
+		}
+		if !seen[uint(id)] {
+			seen[uint(id)] = true
+			ids = append(ids, uint(id))
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxCitationBatch {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_ids",
+			"ids must list between 1 and "+strconv.Itoa(maxCitationBatch)+" book IDs"))
+		return
+	}
+
+	writeCitations(c, ids, "books")
+}
+
+// writeCitations renders the books in the format named by ?format=.
+func writeCitations(c *gin.Context, ids []uint, filename string) {
+	formatName := c.Query("format")
+	format, ok := biblio.Formats[formatName]
+	if !ok {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_format",
+			"format must be marcxml, dc, bibtex, ris or csl-json"))
+		return
+	}
+
+	books, err := services.FetchBooksByIDs(requestContext(c), ids)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+	records := make([]biblio.Record, len(books))
+	for i, book := range books {
+		records[i] = biblio.NewRecord(book)
+	}
+
+	var body bytes.Buffer
+	if err := format.Write(&body, records); err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return
+	}
+	c.Header("Content-Disposition", `inline; filename="`+filename+"."+format.Extension+`"`)
+	c.Data(http.StatusOK, format.ContentType+"; charset=utf-8", body.Bytes())
+}
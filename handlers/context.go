@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"gin-books-api/logging"
+	"gin-books-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestContext returns the context passed to the services for a request. It is the
+// context of the request, so that the queries of a request are cancelled when its
+// client goes away, and it carries the acting user from the X-User-ID header, if any,
+// so that changes are attributed to that user in the audit trail and the logs.
+func requestContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if id, err := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 64); err == nil && id > 0 {
+		ctx = services.WithActor(ctx, uint(id))
+		ctx = logging.With(ctx, slog.Uint64("user_id", id))
+	}
+	return ctx
+}
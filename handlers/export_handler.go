@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportEntity streams all books, reviews or loans as CSV, NDJSON or XLSX. CSV and
+// NDJSON are gzip-compressed for clients that accept it.
+func ExportEntity(c *gin.Context) {
+	entity := c.Param("entity")
+	if !services.IsExportable(entity) {
+		utils.ErrorResponse(c, services.ErrUnknownExport)
+		return
+	}
+
+	formatName := c.DefaultQuery("format", "csv")
+	format, ok := utils.ExportFormats[formatName]
+	if !ok {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_format", "format must be csv, ndjson or xlsx"))
+		return
+	}
+
+	var filter services.ExportFilter
+	for param, target := range map[string]*int{
+		"series_id": &filter.SeriesID,
+		"book_id":   &filter.BookID,
+		"user_id":   &filter.UserID,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid "+param))
+			return
+		}
+		*target = id
+	}
+
+	// Nothing is written until the query has returned its columns, so that a failing
+	// query still gets a problem response
+	var out io.Writer = c.Writer
+	var writer utils.ExportWriter
+	header := func(columns []string) error {
+		c.Header("Content-Type", format.ContentType)
+		c.Header("Content-Disposition", `attachment; filename="`+entity+"."+format.Extension+`"`)
+		c.Header("Vary", "Accept-Encoding")
+		if format.Compress && strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Header("Content-Encoding", "gzip")
+			out = gzip.NewWriter(c.Writer)
+		}
+		c.Status(http.StatusOK)
+
+		writer = format.NewWriter(out)
+		return writer.Header(columns)
+	}
+	row := func(values []interface{}) error {
+		return writer.Row(values)
+	}
+
+	err := services.ExportRows(requestContext(c), entity, filter, header, row)
+	if err == nil && writer != nil {
+		err = writer.Close()
+	}
+	if gz, ok := out.(*gzip.Writer); ok {
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		if writer == nil {
+			utils.ErrorResponse(c, apperrors.FromDB(err, entity))
+			return
+		}
+		// The response is under way; all that is left is to cut it short
+		slog.ErrorContext(c.Request.Context(), "Export failed", "entity", entity, "error", err)
+		c.Abort()
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/services"
+	"gin-books-api/syndication"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedFormat is a format a feed can be served in, named by the extension of its path.
+type feedFormat struct {
+	contentType string
+	write       func(w io.Writer, feed syndication.Feed) error
+}
+
+var feedFormats = map[string]feedFormat{
+	"atom": {syndication.TypeAtom, syndication.WriteAtom},
+	"rss":  {syndication.TypeRSS, syndication.WriteRSS},
+}
+
+// GetNewBooksFeed returns the books most recently added to the catalogue, at
+// /feeds/new.atom or /feeds/new.rss.
+func GetNewBooksFeed(c *gin.Context) {
+	name, extension, ok := parseFeedFile(c)
+	if !ok {
+		return
+	}
+	if name != "new" {
+		utils.ErrorResponse(c, apperrors.NotFound("feed_not_found", "Feed not found"))
+		return
+	}
+
+	writeFeed(c, extension, "feed_new", services.BookFilter{}, syndication.Feed{
+		Title:       "New books",
+		Description: "Books recently added to the catalogue",
+		Link:        "/books",
+	})
+}
+
+// GetCategoryFeed returns the books most recently added to a category or its
+// subcategories, at /feeds/categories/:id.atom or .rss.
+func GetCategoryFeed(c *gin.Context) {
+	id, extension, ok := parseFeedID(c, "category")
+	if !ok {
+		return
+	}
+	category, ok := lookupCategory(c, id)
+	if !ok {
+		return
+	}
+
+	writeFeed(c, extension, "feed_category_"+strconv.Itoa(id), services.BookFilter{CategoryID: uint(id)}, syndication.Feed{
+		Title:       "New books in " + category.Name,
+		Description: "Books recently added to " + category.Name + " and its subcategories",
+		Link:        "/categories/" + strconv.Itoa(id) + "/books",
+	})
+}
+
+// GetAuthorFeed returns the books of an author most recently added to the catalogue, at
+// /feeds/authors/:id.atom or .rss.
+func GetAuthorFeed(c *gin.Context) {
+	id, extension, ok := parseFeedID(c, "author")
+	if !ok {
+		return
+	}
+	author, ok := lookupAuthor(c, id)
+	if !ok {
+		return
+	}
+
+	writeFeed(c, extension, "feed_author_"+strconv.Itoa(id), services.BookFilter{AuthorID: uint(id)}, syndication.Feed{
+		Title:       "New books by " + author.Name,
+		Description: "Books by " + author.Name + " recently added to the catalogue",
+		Link:        "/authors/" + strconv.Itoa(id),
+	})
+}
+
+// GetPublisherFeed returns the books of a publisher most recently added to the
+// catalogue, at /feeds/publishers/:id.atom or .rss.
+func GetPublisherFeed(c *gin.Context) {
+	id, extension, ok := parseFeedID(c, "publisher")
+	if !ok {
+		return
+	}
+	publisher, ok := lookupPublisher(c, id)
+	if !ok {
+		return
+	}
+
+	writeFeed(c, extension, "feed_publisher_"+strconv.Itoa(id), services.BookFilter{PublisherID: uint(id)}, syndication.Feed{
+		Title:       "New books from " + publisher.Name,
+		Description: "Books from " + publisher.Name + " recently added to the catalogue",
+		Link:        "/publishers/" + strconv.Itoa(id),
+	})
+}
+
+// parseFeedFile splits the :file parameter, such as "new.atom", into its name and its
+// format extension.
+func parseFeedFile(c *gin.Context) (string, string, bool) {
+	name, extension, found := strings.Cut(c.Param("file"), ".")
+	if _, ok := feedFormats[extension]; !found || !ok {
+		utils.ErrorResponse(c, apperrors.NotFound("feed_not_found", "Feeds are served as .atom or .rss"))
+		return "", "", false
+	}
+	return name, extension, true
+}
+
+// parseFeedID reads a :file parameter such as "12.rss" that names an entity by its ID.
+func parseFeedID(c *gin.Context, entity string) (int, string, bool) {
+	name, extension, ok := parseFeedFile(c)
+	if !ok {
+		return 0, "", false
+	}
+	id, err := strconv.Atoi(name)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid "+entity+" ID"))
+		return 0, "", false
+	}
+	return id, extension, true
+}
+
+// writeFeed fills feed with the books selected by filter and sends it in the format
+// named by extension. The response carries an ETag of the document, so that readers
+// polling with If-None-Match get 304 Not Modified until the feed changes. It has no
+// Last-Modified: the dates of the books miss changes such as a deleted book or a
+// renamed author, after which If-Modified-Since would wrongly get 304.
+func writeFeed(c *gin.Context, extension, cacheKey string, filter services.BookFilter, feed syndication.Feed) {
+	ctx := requestContext(c)
+	format := feedFormats[extension]
+
+	var books []services.FeedBook
+	if !cache.GetCachedData(ctx, cacheKey, &books) {
+		var err error
+		if books, err = services.FetchFeedBooks(ctx, cacheKey, filter); err != nil {
+			utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+			return
+		}
+	}
+
+	base := requestBaseURL(c)
+	feed.ID = base + c.Request.URL.Path
+	feed.Self = base + c.Request.URL.Path
+	feed.Link = base + resourcePrefix + feed.Link
+	feed.Updated = time.Unix(0, 0)
+	for _, book := range books {
+		if book.UpdatedAt.After(feed.Updated) {
+			feed.Updated = book.UpdatedAt
+		}
+		id := strconv.FormatUint(uint64(book.ID), 10)
+		feed.Items = append(feed.Items, syndication.Item{
+			ID:        opdsBookIDPrefix + id,
+			Title:     book.Title,
+			Link:      base + resourcePrefix + "/books/" + id,
+			Summary:   book.Description,
+			Author:    book.Author,
+			Published: book.CreatedAt,
+			Updated:   book.UpdatedAt,
+		})
+	}
+
+	var body bytes.Buffer
+	if err := format.write(&body, feed); err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Content-Type", format.contentType+"; charset=utf-8")
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(body.Bytes()))
+}
+
+// resourcePrefix is the path of the version of the API that feeds, catalogues, the
+// sitemap and linked data link to, which are not versioned themselves.
+const resourcePrefix = "/api/v1"
+
+// requestBaseURL returns the scheme and host the client used to reach the API, for the
+// absolute links feeds need.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/graph"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PostGraphQL runs a GraphQL query or mutation sent as JSON. Errors of the query are
+// reported in the errors of the response, with status 200; only a body that is not a
+// GraphQL request gets a problem response.
+func PostGraphQL(c *gin.Context) {
+	var req graph.Request
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		utils.ErrorResponse(c, apperrors.BadRequest("malformed_body", "The body must be a JSON object with a query"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, graph.Do(requestContext(c), req, false))
+}
+
+// GetGraphQL runs a GraphQL query given in the query parameter, with its variables as
+// JSON in variables. Mutations are refused, since GET requests must not change anything.
+func GetGraphQL(c *gin.Context) {
+	req := graph.Request{Query: c.Query("query"), OperationName: c.Query("operationName")}
+	if req.Query == "" {
+		utils.ErrorResponse(c, apperrors.BadRequest("missing_query", "The query parameter is required"))
+		return
+	}
+	if variables := c.Query("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+			utils.ErrorResponse(c, apperrors.BadRequest("malformed_variables", "The variables parameter must be a JSON object"))
+			return
+		}
+	}
+
+	utils.JSONResponse(c, http.StatusOK, graph.Do(requestContext(c), req, true))
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveness is the response of GET /healthz.
+type liveness struct {
+	Status string `json:"status"`
+}
+
+// GetHealthz reports that the process is alive. It checks no dependency, so that an
+// outage of the database does not get every instance restarted.
+func GetHealthz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	utils.JSONResponse(c, http.StatusOK, liveness{Status: "ok"})
+}
+
+// GetReadyz reports whether the instance should receive traffic, with the state of
+// each dependency: 200 when ready, or degraded without Redis, and 503 when the
+// database is down, its schema is behind, or the instance is draining.
+func GetReadyz(c *gin.Context) {
+	readiness := services.CheckReadiness(c.Request.Context())
+	status := http.StatusOK
+	if !readiness.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	utils.JSONResponse(c, status, readiness)
+}
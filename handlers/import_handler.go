@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/dto"
+	"gin-books-api/marc"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ImportBooks creates or updates books from a CSV, JSON array or NDJSON body and reports
+// the outcome of every row. With ?dry_run=true nothing is written.
+func ImportBooks(c *gin.Context) {
+	dryRun, ok := bindDryRun(c)
+	if !ok {
+		return
+	}
+
+	decoder, err := dto.NewBookImportDecoder(c.ContentType(), c.Request.Body)
+	if err != nil {
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	var row dto.BookImportRow
+	count, broken := 0, false
+	next := func() (*services.ImportRow, error) {
+		if broken {
+			return nil, io.EOF
+		}
+		err := decoder.Next(&row)
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		count++
+		imported := &services.ImportRow{Row: count}
+
+		var rowErr *dto.RowError
+		if errors.As(err, &rowErr) {
+			imported.Errors = rowErr.Details
+			return imported, nil
+		}
+		if err != nil {
+			// The input cannot be read past this row: report it and end the import
+			broken = true
+			imported.Errors = []apperrors.FieldError{{
+				Code:    "malformed_body",
+				Message: err.Error() + "; the rest of the input was not imported",
+			}}
+			return imported, nil
+		}
+
+		if err := binding.Validator.ValidateStruct(&row); err != nil {
+			imported.Errors = utils.ValidationErrors(err)
+			return imported, nil
+		}
+		imported.Book = row.Model()
+		imported.Author = row.Author
+		imported.Publisher = row.Publisher
+		imported.Category = row.Category
+		return imported, nil
+	}
+
+	report, err := services.ImportBooks(requestContext(c), next, dryRun)
+	respondImport(c, report, err)
+}
+
+// ImportMARC creates or updates books from MARC 21 records, sent as binary ISO 2709
+// (application/marc) or MARCXML, and reports what was created or matched for every
+// record. With ?dry_run=true nothing is written.
+func ImportMARC(c *gin.Context) {
+	dryRun, ok := bindDryRun(c)
+	if !ok {
+		return
+	}
+
+	var reader marc.Reader
+	switch c.ContentType() {
+	case "application/marc", "application/octet-stream":
+		reader = marc.NewBinaryReader(c.Request.Body)
+	case "application/marcxml+xml", "application/xml", "text/xml":
+		reader = marc.NewXMLReader(c.Request.Body)
+	default:
+		utils.ErrorResponse(c, apperrors.New(apperrors.KindUnsupportedMediaType, "unsupported_media_type",
+			"MARC import accepts application/marc or application/marcxml+xml"))
+		return
+	}
+
+	report, err := services.ImportBooks(requestContext(c), services.MARCImportRows(reader), dryRun)
+	respondImport(c, report, err)
+}
+
+// respondImport sends the report of an import. An import that failed after committing
+// some of its rows is answered with the status of the error, and the report of those
+// rows with the error in it, so that the client knows what was imported.
+func respondImport(c *gin.Context, report *services.ImportReport, err error) {
+	if err == nil {
+		utils.JSONResponse(c, http.StatusOK, report)
+		return
+	}
+	appErr := apperrors.FromDB(err, "book")
+	if report == nil || len(report.Rows) == 0 {
+		utils.ErrorResponse(c, appErr)
+		return
+	}
+
+	if appErr.Kind.Status() >= http.StatusInternalServerError && appErr.Err != nil {
+		slog.ErrorContext(c.Request.Context(), "Import failed", "path", c.Request.URL.Path, "rows", len(report.Rows), "error", appErr.Err)
+	}
+	report.Error = &services.ImportError{Code: appErr.Code, Message: appErr.Message}
+	utils.JSONResponse(c, appErr.Kind.Status(), report)
+}
+
+// bindDryRun reads ?dry_run=, which defaults to false.
+func bindDryRun(c *gin.Context) (bool, bool) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_query", "dry_run must be true or false"))
+		return false, false
+	}
+	return dryRun, true
+}
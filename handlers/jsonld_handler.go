@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/schemaorg"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sitemapLimit is the number of URLs the sitemap protocol allows in one file.
+const sitemapLimit = 50000
+
+// wantsJSONLD reports whether the client asked for JSON-LD rather than plain JSON,
+// which stays the default.
+func wantsJSONLD(c *gin.Context) bool {
+	c.Header("Vary", "Accept")
+	return c.NegotiateFormat(gin.MIMEJSON, schemaorg.ContentType) == schemaorg.ContentType
+}
+
+// writeBookJSONLD sends a book as a schema.org Book.
+func writeBookJSONLD(c *gin.Context, id int) {
+	ctx := requestContext(c)
+	books, err := services.FetchBooksByIDs(ctx, []uint{uint(id)})
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+	ratings, err := fetchRatings(c, []uint{uint(id)})
+	if err != nil {
+		return
+	}
+
+	writeJSONLD(c, schemaorg.NewBook(books[0], ratings[uint(id)], requestBaseURL(c)+resourcePrefix))
+}
+
+// writeAuthorJSONLD sends an author as a schema.org Person with the books they wrote.
+func writeAuthorJSONLD(c *gin.Context, id int) {
+	author, ok := lookupAuthor(c, id)
+	if !ok {
+		return
+	}
+
+	ids := make([]uint, len(author.Book))
+	for i, book := range author.Book {
+		ids[i] = book.ID
+	}
+	books, err := services.FetchBooksByIDs(requestContext(c), ids)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+	ratings, err := fetchRatings(c, ids)
+	if err != nil {
+		return
+	}
+
+	writeJSONLD(c, schemaorg.NewPerson(*author, books, ratings, requestBaseURL(c)+resourcePrefix))
+}
+
+// fetchRatings returns the ratings of the given books. On failure it sends the error
+// response.
+func fetchRatings(c *gin.Context, ids []uint) (map[uint]schemaorg.Rating, error) {
+	ratings := make(map[uint]schemaorg.Rating, len(ids))
+	if len(ids) == 0 {
+		return ratings, nil
+	}
+	found, err := services.FetchBookRatings(requestContext(c), ids)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
+		return nil, err
+	}
+	for id, rating := range found {
+		ratings[id] = schemaorg.Rating{Count: rating.Count, Average: rating.Average}
+	}
+	return ratings, nil
+}
+
+// writeJSONLD sends a JSON-LD document.
+func writeJSONLD(c *gin.Context, doc interface{}) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return
+	}
+	c.Data(http.StatusOK, schemaorg.ContentType+"; charset=utf-8", body.Bytes())
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// GetSitemap lists every book and author for search engines. A catalogue with more
+// URLs than one sitemap may list gets a sitemap index of ?page=1, ?page=2 and so on.
+func GetSitemap(c *gin.Context) {
+	entries, err := services.FetchSitemapEntries(requestContext(c))
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+
+	base := requestBaseURL(c)
+	pages := (len(entries) + sitemapLimit - 1) / sitemapLimit
+	var doc interface{}
+	switch page := c.Query("page"); {
+	case page == "" && pages > 1:
+		index := sitemapIndex{}
+		for i := 1; i <= pages; i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: base + "/sitemap.xml?page=" + strconv.Itoa(i)})
+		}
+		doc = index
+	default:
+		number := 1
+		if page != "" {
+			number, err = strconv.Atoi(page)
+			if err != nil || number < 1 || number > pages {
+				utils.ErrorResponse(c, apperrors.NotFound("sitemap_not_found", "Sitemap page not found"))
+				return
+			}
+		}
+		entries = entries[(number-1)*sitemapLimit : min(number*sitemapLimit, len(entries))]
+
+		urls := sitemapURLSet{URLs: make([]sitemapURL, len(entries))}
+		for i, entry := range entries {
+			urls.URLs[i].Loc = base + resourcePrefix + entry.Path
+			if !entry.LastModified.IsZero() {
+				urls.URLs[i].LastMod = entry.LastModified.UTC().Format("2006-01-02")
+			}
+		}
+		doc = urls
+	}
+
+	body := bytes.NewBufferString(xml.Header)
+	encoder := xml.NewEncoder(body)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return
+	}
+	body.WriteString("\n")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body.Bytes())
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/dto"
+	"gin-books-api/models"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLoans retrieves the books currently on loan.
+func GetLoans(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "loans_all"
+
+	var loans []models.BorrowedBook
+	if cache.GetCachedData(ctx, cacheKey, &loans) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, loans)
+		return
+	}
+
+	loans, err := services.FetchLoansFromDB(ctx, cacheKey)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "loan"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, loans)
+}
+
+// GetLoanByID retrieves a loan by its ID.
+func GetLoanByID(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid loan ID"))
+		return
+	}
+
+	cacheKey := "loan_" + idParam
+	var loan models.BorrowedBook
+	if cache.GetCachedData(ctx, cacheKey, &loan) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, loan)
+		return
+	}
+
+	loanPtr, err := services.FetchLoanFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "loan"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, loanPtr)
+}
+
+// BorrowBook lends a book to a user. It fails with 409 if the book is already on loan.
+func BorrowBook(c *gin.Context) {
+	var req dto.LoanRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	loan := req.Model()
+
+	if err := services.BorrowBook(requestContext(c), &loan); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "loan"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusCreated, loan)
+}
+
+// ReturnBook ends a loan, making the book available again.
+func ReturnBook(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid loan ID"))
+		return
+	}
+
+	if err := services.ReturnBook(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "loan"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, gin.H{"message": "Book returned successfully"})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/models"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The lookups below return a row from the cache or the database for handlers that only
+// need it to name or check what they list. On failure they send the error response.
+
+// lookupCategory returns the category with the given ID.
+func lookupCategory(c *gin.Context, id int) (*models.Category, bool) {
+	ctx := requestContext(c)
+	cacheKey := "category_" + strconv.Itoa(id)
+
+	var category models.Category
+	if cache.GetCachedData(ctx, cacheKey, &category) {
+		return &category, true
+	}
+	fetched, err := services.FetchCategoryFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
+		return nil, false
+	}
+	return fetched, true
+}
+
+// lookupAuthor returns the author with the given ID.
+func lookupAuthor(c *gin.Context, id int) (*models.Author, bool) {
+	ctx := requestContext(c)
+	cacheKey := "author_" + strconv.Itoa(id)
+
+	var author models.Author
+	if cache.GetCachedData(ctx, cacheKey, &author) {
+		return &author, true
+	}
+	fetched, err := services.FetchAuthorFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
+		return nil, false
+	}
+	return fetched, true
+}
+
+// lookupPublisher returns the publisher with the given ID.
+func lookupPublisher(c *gin.Context, id int) (*models.Publisher, bool) {
+	ctx := requestContext(c)
+	cacheKey := "publisher_" + strconv.Itoa(id)
+
+	var publisher models.Publisher
+	if cache.GetCachedData(ctx, cacheKey, &publisher) {
+		return &publisher, true
+	}
+	fetched, err := services.FetchPublisherFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
+		return nil, false
+	}
+	return fetched, true
+}
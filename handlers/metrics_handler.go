@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"sync"
+
+	"gin-books-api/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsHandler is made on first use, once the logger it reports errors to is set up.
+var metricsHandler = sync.OnceValue(metrics.Handler)
+
+// GetMetrics serves the Prometheus metrics of the API.
+func GetMetrics(c *gin.Context) {
+	metricsHandler().ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoRoute answers requests for unknown routes with a problem response.
+func NoRoute(c *gin.Context) {
+	utils.ErrorResponse(c, apperrors.NotFound("route_not_found", "No route matches "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+// Recover answers requests whose handler panicked with a problem response, logging the
+// panic and its stack.
+func Recover(c *gin.Context, recovered any) {
+	utils.ErrorResponse(c, apperrors.Internal(fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())))
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/models"
+	"gin-books-api/opds"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	opdsRoot         = "/opds"
+	opdsSearch       = "/opds/search.xml"
+	opdsPageSize     = 20
+	opdsMaxPageSize  = 100
+	opdsIDPrefix     = "urn:gin-books:opds:"
+	opdsBookIDPrefix = "urn:gin-books:book:"
+)
+
+// GetOPDSRoot returns the navigation feed at the root of the catalogue.
+func GetOPDSRoot(c *gin.Context) {
+	writeOPDS(c, opds.Feed{
+		ID:     opdsIDPrefix + "root",
+		Title:  "Gin Books catalogue",
+		Kind:   opds.Navigation,
+		Self:   opdsRoot,
+		Search: opdsSearch,
+		Navigation: []opds.NavigationItem{
+			{ID: opdsIDPrefix + "new", Title: "New arrivals", Summary: "The most recently added books",
+				Href: opdsRoot + "/new", Kind: opds.Acquisition, Rel: opds.RelSortNew},
+			{ID: opdsIDPrefix + "books", Title: "All books", Summary: "Every book, by title",
+				Href: opdsRoot + "/books", Kind: opds.Acquisition},
+			{ID: opdsIDPrefix + "categories", Title: "Categories", Summary: "Books by category",
+				Href: opdsRoot + "/categories", Kind: opds.Navigation},
+			{ID: opdsIDPrefix + "authors", Title: "Authors", Summary: "Books by author",
+				Href: opdsRoot + "/authors", Kind: opds.Navigation},
+			{ID: opdsIDPrefix + "publishers", Title: "Publishers", Summary: "Books by publisher",
+				Href: opdsRoot + "/publishers", Kind: opds.Navigation},
+		},
+	})
+}
+
+// GetOPDSNewArrivals returns the acquisition feed of books, most recently added first.
+func GetOPDSNewArrivals(c *gin.Context) {
+	writeOPDSBooks(c, opds.Feed{ID: opdsIDPrefix + "new", Title: "New arrivals", Up: opdsRoot},
+		services.BookFilter{Newest: true})
+}
+
+// GetOPDSBooks returns the acquisition feed of all books by title. With ?q= it returns
+// the books whose title contains the query, which is where OpenSearch sends searches.
+func GetOPDSBooks(c *gin.Context) {
+	feed := opds.Feed{ID: opdsIDPrefix + "books", Title: "All books", Up: opdsRoot}
+	query := strings.TrimSpace(c.Query("q"))
+	if query != "" {
+		feed.ID += ":search:" + url.QueryEscape(query)
+		feed.Title = "Search results for " + strconv.Quote(query)
+	}
+	writeOPDSBooks(c, feed, services.BookFilter{Title: query})
+}
+
+// GetOPDSCategories returns the navigation feed of categories, subcategories following
+// their parent.
+func GetOPDSCategories(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "categories_all"
+
+	var categories []models.Category
+	if !cache.GetCachedData(ctx, cacheKey, &categories) {
+		var err error
+		if categories, err = services.FetchCategoriesFromDB(ctx, cacheKey); err != nil {
+			utils.ErrorResponse(c, apperrors.FromDB(err, "category"))
+			return
+		}
+	}
+
+	var items []opds.NavigationItem
+	var walk func(categories []models.Category, prefix string) int
+	walk = func(categories []models.Category, prefix string) int {
+		total := 0
+		for _, category := range categories {
+			index := len(items)
+			id := strconv.FormatUint(uint64(category.ID), 10)
+			items = append(items, opds.NavigationItem{
+				ID:    opdsIDPrefix + "category:" + id,
+				Title: prefix + category.Name,
+				Href:  opdsRoot + "/categories/" + id,
+				Kind:  opds.Acquisition,
+			})
+			count := len(category.Books) + walk(category.Children, prefix+category.Name+" / ")
+			items[index].Count = count
+			total += count
+		}
+		return total
+	}
+	walk(categories, "")
+
+	writeOPDS(c, opds.Feed{
+		ID:         opdsIDPrefix + "categories",
+		Title:      "Categories",
+		Kind:       opds.Navigation,
+		Self:       opdsRoot + "/categories",
+		Up:         opdsRoot,
+		Navigation: items,
+	})
+}
+
+// GetOPDSCategory returns the acquisition feed of the books of a category and of its
+// subcategories.
+func GetOPDSCategory(c *gin.Context) {
+	id, ok := bindOPDSID(c, "category")
+	if !ok {
+		return
+	}
+
+	category, ok := lookupCategory(c, id)
+	if !ok {
+		return
+	}
+
+	writeOPDSBooks(c, opds.Feed{
+		ID:    opdsIDPrefix + "category:" + c.Param("id"),
+		Title: category.Name,
+		Up:    opdsRoot + "/categories",
+	}, services.BookFilter{CategoryID: uint(id)})
+}
+
+// GetOPDSAuthors returns the navigation feed of authors.
+func GetOPDSAuthors(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "authors_all"
+
+	var authors []models.Author
+	if !cache.GetCachedData(ctx, cacheKey, &authors) {
+		var err error
+		if authors, err = services.FetchAuthorsFromDB(ctx, cacheKey); err != nil {
+			utils.ErrorResponse(c, apperrors.FromDB(err, "author"))
+			return
+		}
+	}
+
+	items := make([]opds.NavigationItem, len(authors))
+	for i, author := range authors {
+		id := strconv.FormatUint(uint64(author.ID), 10)
+		items[i] = opds.NavigationItem{
+			ID:    opdsIDPrefix + "author:" + id,
+			Title: author.Name,
+			Href:  opdsRoot + "/authors/" + id,
+			Kind:  opds.Acquisition,
+			Count: len(author.Book),
+		}
+	}
+
+	writeOPDS(c, opds.Feed{
+		ID:         opdsIDPrefix + "authors",
+		Title:      "Authors",
+		Kind:       opds.Navigation,
+		Self:       opdsRoot + "/authors",
+		Up:         opdsRoot,
+		Navigation: items,
+	})
+}
+
+// GetOPDSAuthor returns the acquisition feed of the books of an author.
+func GetOPDSAuthor(c *gin.Context) {
+	id, ok := bindOPDSID(c, "author")
+	if !ok {
+		return
+	}
+
+	author, ok := lookupAuthor(c, id)
+	if !ok {
+		return
+	}
+
+	writeOPDSBooks(c, opds.Feed{
+		ID:    opdsIDPrefix + "author:" + c.Param("id"),
+		Title: author.Name,
+		Up:    opdsRoot + "/authors",
+	}, services.BookFilter{AuthorID: uint(id)})
+}
+
+// GetOPDSPublishers returns the navigation feed of publishers.
+func GetOPDSPublishers(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "publishers_all"
+
+	var publishers []models.Publisher
+	if !cache.GetCachedData(ctx, cacheKey, &publishers) {
+		var err error
+		if publishers, err = services.FetchPublishersFromDB(ctx, cacheKey); err != nil {
+			utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
+			return
+		}
+	}
+
+	items := make([]opds.NavigationItem, len(publishers))
+	for i, publisher := range publishers {
+		id := strconv.FormatUint(uint64(publisher.ID), 10)
+		items[i] = opds.NavigationItem{
+			ID:    opdsIDPrefix + "publisher:" + id,
+			Title: publisher.Name,
+			Href:  opdsRoot + "/publishers/" + id,
+			Kind:  opds.Acquisition,
+			Count: len(publisher.Books),
+		}
+	}
+
+	writeOPDS(c, opds.Feed{
+		ID:         opdsIDPrefix + "publishers",
+		Title:      "Publishers",
+		Kind:       opds.Navigation,
+		Self:       opdsRoot + "/publishers",
+		Up:         opdsRoot,
+		Navigation: items,
+	})
+}
+
+// GetOPDSPublisher returns the acquisition feed of the books of a publisher.
+func GetOPDSPublisher(c *gin.Context) {
+	id, ok := bindOPDSID(c, "publisher")
+	if !ok {
+		return
+	}
+
+	publisher, ok := lookupPublisher(c, id)
+	if !ok {
+		return
+	}
+
+	writeOPDSBooks(c, opds.Feed{
+		ID:    opdsIDPrefix + "publisher:" + c.Param("id"),
+		Title: publisher.Name,
+		Up:    opdsRoot + "/publishers",
+	}, services.BookFilter{PublisherID: uint(id)})
+}
+
+// GetOPDSSearch returns the OpenSearch description of the title search.
+func GetOPDSSearch(c *gin.Context) {
+	var body bytes.Buffer
+	if err := opds.WriteOpenSearchDescription(&body, opdsRoot+"/books?q={searchTerms}"); err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return
+	}
+	c.Data(http.StatusOK, opds.TypeOpenSearch+"; charset=utf-8", body.Bytes())
+}
+
+// bindOPDSID reads the ID of the entity whose books a feed lists.
+func bindOPDSID(c *gin.Context, entity string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid "+entity+" ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// writeOPDSBooks fills feed with the page of books selected by filter and ?page= and
+// ?pageSize=, and sends it.
+func writeOPDSBooks(c *gin.Context, feed opds.Feed, filter services.BookFilter) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_page", "Invalid page number"))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(opdsPageSize)))
+	if err != nil || pageSize <= 0 || pageSize > opdsMaxPageSize {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_page_size",
+			"pageSize must be between 1 and "+strconv.Itoa(opdsMaxPageSize)))
+		return
+	}
+	filter.Page, filter.PageSize = page, pageSize
+
+	books, total, err := services.FetchBookPage(requestContext(c), filter)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "book"))
+		return
+	}
+
+	path := c.Request.URL.Path
+	pageHref := func(page int) string {
+		query := url.Values{}
+		if filter.Title != "" {
+			query.Set("q", filter.Title)
+		}
+		if page > 1 {
+			query.Set("page", strconv.Itoa(page))
+		}
+		if pageSize != opdsPageSize {
+			query.Set("pageSize", strconv.Itoa(pageSize))
+		}
+		if len(query) == 0 {
+			return path
+		}
+		return path + "?" + query.Encode()
+	}
+
+	feed.Kind = opds.Acquisition
+	feed.Self = pageHref(page)
+	feed.Search = opdsSearch
+	feed.Paging = &opds.Paging{Page: page, PageSize: pageSize, Total: int(total), PageHref: pageHref}
+	for _, book := range books {
+		feed.Publications = append(feed.Publications, opdsPublication(book))
+	}
+	writeOPDS(c, feed)
+}
+
+// opdsPublication describes a book as a catalogue entry.
+func opdsPublication(book models.Book) opds.Publication {
+	id := strconv.FormatUint(uint64(book.ID), 10)
+	publication := opds.Publication{
+		ID:        opdsBookIDPrefix + id,
+		Title:     book.Title,
+		Publisher: book.Publisher.Name,
+		Language:  book.Language,
+		Year:      book.PublishedYear,
+		ISBN:      book.ISBN,
+		Summary:   book.Description,
+		Href:      resourcePrefix + "/books/" + id,
+		Updated:   book.UpdatedAt,
+	}
+	if book.AuthorID != nil && book.Author.Name != "" {
+		publication.Authors = []opds.Contributor{{
+			Name: book.Author.Name,
+			Href: opdsRoot + "/authors/" + strconv.FormatUint(uint64(*book.AuthorID), 10),
+		}}
+	}
+	for _, subject := range book.Subjects {
+		publication.Subjects = append(publication.Subjects, subject.Name)
+	}
+	return publication
+}
+
+// writeOPDS sends feed as OPDS 2.0 JSON to clients that accept it, and as OPDS 1.2 Atom
+// otherwise.
+func writeOPDS(c *gin.Context, feed opds.Feed) {
+	feed.Start = opdsRoot
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now()
+	}
+
+	write, contentType := opds.WriteAtom, opds.TypeAtom+";kind="+feed.Kind
+	switch c.NegotiateFormat("application/atom+xml", opds.TypeJSON, gin.MIMEJSON) {
+	case opds.TypeJSON, gin.MIMEJSON:
+		write, contentType = opds.WriteJSON, opds.TypeJSON
+	}
+
+	var body bytes.Buffer
+	if err := write(&body, feed); err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return
+	}
+
+	c.Header("Vary", "Accept")
+	c.Data(http.StatusOK, contentType+"; charset=utf-8", body.Bytes())
+}
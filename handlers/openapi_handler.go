@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+	"sync"
+
+	"gin-books-api/dto"
+	"gin-books-api/graph"
+	"gin-books-api/models"
+	"gin-books-api/openapi"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// apiInfo describes the API in the OpenAPI document.
+var apiInfo = openapi.Info{
+	Title:       "Gin Books API",
+	Version:     "1.0.0",
+	Description: "A catalogue of books with their authors, publishers, categories, series, subjects and reviews.",
+	Problem:     utils.Problem{},
+	ProblemType: utils.ProblemContentType,
+}
+
+// bookPage, authorPage and auditPage are the pagination envelopes of GET /books, of
+// GET /authors from v2 and of the audit routes, described here for the OpenAPI document.
+type bookPage struct {
+	Page       int           `json:"page"`
+	PageSize   int           `json:"pageSize"`
+	Total      int           `json:"total"`
+	TotalPages int           `json:"totalPages"`
+	Data       []models.Book `json:"data"`
+}
+
+type authorPage struct {
+	Page       int             `json:"page"`
+	PageSize   int             `json:"pageSize"`
+	Total      int             `json:"total"`
+	TotalPages int             `json:"totalPages"`
+	Data       []models.Author `json:"data"`
+}
+
+type auditPage struct {
+	Page       int               `json:"page"`
+	PageSize   int               `json:"pageSize"`
+	Total      int64             `json:"total"`
+	TotalPages int               `json:"totalPages"`
+	Data       []models.AuditLog `json:"data"`
+}
+
+// graphQLResult is the response of the GraphQL routes.
+type graphQLResult struct {
+	Data   map[string]interface{}   `json:"data"`
+	Errors []map[string]interface{} `json:"errors,omitempty"`
+}
+
+// message is the response of deletes.
+type message struct {
+	Message string `json:"message"`
+}
+
+// Query parameters shared by several routes.
+var (
+	pageParam       = openapi.Parameter{Name: "page", Type: "integer", Description: "Page number, from 1"}
+	pageSizeParam   = openapi.Parameter{Name: "pageSize", Type: "integer", Description: "Number of entries per page"}
+	dryRunParam     = openapi.Parameter{Name: "dry_run", Type: "boolean", Description: "Report what would be imported without writing it"}
+	opdsSearchParam = openapi.Parameter{Name: "q", Type: "string", Description: "Words of the title"}
+	citationParam   = openapi.Parameter{Name: "format", Type: "string", Required: true,
+		Enum: []string{"marcxml", "dc", "bibtex", "ris", "csl-json"}}
+)
+
+// Media types of responses that are not plain JSON.
+var (
+	opdsTypes     = []string{"application/atom+xml", "application/opds+json"}
+	feedTypes     = []string{"application/atom+xml", "application/rss+xml"}
+	citationTypes = []string{"application/marcxml+xml", "application/xml", "application/x-bibtex",
+		"application/x-research-info-systems", "application/vnd.citationstyles.csl+json"}
+)
+
+// Operations describes every route of the API, keyed by "METHOD /path" as the route is
+// registered. The routes of a version are described by their path within the version,
+// such as "GET /books" for /api/v1/books and /api/v2/books, unless the handler of the
+// version differs. The OpenAPI document is generated from it and the route table, and
+// TestRoutesAreDocumented fails for a route that is missing here.
+var Operations = map[string]openapi.Operation{
+	// API description
+	"GET /openapi.json": {Summary: "OpenAPI document of the API", Tag: "docs", Response: map[string]interface{}{}},
+	"GET /docs":         {Summary: "Interactive documentation of the API", Tag: "docs", Produces: []string{"text/html"}},
+
+	// Monitoring
+	"GET /metrics": {Summary: "Prometheus metrics", Tag: "monitoring", Produces: []string{"text/plain"},
+		Description: "Requests, database queries and connections, cache lookups and lending, in the Prometheus text format."},
+	"GET /healthz": {Summary: "Liveness", Tag: "monitoring", Response: liveness{},
+		Description: "Always 200 while the process serves requests; no dependency is checked."},
+	"GET /readyz": {Summary: "Readiness", Tag: "monitoring", Response: services.Readiness{},
+		Description: "Pings the database and Redis and checks the schema version. 200 when ready, or degraded without Redis; " +
+			"503, with the same body, when the database is down, its schema is behind, or the instance is draining."},
+
+	// GraphQL
+	"GET /graphql": {Summary: "Run a GraphQL query", Tag: "graphql", Response: graphQLResult{},
+		Description: "Runs a query, never a mutation. Errors of the query are reported in the errors member.",
+		Query: []openapi.Parameter{{Name: "query", Type: "string", Required: true, Description: "GraphQL document"},
+			{Name: "operationName", Type: "string", Description: "Operation of the document to run"},
+			{Name: "variables", Type: "string", Description: "Variables as a JSON object"}}},
+	"POST /graphql": {Summary: "Run a GraphQL query or mutation", Tag: "graphql", Body: graph.Request{}, Response: graphQLResult{},
+		Description: "Errors of the query are reported in the errors member."},
+
+	// Books
+	"GET /books": {Summary: "List books", Response: bookPage{},
+		Description: "Lists books a page at a time, in reading order when filtered by series.",
+		Query: []openapi.Parameter{pageParam, pageSizeParam,
+			{Name: "series_id", Type: "integer", Description: "Only the books of this series"}}},
+	"GET /books/:id": {Summary: "Get a book", Response: models.Book{}, Produces: []string{"application/ld+json"},
+		Description: "Returns schema.org JSON-LD instead when the client accepts application/ld+json."},
+	"POST /books":       {Summary: "Create a book", Body: dto.BookRequest{}, Status: http.StatusCreated, Response: models.Book{}},
+	"PUT /books/:id":    {Summary: "Replace a book", Body: dto.BookRequest{}, Response: models.Book{}},
+	"PATCH /books/:id":  {Summary: "Update a book", Patch: dto.BookRequest{}, Response: models.Book{}},
+	"DELETE /books/:id": {Summary: "Delete a book", Response: message{}},
+	"GET /books/:id/series": {Summary: "Series of a book",
+		Description: "Lists the series the book belongs to with the books before and after it.",
+		Response:    []services.SeriesNavigation{}},
+	"GET /books/:id/export": {Summary: "Export a book as a citation", Query: []openapi.Parameter{citationParam},
+		Produces: citationTypes},
+	"GET /books/export": {Summary: "Export books as citations", Produces: citationTypes,
+		Query: []openapi.Parameter{citationParam,
+			{Name: "ids", Type: "string", Required: true, Description: "Comma-separated IDs of up to 500 books"}}},
+
+	// Import and export
+	"POST /import/books": {Summary: "Import books", Tag: "import", Response: services.ImportReport{},
+		Description: "Creates or updates books from a CSV, JSON array or NDJSON body and reports every row.",
+		Query:       []openapi.Parameter{dryRunParam},
+		Body:        []dto.BookImportRow{}, BodyTypes: []string{dto.ContentTypeCSV, dto.ContentTypeNDJSON}},
+	"POST /import/marc": {Summary: "Import MARC records", Tag: "import", Response: services.ImportReport{},
+		Query:     []openapi.Parameter{dryRunParam},
+		BodyTypes: []string{"application/marc", "application/marcxml+xml"}},
+	"GET /export/:entity": {Summary: "Export books, reviews or loans",
+		Description: "Streams every row as CSV, NDJSON or XLSX; :entity is books, reviews or loans.",
+		Query: []openapi.Parameter{
+			{Name: "format", Type: "string", Enum: []string{"csv", "ndjson", "xlsx"}},
+			{Name: "series_id", Type: "integer"}, {Name: "book_id", Type: "integer"}, {Name: "user_id", Type: "integer"}},
+		Produces: []string{"text/csv", "application/x-ndjson", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}},
+
+	// OPDS catalogue
+	"GET /opds":                {Summary: "Root of the OPDS catalogue", Produces: opdsTypes},
+	"GET /opds/search.xml":     {Summary: "OpenSearch description of the catalogue", Produces: []string{"application/opensearchdescription+xml"}},
+	"GET /opds/new":            {Summary: "Newest books", Produces: opdsTypes, Query: []openapi.Parameter{pageParam, pageSizeParam}},
+	"GET /opds/books":          {Summary: "Books by title", Produces: opdsTypes, Query: []openapi.Parameter{opdsSearchParam, pageParam, pageSizeParam}},
+	"GET /opds/categories":     {Summary: "Categories of the catalogue", Produces: opdsTypes},
+	"GET /opds/categories/:id": {Summary: "Books of a category", Produces: opdsTypes, Query: []openapi.Parameter{pageParam, pageSizeParam}},
+	"GET /opds/authors":        {Summary: "Authors of the catalogue", Produces: opdsTypes},
+	"GET /opds/authors/:id":    {Summary: "Books of an author", Produces: opdsTypes, Query: []openapi.Parameter{pageParam, pageSizeParam}},
+	"GET /opds/publishers":     {Summary: "Publishers of the catalogue", Produces: opdsTypes},
+	"GET /opds/publishers/:id": {Summary: "Books of a publisher", Produces: opdsTypes, Query: []openapi.Parameter{pageParam, pageSizeParam}},
+
+	// Sitemap and feeds
+	"GET /sitemap.xml": {Summary: "Sitemap of books and authors", Tag: "sitemap", Produces: []string{"application/xml"},
+		Query: []openapi.Parameter{{Name: "page", Type: "integer", Description: "Page of a sitemap index"}}},
+	"GET /feeds/:file":            {Summary: "Feed of new books", Description: "new.atom or new.rss", Produces: feedTypes},
+	"GET /feeds/categories/:file": {Summary: "Feed of new books in a category", Description: ":file is the ID with .atom or .rss", Produces: feedTypes},
+	"GET /feeds/authors/:file":    {Summary: "Feed of new books by an author", Description: ":file is the ID with .atom or .rss", Produces: feedTypes},
+	"GET /feeds/publishers/:file": {Summary: "Feed of new books from a publisher", Description: ":file is the ID with .atom or .rss", Produces: feedTypes},
+
+	// Works
+	"GET /works":            {Summary: "List works", Response: []models.Work{}},
+	"GET /works/:id":        {Summary: "Get a work with its editions", Response: services.WorkDetail{}},
+	"POST /works":           {Summary: "Create a work", Body: dto.WorkRequest{}, Status: http.StatusCreated, Response: models.Work{}},
+	"PUT /works/:id":        {Summary: "Replace a work", Body: dto.WorkRequest{}, Response: models.Work{}},
+	"PATCH /works/:id":      {Summary: "Update a work", Patch: dto.WorkRequest{}, Response: models.Work{}},
+	"DELETE /works/:id":     {Summary: "Delete a work", Response: message{}},
+	"POST /works/:id/merge": {Summary: "Merge works into this one", Body: dto.MergeWorkRequest{}, Response: models.Work{}},
+	"POST /works/:id/split": {Summary: "Split editions into a new work", Body: dto.SplitWorkRequest{},
+		Status: http.StatusCreated, Response: models.Work{}},
+
+	// Series
+	"GET /series":        {Summary: "List series", Response: []models.Series{}},
+	"GET /series/:id":    {Summary: "Get a series", Response: models.Series{}},
+	"POST /series":       {Summary: "Create a series", Body: dto.SeriesRequest{}, Status: http.StatusCreated, Response: models.Series{}},
+	"PUT /series/:id":    {Summary: "Replace a series", Body: dto.SeriesRequest{}, Response: models.Series{}},
+	"PATCH /series/:id":  {Summary: "Update a series", Patch: dto.SeriesRequest{}, Response: models.Series{}},
+	"DELETE /series/:id": {Summary: "Delete a series", Response: message{}},
+	"POST /series/:id/books": {Summary: "Add a book to a series", Body: dto.SeriesEntryRequest{},
+		Response: models.SeriesEntry{}},
+	"DELETE /series/:id/books/:book_id": {Summary: "Remove a book from a series", Response: message{}},
+
+	// Tags and subjects
+	"GET /tags":         {Summary: "List tags with the number of books", Response: []services.TagUsage{}},
+	"GET /subjects":     {Summary: "List subjects", Response: []models.Subject{}},
+	"GET /subjects/:id": {Summary: "Get a subject", Response: models.Subject{}},
+	"GET /subjects/:id/books": {Summary: "Books of a subject", Response: []models.Book{},
+		Query: []openapi.Parameter{{Name: "descendants", Type: "boolean", Description: "Include the books of narrower subjects"}}},
+	"POST /subjects":       {Summary: "Create a subject", Body: dto.SubjectRequest{}, Status: http.StatusCreated, Response: models.Subject{}},
+	"PUT /subjects/:id":    {Summary: "Replace a subject", Body: dto.SubjectRequest{}, Response: models.Subject{}},
+	"PATCH /subjects/:id":  {Summary: "Update a subject", Patch: dto.SubjectRequest{}, Response: models.Subject{}},
+	"DELETE /subjects/:id": {Summary: "Delete a subject", Response: message{}},
+
+	// Authors
+	"GET /authors": {Summary: "List authors", Response: []models.Author{}},
+	"GET /authors/:id": {Summary: "Get an author", Response: models.Author{}, Produces: []string{"application/ld+json"},
+		Description: "Returns schema.org JSON-LD instead when the client accepts application/ld+json."},
+	"GET /api/v2/authors": {Summary: "List authors", Tag: "authors", Response: authorPage{},
+		Query: []openapi.Parameter{pageParam, pageSizeParam}},
+	"POST /authors":       {Summary: "Create an author", Body: dto.AuthorRequest{}, Status: http.StatusCreated, Response: models.Author{}},
+	"PUT /authors/:id":    {Summary: "Replace an author", Body: dto.AuthorRequest{}, Response: models.Author{}},
+	"PATCH /authors/:id":  {Summary: "Update an author", Patch: dto.AuthorRequest{}, Response: models.Author{}},
+	"DELETE /authors/:id": {Summary: "Delete an author", Response: message{}},
+
+	// Categories
+	"GET /categories":           {Summary: "List categories as a tree", Response: []models.Category{}},
+	"GET /categories/:id":       {Summary: "Get a category", Response: models.Category{}},
+	"GET /categories/:id/books": {Summary: "Books of a category and its subcategories", Response: []models.Book{}},
+	"POST /categories": {Summary: "Create a category", Body: dto.CategoryRequest{}, Status: http.StatusCreated,
+		Response: models.Category{}},
+	"PUT /categories/:id":   {Summary: "Replace a category", Body: dto.CategoryRequest{}, Response: models.Category{}},
+	"PATCH /categories/:id": {Summary: "Update a category", Patch: dto.CategoryRequest{}, Response: models.Category{}},
+	"DELETE /categories/:id": {Summary: "Delete a category", Response: message{},
+		Query: []openapi.Parameter{{Name: "strategy", Type: "string", Required: true, Enum: []string{"reparent", "refuse"},
+			Description: "What happens to the subcategories and books"}}},
+
+	// Publishers
+	"GET /publishers":     {Summary: "List publishers", Response: []models.Publisher{}},
+	"GET /publishers/:id": {Summary: "Get a publisher", Response: models.Publisher{}},
+	"POST /publishers": {Summary: "Create a publisher", Body: dto.PublisherRequest{}, Status: http.StatusCreated,
+		Response: models.Publisher{}},
+	"PUT /publishers/:id":    {Summary: "Replace a publisher", Body: dto.PublisherRequest{}, Response: models.Publisher{}},
+	"PATCH /publishers/:id":  {Summary: "Update a publisher", Patch: dto.PublisherRequest{}, Response: models.Publisher{}},
+	"DELETE /publishers/:id": {Summary: "Delete a publisher", Response: message{}},
+
+	// Reviews
+	"GET /reviews":        {Summary: "List reviews", Response: []models.Review{}},
+	"GET /reviews/:id":    {Summary: "Get a review", Response: models.Review{}},
+	"POST /reviews":       {Summary: "Create a review", Body: dto.ReviewRequest{}, Status: http.StatusCreated, Response: models.Review{}},
+	"PUT /reviews/:id":    {Summary: "Replace a review", Body: dto.ReviewRequest{}, Response: models.Review{}},
+	"PATCH /reviews/:id":  {Summary: "Update a review", Patch: dto.ReviewRequest{}, Response: models.Review{}},
+	"DELETE /reviews/:id": {Summary: "Delete a review", Response: message{}},
+
+	// Users
+	"GET /users":        {Summary: "List users", Response: []models.User{}},
+	"GET /users/:id":    {Summary: "Get a user", Response: models.User{}},
+	"POST /users":       {Summary: "Create a user", Body: dto.UserRequest{}, Status: http.StatusCreated, Response: models.User{}},
+	"PUT /users/:id":    {Summary: "Replace a user", Body: dto.UserRequest{}, Response: models.User{}},
+	"PATCH /users/:id":  {Summary: "Update a user", Patch: dto.UserRequest{}, Response: models.User{}},
+	"DELETE /users/:id": {Summary: "Delete a user", Response: message{}},
+	"GET /users/:id/activity": {Summary: "Changes made by a user", Tag: "audit", Response: auditPage{},
+		Query: []openapi.Parameter{pageParam, pageSizeParam}},
+
+	// Loans
+	"GET /loans":     {Summary: "List the books on loan", Response: []models.BorrowedBook{}},
+	"GET /loans/:id": {Summary: "Get a loan", Response: models.BorrowedBook{}},
+	"POST /loans": {Summary: "Lend a book", Body: dto.LoanRequest{}, Status: http.StatusCreated, Response: models.BorrowedBook{},
+		Description: "Marks the book unavailable until it is returned. Fails with 409 if the book is already on loan."},
+	"DELETE /loans/:id": {Summary: "Return a book", Response: message{},
+		Description: "Ends the loan and makes the book available again."},
+
+	// Audit trail
+	"GET /audit": {Summary: "List changes, newest first", Response: auditPage{},
+		Query: []openapi.Parameter{pageParam, pageSizeParam,
+			{Name: "entity", Type: "string", Description: "Only changes to this kind of entity, e.g. book"},
+			{Name: "id", Type: "integer", Description: "Only changes to this entity; requires entity"}}},
+}
+
+// OpenAPI returns the handler of /openapi.json, which describes the routes listed by
+// routes. The document is generated on the first request, once every route is
+// registered.
+func OpenAPI(routes func() []openapi.Route) gin.HandlerFunc {
+	var once sync.Once
+	var doc *openapi.Document
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = openapi.Generate(apiInfo, routes(), Operations)
+		})
+		utils.JSONResponse(c, http.StatusOK, doc)
+	}
+}
+
+// GetDocs serves Swagger UI on the OpenAPI document.
+func GetDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
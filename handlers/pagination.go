@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindPage reads ?page= and ?pageSize=, which default to the first page of
+// defaultSize entries.
+func bindPage(c *gin.Context, defaultSize int) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_page", "Invalid page number"))
+		return 0, 0, false
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultSize)))
+	if err != nil || pageSize <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_page_size", "Invalid page size"))
+		return 0, 0, false
+	}
+
+	return page, pageSize, true
+}
+
+// paginate returns the requested page of items wrapped in the pagination envelope.
+func paginate[T any](items []T, page, pageSize int) gin.H {
+	start := (page - 1) * pageSize
+	end := start + pageSize
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return gin.H{
+		"page":       page,
+		"pageSize":   pageSize,
+		"total":      len(items),
+		"totalPages": (len(items) + pageSize - 1) / pageSize,
+		"data":       items[start:end],
+	}
+}
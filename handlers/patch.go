@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/dto"
+	"gin-books-api/utils"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json" // RFC 7396
+	mediaTypeJSONPatch  = "application/json-patch+json"  // RFC 6902
+
+	maxPatchSize = 1 << 20
+)
+
+// applyPatch applies the PATCH body to req, which holds the current state of the
+// record, and validates the fields it changes the same way bindRequest validates a full
+// body. It returns the JSON keys whose values changed; these match the column names. On
+// failure it sends the problem response and returns false: 415 for an unsupported
+// content type, 400 for a malformed patch, 409 when a JSON Patch operation cannot be
+// applied (including a failed "test") and 422 when the patched record is invalid.
+func applyPatch(c *gin.Context, req dto.Request) ([]string, bool) {
+	contentType := c.ContentType()
+	if contentType != mediaTypeMergePatch && contentType != mediaTypeJSONPatch {
+		utils.ErrorResponse(c, apperrors.New(apperrors.KindUnsupportedMediaType, "unsupported_media_type",
+			"PATCH accepts "+mediaTypeMergePatch+" or "+mediaTypeJSONPatch))
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchSize))
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.BadRequest("malformed_body", "Invalid request payload"))
+		return nil, false
+	}
+	original, err := json.Marshal(req)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return nil, false
+	}
+
+	var patched []byte
+	if contentType == mediaTypeMergePatch {
+		if patched, err = jsonpatch.MergePatch(original, body); err != nil {
+			utils.ErrorResponse(c, apperrors.BadRequest("malformed_patch", "Invalid merge patch document"))
+			return nil, false
+		}
+	} else {
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			utils.ErrorResponse(c, apperrors.BadRequest("malformed_patch", "Invalid JSON Patch document"))
+			return nil, false
+		}
+		if patched, err = patch.Apply(original); err != nil {
+			utils.ErrorResponse(c, apperrors.Conflict("patch_failed", err.Error()))
+			return nil, false
+		}
+	}
+
+	// Decode into a zeroed request so that members removed by the patch stay empty
+	value := reflect.ValueOf(req).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.NewDecoder(bytes.NewReader(patched)).Decode(req); err != nil {
+		if details := utils.ValidationErrors(err); details != nil {
+			utils.ErrorResponse(c, apperrors.Validation(details...))
+		} else {
+			utils.ErrorResponse(c, apperrors.BadRequest("malformed_patch", "Patched document is not a valid object"))
+		}
+		return nil, false
+	}
+
+	result, err := json.Marshal(req)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.Internal(err))
+		return nil, false
+	}
+	changed := changedKeys(original, result)
+	if err := validateKeys(req, changed); err != nil {
+		utils.ErrorResponse(c, apperrors.Validation(utils.ValidationErrors(err)...))
+		return nil, false
+	}
+	if !checkReferences(c, req) {
+		return nil, false
+	}
+	return changed, true
+}
+
+// validateKeys validates the fields of req named by the JSON keys given, and the fields
+// nested in them, so that a patch is not refused for a field it leaves as it is, such
+// as one stored before a rule of the request was added.
+func validateKeys(req dto.Request, keys []string) error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return binding.Validator.ValidateStruct(req)
+	}
+
+	fields := map[string]bool{}
+	typ := reflect.TypeOf(req).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if slices.Contains(keys, name) {
+			fields[typ.Field(i).Name] = true
+		}
+	}
+	return engine.StructFiltered(req, func(namespace []byte) bool {
+		// The namespace of a field, such as "BookRequest.Tags[0].Name", starts with the
+		// request type and its top-level field
+		_, field, _ := bytes.Cut(namespace, []byte("."))
+		if i := bytes.IndexAny(field, ".["); i >= 0 {
+			field = field[:i]
+		}
+		return !fields[string(field)]
+	})
+}
+
+// changedKeys lists the top-level keys whose values differ between two JSON objects.
+func changedKeys(before, after []byte) []string {
+	var old, updated map[string]interface{}
+	_ = json.Unmarshal(before, &old)
+	_ = json.Unmarshal(after, &updated)
+
+	var keys []string
+	for key, value := range updated {
+		if !reflect.DeepEqual(old[key], value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
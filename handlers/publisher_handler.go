@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"context"
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
+	"gin-books-api/dto"
 	"gin-books-api/models"
 	"gin-books-api/services"
 	"gin-books-api/utils"
@@ -10,11 +11,10 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func GetPublishers(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	cacheKey := "publishers_all"
 
 	var publishers []models.Publisher
@@ -26,7 +26,7 @@ func GetPublishers(c *gin.Context) {
 
 	publishers, err := services.FetchPublishersFromDB(ctx, cacheKey)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve publishers")
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
 		return
 	}
 
@@ -35,12 +35,12 @@ func GetPublishers(c *gin.Context) {
 }
 
 func GetPublisherByID(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	idParam := c.Param("id")
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid publisher ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid publisher ID"))
 		return
 	}
 
@@ -55,11 +55,7 @@ func GetPublisherByID(c *gin.Context) {
 
 	publisherPtr, err := services.FetchPublisherFromDB(ctx, cacheKey, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Publisher not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the publisher")
-		}
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
 		return
 	}
 
@@ -68,14 +64,14 @@ func GetPublisherByID(c *gin.Context) {
 }
 
 func CreatePublisher(c *gin.Context) {
-	var publisher models.Publisher
-	if err := c.ShouldBindJSON(&publisher); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.PublisherRequest
+	if !bindRequest(c, &req) {
 		return
 	}
+	publisher := req.Model()
 
-	if err := services.CreatePublisher(context.Background(), &publisher); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create publisher")
+	if err := services.CreatePublisher(requestContext(c), &publisher); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
 		return
 	}
 
@@ -86,18 +82,50 @@ func UpdatePublisher(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid publisher ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid publisher ID"))
 		return
 	}
 
-	var publisher models.Publisher
-	if err := c.ShouldBindJSON(&publisher); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.PublisherRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	publisher := req.Model()
+
+	if err := services.UpdatePublisher(requestContext(c), id, &publisher); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, publisher)
+}
+
+// PatchPublisher applies a JSON Merge Patch or JSON Patch to a publisher and writes only the
+// columns it changes.
+func PatchPublisher(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid publisher ID"))
+		return
+	}
+
+	current, err := services.FetchPublisherFromDB(ctx, "publisher_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
+		return
+	}
+
+	req := dto.NewPublisherRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
 		return
 	}
+	publisher := req.Model()
 
-	if err := services.UpdatePublisher(context.Background(), id, &publisher); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update publisher")
+	if err := services.PatchPublisher(ctx, id, &publisher, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
 		return
 	}
 
@@ -108,16 +136,12 @@ func DeletePublisher(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid publisher ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid publisher ID"))
 		return
 	}
 
-	if err := services.DeletePublisher(context.Background(), id); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "Publisher not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete publisher")
-		}
+	if err := services.DeletePublisher(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "publisher"))
 		return
 	}
 
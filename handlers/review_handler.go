@@ -1,8 +1,9 @@
 package handlers
 
 import (
-    "context"
+    "gin-books-api/apperrors"
     "gin-books-api/cache"
+    "gin-books-api/dto"
     "gin-books-api/models"
     "gin-books-api/services"
     "gin-books-api/utils"
@@ -10,11 +11,10 @@ import (
     "strconv"
 
     "github.com/gin-gonic/gin"
-    "gorm.io/gorm"
 )
 
 func GetReviews(c *gin.Context) {
-    ctx := context.Background()
+    ctx := requestContext(c)
     cacheKey := "reviews_all"
 
     var reviews []models.Review
@@ -26,7 +26,7 @@ func GetReviews(c *gin.Context) {
 
     reviews, err := services.FetchReviewsFromDB(ctx, cacheKey)
     if err != nil {
-        utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve reviews")
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
         return
     }
 
@@ -35,12 +35,12 @@ func GetReviews(c *gin.Context) {
 }
 
 func GetReviewByID(c *gin.Context) {
-    ctx := context.Background()
+    ctx := requestContext(c)
     idParam := c.Param("id")
 
     id, err := strconv.Atoi(idParam)
     if err != nil || id <= 0 {
-        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid review ID")
+        utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid review ID"))
         return
     }
 
@@ -55,11 +55,7 @@ func GetReviewByID(c *gin.Context) {
 
     reviewPtr, err := services.FetchReviewFromDB(ctx, cacheKey, id)
     if err != nil {
-        if err == gorm.ErrRecordNotFound {
-            utils.ErrorResponse(c, http.StatusNotFound, "Review not found")
-        } else {
-            utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the review")
-        }
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
         return
     }
 
@@ -68,14 +64,14 @@ func GetReviewByID(c *gin.Context) {
 }
 
 func CreateReview(c *gin.Context) {
-    var review models.Review
-    if err := c.ShouldBindJSON(&review); err != nil {
-        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+    var req dto.ReviewRequest
+    if !bindRequest(c, &req) {
         return
     }
+    review := req.Model()
 
-    if err := services.CreateReview(context.Background(), &review); err != nil {
-        utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create review")
+    if err := services.CreateReview(requestContext(c), &review); err != nil {
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
         return
     }
 
@@ -86,18 +82,50 @@ func UpdateReview(c *gin.Context) {
     idParam := c.Param("id")
     id, err := strconv.Atoi(idParam)
     if err != nil || id <= 0 {
-        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid review ID")
+        utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid review ID"))
         return
     }
 
-    var review models.Review
-    if err := c.ShouldBindJSON(&review); err != nil {
-        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+    var req dto.ReviewRequest
+    if !bindRequest(c, &req) {
+        return
+    }
+    review := req.Model()
+
+    if err := services.UpdateReview(requestContext(c), id, &review); err != nil {
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
+        return
+    }
+
+    utils.JSONResponse(c, http.StatusOK, review)
+}
+
+// PatchReview applies a JSON Merge Patch or JSON Patch to a review and writes only the
+// columns it changes.
+func PatchReview(c *gin.Context) {
+    ctx := requestContext(c)
+    idParam := c.Param("id")
+    id, err := strconv.Atoi(idParam)
+    if err != nil || id <= 0 {
+        utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid review ID"))
+        return
+    }
+
+    current, err := services.FetchReviewFromDB(ctx, "review_"+idParam, id)
+    if err != nil {
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
+        return
+    }
+
+    req := dto.NewReviewRequest(*current)
+    columns, ok := applyPatch(c, &req)
+    if !ok {
         return
     }
+    review := req.Model()
 
-    if err := services.UpdateReview(context.Background(), id, &review); err != nil {
-        utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update review")
+    if err := services.PatchReview(ctx, id, &review, columns); err != nil {
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
         return
     }
 
@@ -108,16 +136,12 @@ func DeleteReview(c *gin.Context) {
     idParam := c.Param("id")
     id, err := strconv.Atoi(idParam)
     if err != nil || id <= 0 {
-        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid review ID")
+        utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid review ID"))
         return
     }
 
-    if err := services.DeleteReview(context.Background(), id); err != nil {
-        if err == gorm.ErrRecordNotFound {
-            utils.ErrorResponse(c, http.StatusNotFound, "Review not found")
-        } else {
-            utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete review")
-        }
+    if err := services.DeleteReview(requestContext(c), id); err != nil {
+        utils.ErrorResponse(c, apperrors.FromDB(err, "review"))
         return
     }
 
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/dto"
+	"gin-books-api/models"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSeriesList retrieves all series with their books in reading order and implements caching.
+func GetSeriesList(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "series_all"
+
+	// Attempt to retrieve cached data
+	var series []models.Series
+	if cache.GetCachedData(ctx, cacheKey, &series) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, series)
+		return
+	}
+
+	// If not cached, fetch from database
+	series, err := services.FetchSeriesListFromDB(ctx, cacheKey)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, series)
+}
+
+// GetSeriesByID retrieves a series by its ID with its books in reading order and implements caching.
+func GetSeriesByID(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+
+	// Validate the series ID
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+		return
+	}
+
+	cacheKey := "series_" + idParam
+	var series models.Series
+
+	// Attempt to retrieve cached data
+	if cache.GetCachedData(ctx, cacheKey, &series) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, series)
+		return
+	}
+
+	// If not cached, fetch from database
+	seriesPtr, err := services.FetchSeriesFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, seriesPtr)
+}
+
+// CreateSeries creates a new series and stores it in the database.
+func CreateSeries(c *gin.Context) {
+	var req dto.SeriesRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	series := req.Model()
+
+	if err := services.CreateSeries(requestContext(c), &series); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusCreated, series)
+}
+
+// UpdateSeries updates an existing series by its ID.
+func UpdateSeries(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+		return
+	}
+
+	var req dto.SeriesRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	series := req.Model()
+
+	if err := services.UpdateSeries(requestContext(c), id, &series); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, series)
+}
+
+// PatchSeries applies a JSON Merge Patch or JSON Patch to a series and writes only the
+// columns it changes.
+func PatchSeries(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+		return
+	}
+
+	current, err := services.FetchSeriesFromDB(ctx, "series_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	req := dto.NewSeriesRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
+		return
+	}
+	series := req.Model()
+
+	if err := services.PatchSeries(ctx, id, &series, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, series)
+}
+
+// DeleteSeries deletes a series by its ID. Its books are kept.
+func DeleteSeries(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+		return
+	}
+
+	if err := services.DeleteSeries(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, gin.H{"message": "Series deleted successfully"})
+}
+
+// AddBookToSeries places a book at a position in a series, or moves it if already a member.
+func AddBookToSeries(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+		return
+	}
+
+	var req dto.SeriesEntryRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+
+	entry := req.Model()
+	if err := services.AddBookToSeries(requestContext(c), id, &entry); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, entry)
+}
+
+// RemoveBookFromSeries removes a book from a series and closes the gap it leaves.
+func RemoveBookFromSeries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid series ID"))
+		return
+	}
+
+	bookID, err := strconv.Atoi(c.Param("book_id"))
+	if err != nil || bookID <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid book ID"))
+		return
+	}
+
+	if err := services.RemoveBookFromSeries(requestContext(c), id, bookID); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "series_entry"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, gin.H{"message": "Book removed from series successfully"})
+}
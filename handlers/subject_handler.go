@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/dto"
+	"gin-books-api/models"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSubjects retrieves all subjects and implements caching.
+func GetSubjects(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "subjects_all"
+
+	// Attempt to retrieve cached data
+	var subjects []models.Subject
+	if cache.GetCachedData(ctx, cacheKey, &subjects) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, subjects)
+		return
+	}
+
+	// If not cached, fetch from database
+	subjects, err := services.FetchSubjectsFromDB(ctx, cacheKey)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, subjects)
+}
+
+// GetSubjectByID retrieves a subject by its ID with its narrower subjects and implements caching.
+func GetSubjectByID(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+
+	// Validate the subject ID
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid subject ID"))
+		return
+	}
+
+	cacheKey := "subject_" + idParam
+	var subject models.Subject
+
+	// Attempt to retrieve cached data
+	if cache.GetCachedData(ctx, cacheKey, &subject) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, subject)
+		return
+	}
+
+	// If not cached, fetch from database
+	subjectPtr, err := services.FetchSubjectFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, subjectPtr)
+}
+
+// GetSubjectBooks retrieves the books filed under a subject. With ?descendants=true,
+// books filed under any narrower subject are included.
+func GetSubjectBooks(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid subject ID"))
+		return
+	}
+
+	descendants, err := strconv.ParseBool(c.DefaultQuery("descendants", "false"))
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_parameter", "Invalid descendants flag"))
+		return
+	}
+
+	books, err := services.FetchSubjectBooks(requestContext(c), id, descendants)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, books)
+}
+
+// CreateSubject creates a new subject and stores it in the database.
+func CreateSubject(c *gin.Context) {
+	var req dto.SubjectRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	subject := req.Model()
+
+	if err := services.CreateSubject(requestContext(c), &subject); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusCreated, subject)
+}
+
+// UpdateSubject updates an existing subject by its ID, including moving it under another parent.
+func UpdateSubject(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid subject ID"))
+		return
+	}
+
+	var req dto.SubjectRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	subject := req.Model()
+
+	if err := services.UpdateSubject(requestContext(c), id, &subject); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, subject)
+}
+
+// PatchSubject applies a JSON Merge Patch or JSON Patch to a subject and writes only the
+// columns it changes.
+func PatchSubject(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid subject ID"))
+		return
+	}
+
+	current, err := services.FetchSubjectFromDB(ctx, "subject_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	req := dto.NewSubjectRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
+		return
+	}
+	subject := req.Model()
+
+	if err := services.PatchSubject(ctx, id, &subject, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, subject)
+}
+
+// DeleteSubject deletes a subject by its ID. Its narrower subjects move up to its parent.
+func DeleteSubject(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid subject ID"))
+		return
+	}
+
+	if err := services.DeleteSubject(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "subject"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, gin.H{"message": "Subject deleted successfully"})
+}
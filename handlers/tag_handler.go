@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTags retrieves the tag cloud: every tag with its usage count and display weight.
+// Implements caching.
+func GetTags(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "tags_cloud"
+
+	// Attempt to retrieve cached data
+	var tags []services.TagUsage
+	if cache.GetCachedData(ctx, cacheKey, &tags) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, tags)
+		return
+	}
+
+	// If not cached, fetch from database
+	tags, err := services.FetchTagCloudFromDB(ctx, cacheKey)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "tag"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, tags)
+}
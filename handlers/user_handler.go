@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"context"
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
+	"gin-books-api/dto"
 	"gin-books-api/models"
 	"gin-books-api/services"
 	"gin-books-api/utils"
@@ -10,11 +11,10 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func GetUsers(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	cacheKey := "users_all"
 
 	var users []models.User
@@ -26,7 +26,7 @@ func GetUsers(c *gin.Context) {
 
 	users, err := services.FetchUsersFromDB(ctx, cacheKey)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve users")
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
 		return
 	}
 
@@ -35,12 +35,12 @@ func GetUsers(c *gin.Context) {
 }
 
 func GetUserByID(c *gin.Context) {
-	ctx := context.Background()
+	ctx := requestContext(c)
 	idParam := c.Param("id")
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid user ID"))
 		return
 	}
 
@@ -55,11 +55,7 @@ func GetUserByID(c *gin.Context) {
 
 	userPtr, err := services.FetchUserFromDB(ctx, cacheKey, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "User not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the user")
-		}
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
 		return
 	}
 
@@ -68,14 +64,14 @@ func GetUserByID(c *gin.Context) {
 }
 
 func CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.UserRequest
+	if !bindRequest(c, &req) {
 		return
 	}
+	user := req.Model()
 
-	if err := services.CreateUser(context.Background(), &user); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create user")
+	if err := services.CreateUser(requestContext(c), &user); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
 		return
 	}
 
@@ -86,18 +82,50 @@ func UpdateUser(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid user ID"))
 		return
 	}
 
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+	var req dto.UserRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	user := req.Model()
+
+	if err := services.UpdateUser(requestContext(c), id, &user); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, user)
+}
+
+// PatchUser applies a JSON Merge Patch or JSON Patch to a user and writes only the
+// columns it changes.
+func PatchUser(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid user ID"))
+		return
+	}
+
+	current, err := services.FetchUserFromDB(ctx, "user_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
+		return
+	}
+
+	req := dto.NewUserRequest(*current)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
 		return
 	}
+	user := req.Model()
 
-	if err := services.UpdateUser(context.Background(), id, &user); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user")
+	if err := services.PatchUser(ctx, id, &user, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
 		return
 	}
 
@@ -108,16 +136,12 @@ func DeleteUser(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid user ID"))
 		return
 	}
 
-	if err := services.DeleteUser(context.Background(), id); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			utils.ErrorResponse(c, http.StatusNotFound, "User not found")
-		} else {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete user")
-		}
+	if err := services.DeleteUser(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "user"))
 		return
 	}
 
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"gin-books-api/apperrors"
+	"gin-books-api/dto"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindRequest binds the JSON body into req, validates it and checks that every row it
+// references exists. On failure it sends the problem response and returns false: 400 for
+// a body that cannot be decoded, 422 with the field violations otherwise.
+func bindRequest(c *gin.Context, req dto.Request) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		if details := utils.ValidationErrors(err); details != nil {
+			utils.ErrorResponse(c, apperrors.Validation(details...))
+		} else {
+			utils.ErrorResponse(c, apperrors.BadRequest("malformed_body", "Invalid request payload"))
+		}
+		return false
+	}
+	return checkReferences(c, req)
+}
+
+// checkReferences reports a 422 naming every row referenced by req that does not exist.
+func checkReferences(c *gin.Context, req dto.Request) bool {
+	if err := services.CheckReferences(requestContext(c), req); err != nil {
+		utils.ErrorResponse(c, err)
+		return false
+	}
+	return true
+}
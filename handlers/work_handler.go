@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	"gin-books-api/dto"
+	"gin-books-api/models"
+	"gin-books-api/services"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWorks retrieves all works with their editions and implements caching.
+func GetWorks(c *gin.Context) {
+	ctx := requestContext(c)
+	cacheKey := "works_all"
+
+	// Attempt to retrieve cached data
+	var works []models.Work
+	if cache.GetCachedData(ctx, cacheKey, &works) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, works)
+		return
+	}
+
+	// If not cached, fetch from database
+	works, err := services.FetchWorksFromDB(ctx, cacheKey)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, works)
+}
+
+// GetWorkByID retrieves a work by its ID along with all of its editions and
+// the reviews of every edition. Implements caching.
+func GetWorkByID(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+
+	// Validate the work ID
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid work ID"))
+		return
+	}
+
+	cacheKey := "work_" + idParam
+	var work services.WorkDetail
+
+	// Attempt to retrieve cached data
+	if cache.GetCachedData(ctx, cacheKey, &work) {
+		c.Header("X-Data-Source", "cache")
+		utils.JSONResponse(c, http.StatusOK, work)
+		return
+	}
+
+	// If not cached, fetch from database
+	workPtr, err := services.FetchWorkFromDB(ctx, cacheKey, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	c.Header("X-Data-Source", "database")
+	utils.JSONResponse(c, http.StatusOK, workPtr)
+}
+
+// CreateWork creates a new work and stores it in the database.
+func CreateWork(c *gin.Context) {
+	var req dto.WorkRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	work := req.Model()
+
+	if err := services.CreateWork(requestContext(c), &work); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusCreated, work)
+}
+
+// UpdateWork updates an existing work by its ID.
+func UpdateWork(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid work ID"))
+		return
+	}
+
+	var req dto.WorkRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+	work := req.Model()
+
+	if err := services.UpdateWork(requestContext(c), id, &work); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, work)
+}
+
+// PatchWork applies a JSON Merge Patch or JSON Patch to a work and writes only the
+// columns it changes.
+func PatchWork(c *gin.Context) {
+	ctx := requestContext(c)
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid work ID"))
+		return
+	}
+
+	detail, err := services.FetchWorkFromDB(ctx, "work_"+idParam, id)
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	req := dto.NewWorkRequest(detail.Work)
+	columns, ok := applyPatch(c, &req)
+	if !ok {
+		return
+	}
+	work := req.Model()
+
+	if err := services.PatchWork(ctx, id, &work, columns); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, work)
+}
+
+// DeleteWork deletes a work by its ID. Its editions become standalone books.
+func DeleteWork(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid work ID"))
+		return
+	}
+
+	if err := services.DeleteWork(requestContext(c), id); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, gin.H{"message": "Work deleted successfully"})
+}
+
+// MergeWorks moves every edition of another work into this one and deletes the other work.
+func MergeWorks(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid work ID"))
+		return
+	}
+
+	var req dto.MergeWorkRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+
+	work, err := services.MergeWorks(requestContext(c), id, int(req.WorkID))
+	if err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusOK, work)
+}
+
+// SplitWork moves some editions of a work into a new work.
+func SplitWork(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, apperrors.BadRequest("invalid_id", "Invalid work ID"))
+		return
+	}
+
+	var req dto.SplitWorkRequest
+	if !bindRequest(c, &req) {
+		return
+	}
+
+	work := req.Model()
+	if err := services.SplitWork(requestContext(c), id, req.BookIDs, &work); err != nil {
+		utils.ErrorResponse(c, apperrors.FromDB(err, "work"))
+		return
+	}
+
+	utils.JSONResponse(c, http.StatusCreated, work)
+}
@@ -1,50 +1,293 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
 	config "gin-books-api/configs"
+	"gin-books-api/grpcserver"
 	"gin-books-api/handlers"
+	"gin-books-api/logging"
+	"gin-books-api/metrics"
+	"gin-books-api/middleware"
 	"gin-books-api/models"
+	"gin-books-api/openapi"
+	"gin-books-api/services"
+	"gin-books-api/tracing"
+	"gin-books-api/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	config.InitDB()
-	config.GetDB().AutoMigrate(
-		&models.Author{},
-		&models.Book{},
-		&models.BorrowedBook{},
-		&models.Category{},
-		&models.Publisher{},
-		&models.Review{},
-		&models.User{})
-	config.InitRedis()
+	// The .env file may set LOG_LEVEL and LOG_FORMAT, so it is loaded first
+	envErr := config.LoadEnv()
+	logging.Init()
+	if envErr != nil {
+		slog.Debug("No .env file loaded", "error", envErr)
+	}
+
+	// Subcommands, such as "import-marc", run against the database and exit
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	connect()
+	registerMetrics()
+	shutdownTracing := initTracing()
+
+	// gRPC and its JSON mapping listen on a port of their own
+	grpcServer, err := grpcserver.Listen(":9090", grpcserver.Options{RateLimiter: rateLimiter, TrustedProxies: trustedProxies()})
+	if err != nil {
+		slog.Error("Failed to start the gRPC server", "error", err)
+		os.Exit(1)
+	}
+	go func() {
+		if err := grpcServer.Serve(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("gRPC server stopped", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	server := &http.Server{Addr: ":8080", Handler: newRouter()}
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server stopped", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// On SIGINT or SIGTERM, fail readiness for DRAIN_DELAY so that load balancers stop
+	// sending requests, then finish those under way. A second signal stops at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	delay := drainDelay()
+	slog.Info("Draining", "delay", delay.String())
+	services.Drain()
+	time.Sleep(delay)
+
+	// Both ports finish their requests within shutdownTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for name, shutdown := range map[string]func(context.Context) error{"HTTP": server.Shutdown, "gRPC": grpcServer.Shutdown} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("Requests were cut short by the shutdown", "server", name, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush the spans", "error", err)
+	}
+	slog.Info("Stopped")
+}
+
+// shutdownTimeout bounds how long requests under way, and spans not yet exported, are
+// waited for on shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// drainDelay returns how long readiness fails before the server stops accepting
+// requests: DRAIN_DELAY, such as "15s", or 10 seconds.
+func drainDelay() time.Duration {
+	if value := os.Getenv("DRAIN_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err == nil && delay >= 0 {
+			return delay
+		}
+		slog.Warn("Invalid DRAIN_DELAY", "value", value)
+	}
+	return 10 * time.Second
+}
+
+// trustedProxies returns the addresses or CIDR ranges of the reverse proxies whose
+// X-Forwarded-For is believed: TRUSTED_PROXIES, separated by commas, or none.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// corsConfig allows browsers on any origin to send the request headers of the API, such
+// as Idempotency-Key, and to read its response headers, such as the RateLimit-* ones.
+func corsConfig() cors.Config {
+	config := cors.DefaultConfig()
+	config.AllowAllOrigins = true
+	config.AllowHeaders = append(config.AllowHeaders,
+		"X-User-ID", "X-Request-ID", "Idempotency-Key", "If-None-Match", "If-Modified-Since")
+	config.ExposeHeaders = []string{
+		"X-Request-ID", "X-Trace-ID", "X-Data-Source", "Idempotent-Replayed", "Location", "ETag",
+		"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After",
+		"Deprecation", "Sunset", "Link",
+	}
+	return config
+}
+
+// newRouter registers the middleware and routes of the API.
+func newRouter() *gin.Engine {
+	r := gin.New()
 
-	r := gin.Default()
+	// Take the client IP address of rate limits and idempotency keys from
+	// X-Forwarded-For only when sent by a trusted proxy
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		slog.Warn("Invalid TRUSTED_PROXIES; trusting no proxy", "error", err)
+		_ = r.SetTrustedProxies(nil)
+	}
+
+	// Tag every request with an ID logged with its records, and trace it as a span
+	// whose trace ID is reported in error responses
+	r.Use(middleware.RequestID(), middleware.TraceID())
+
+	// Log and measure every request, including those whose handler panicked
+	r.Use(middleware.AccessLog(), middleware.Metrics(), gin.CustomRecoveryWithWriter(io.Discard, handlers.Recover))
 
 	// Add CORS middleware
-	r.Use(cors.Default())
+	r.Use(cors.New(corsConfig()))
+	r.NoRoute(handlers.NoRoute)
+
+	// Health checks of load balancers and orchestrators, which are not rate limited
+	r.GET("/healthz", handlers.GetHealthz)
+	r.GET("/readyz", handlers.GetReadyz)
+
+	// Limit the request rate of each client, see ratelimits.go
+	r.Use(middleware.RateLimit(rateLimiter, utils.ErrorResponse))
+
+	// Replay the response to a POST retried with the same Idempotency-Key
+	r.Use(middleware.Idempotency(utils.ErrorResponse))
+
+	// Prometheus metrics
+	r.GET("/metrics", handlers.GetMetrics)
 
+	// API description, generated from the routes below
+	r.GET("/openapi.json", handlers.OpenAPI(func() []openapi.Route { return apiRoutes(r) }))
+	r.GET("/docs", handlers.GetDocs)
+
+	// GraphQL API over the same services, unversioned
+	r.GET("/graphql", handlers.GetGraphQL)
+	r.POST("/graphql", handlers.PostGraphQL)
+
+	// OPDS catalogue routes
+	r.GET("/opds", handlers.GetOPDSRoot)
+	r.GET("/opds/search.xml", handlers.GetOPDSSearch)
+	r.GET("/opds/new", handlers.GetOPDSNewArrivals)
+	r.GET("/opds/books", handlers.GetOPDSBooks)
+	r.GET("/opds/categories", handlers.GetOPDSCategories)
+	r.GET("/opds/categories/:id", handlers.GetOPDSCategory)
+	r.GET("/opds/authors", handlers.GetOPDSAuthors)
+	r.GET("/opds/authors/:id", handlers.GetOPDSAuthor)
+	r.GET("/opds/publishers", handlers.GetOPDSPublishers)
+	r.GET("/opds/publishers/:id", handlers.GetOPDSPublisher)
+
+	// Sitemap of every book and author for search engines
+	r.GET("/sitemap.xml", handlers.GetSitemap)
+
+	// Feed routes, such as /feeds/new.atom and /feeds/authors/3.rss
+	r.GET("/feeds/:file", handlers.GetNewBooksFeed)
+	r.GET("/feeds/categories/:file", handlers.GetCategoryFeed)
+	r.GET("/feeds/authors/:file", handlers.GetAuthorFeed)
+	r.GET("/feeds/publishers/:file", handlers.GetPublisherFeed)
+
+	// Versions of the API, under /api/v1, /api/v2, ...
+	for _, version := range apiVersions {
+		group := r.Group(version.prefix())
+		if !version.deprecation.IsZero() {
+			group.Use(middleware.Deprecated(version.prefix(), version.successor, version.deprecation, version.sunset))
+		}
+		registerRoutes(routeGroup{RouterGroup: group, overrides: version.overrides})
+	}
+
+	// The routes from before the API was versioned remain as aliases of v1
+	legacy := r.Group("/", middleware.Deprecated("", "/api/v1", legacyDeprecation, legacySunset))
+	registerRoutes(routeGroup{RouterGroup: legacy})
+
+	return r
+}
+
+// registerRoutes registers the routes of one version of the API.
+func registerRoutes(r routeGroup) {
 	// Book routes
 	r.GET("/books", handlers.GetBooks)
 	r.GET("/books/:id", handlers.GetBookByID)
 	r.POST("/books", handlers.CreateBook)
 	r.PUT("/books/:id", handlers.UpdateBook)
+	r.PATCH("/books/:id", handlers.PatchBook)
 	r.DELETE("/books/:id", handlers.DeleteBook)
+	r.GET("/books/:id/series", handlers.GetBookSeries)
+	r.GET("/books/:id/export", handlers.ExportBookCitation)
+	r.GET("/books/export", handlers.ExportBookCitations)
+
+	// Bulk import routes
+	r.POST("/import/books", handlers.ImportBooks)
+	r.POST("/import/marc", handlers.ImportMARC)
+
+	// Export routes
+	r.GET("/export/:entity", handlers.ExportEntity)
+
+	// Work routes
+	r.GET("/works", handlers.GetWorks)
+	r.GET("/works/:id", handlers.GetWorkByID)
+	r.POST("/works", handlers.CreateWork)
+	r.PUT("/works/:id", handlers.UpdateWork)
+	r.PATCH("/works/:id", handlers.PatchWork)
+	r.DELETE("/works/:id", handlers.DeleteWork)
+	r.POST("/works/:id/merge", handlers.MergeWorks)
+	r.POST("/works/:id/split", handlers.SplitWork)
+
+	// Series routes
+	r.GET("/series", handlers.GetSeriesList)
+	r.GET("/series/:id", handlers.GetSeriesByID)
+	r.POST("/series", handlers.CreateSeries)
+	r.PUT("/series/:id", handlers.UpdateSeries)
+	r.PATCH("/series/:id", handlers.PatchSeries)
+	r.DELETE("/series/:id", handlers.DeleteSeries)
+	r.POST("/series/:id/books", handlers.AddBookToSeries)
+	r.DELETE("/series/:id/books/:book_id", handlers.RemoveBookFromSeries)
+
+	// Tag routes
+	r.GET("/tags", handlers.GetTags)
+
+	// Subject routes
+	r.GET("/subjects", handlers.GetSubjects)
+	r.GET("/subjects/:id", handlers.GetSubjectByID)
+	r.GET("/subjects/:id/books", handlers.GetSubjectBooks)
+	r.POST("/subjects", handlers.CreateSubject)
+	r.PUT("/subjects/:id", handlers.UpdateSubject)
+	r.PATCH("/subjects/:id", handlers.PatchSubject)
+	r.DELETE("/subjects/:id", handlers.DeleteSubject)
 
 	// Author routes
 	r.GET("/authors", handlers.GetAuthors)
 	r.GET("/authors/:id", handlers.GetAuthorByID)
 	r.POST("/authors", handlers.CreateAuthor)
 	r.PUT("/authors/:id", handlers.UpdateAuthor)
+	r.PATCH("/authors/:id", handlers.PatchAuthor)
 	r.DELETE("/authors/:id", handlers.DeleteAuthor)
 
 	// Category routes
 	r.GET("/categories", handlers.GetCategories)
 	r.GET("/categories/:id", handlers.GetCategoryByID)
+	r.GET("/categories/:id/books", handlers.GetCategoryBooks)
 	r.POST("/categories", handlers.CreateCategory)
 	r.PUT("/categories/:id", handlers.UpdateCategory)
+	r.PATCH("/categories/:id", handlers.PatchCategory)
 	r.DELETE("/categories/:id", handlers.DeleteCategory)
 
 	// Publisher routes
@@ -52,6 +295,7 @@ func main() {
 	r.GET("/publishers/:id", handlers.GetPublisherByID)
 	r.POST("/publishers", handlers.CreatePublisher)
 	r.PUT("/publishers/:id", handlers.UpdatePublisher)
+	r.PATCH("/publishers/:id", handlers.PatchPublisher)
 	r.DELETE("/publishers/:id", handlers.DeletePublisher)
 
 	// Review routes
@@ -59,6 +303,7 @@ func main() {
 	r.GET("/reviews/:id", handlers.GetReviewByID)
 	r.POST("/reviews", handlers.CreateReview)
 	r.PUT("/reviews/:id", handlers.UpdateReview)
+	r.PATCH("/reviews/:id", handlers.PatchReview)
 	r.DELETE("/reviews/:id", handlers.DeleteReview)
 
 	// User routes
@@ -66,7 +311,77 @@ func main() {
 	r.GET("/users/:id", handlers.GetUserByID)
 	r.POST("/users", handlers.CreateUser)
 	r.PUT("/users/:id", handlers.UpdateUser)
+	r.PATCH("/users/:id", handlers.PatchUser)
 	r.DELETE("/users/:id", handlers.DeleteUser)
+	r.GET("/users/:id/activity", handlers.GetUserActivity)
+
+	// Loan routes
+	r.GET("/loans", handlers.GetLoans)
+	r.GET("/loans/:id", handlers.GetLoanByID)
+	r.POST("/loans", handlers.BorrowBook)
+	r.DELETE("/loans/:id", handlers.ReturnBook)
+
+	// Audit routes
+	r.GET("/audit", handlers.GetAuditLogs)
+}
+
+// connect opens the database, migrates its schema and connects to Redis. The schema
+// version is recorded only once the migration succeeded, so that readiness fails
+// otherwise.
+func connect() {
+	config.InitDB()
+	err := services.SeparateTiedSeriesEntries(context.Background())
+	if err == nil {
+		err = config.GetDB().AutoMigrate(
+			&models.AuditLog{},
+			&models.Author{},
+			&models.Book{},
+			&models.BorrowedBook{},
+			&models.Category{},
+			&models.Publisher{},
+			&models.Review{},
+			&models.Series{},
+			&models.SeriesEntry{},
+			&models.Subject{},
+			&models.Tag{},
+			&models.User{},
+			&models.Work{},
+			&models.SchemaMigration{})
+	}
+	if err == nil {
+		err = services.NormalizeStoredISBNs(context.Background())
+	}
+	if err == nil {
+		err = services.RecordSchemaVersion(context.Background())
+	}
+	if err != nil {
+		slog.Error("Failed to migrate the schema", "error", err)
+	}
+	config.InitRedis()
+}
+
+// registerMetrics registers the metrics of the database and of lending, served with
+// the others under /metrics.
+func registerMetrics() {
+	if err := metrics.RegisterDB(config.GetDB(), os.Getenv("DB_NAME")); err != nil {
+		slog.Error("Failed to register the database metrics", "error", err)
+	}
+	metrics.RegisterLending(func(ctx context.Context) (metrics.Lending, error) {
+		stats, err := services.FetchLendingStats(ctx)
+		return metrics.Lending(stats), err
+	})
+}
 
-	r.Run(":8080")
+// initTracing installs the tracer provider, configured by OTEL_TRACES_EXPORTER, and
+// traces the queries of the database. It returns the function flushing the spans.
+func initTracing() func(context.Context) error {
+	shutdown, err := tracing.Init(context.Background())
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	if err := config.GetDB().Use(tracing.GormPlugin{}); err != nil {
+		slog.Error("Failed to trace the database", "error", err)
+	}
+	return shutdown
 }
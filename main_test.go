@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gin-books-api/handlers"
+	"gin-books-api/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesAreDocumented fails for a route registered without its description in
+// handlers.Operations, which the OpenAPI document is generated from.
+func TestRoutesAreDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, route := range openapi.Undocumented(apiRoutes(newRouter()), handlers.Operations) {
+		t.Errorf("%s is not described in handlers.Operations", route)
+	}
+}
+
+// TestCORSAllowsTheHeadersOfTheAPI checks that browsers may send Idempotency-Key.
+func TestCORSAllowsTheHeadersOfTheAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	request := httptest.NewRequest(http.MethodOptions, "/api/v1/reviews", nil)
+	request.Header.Set("Origin", "https://app.example.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	request.Header.Set("Access-Control-Request-Headers", "content-type,idempotency-key,x-request-id")
+	response := httptest.NewRecorder()
+	newRouter().ServeHTTP(response, request)
+
+	allowed := strings.ToLower(response.Header().Get("Access-Control-Allow-Headers"))
+	if response.Code != http.StatusNoContent || !strings.Contains(allowed, "idempotency-key") || !strings.Contains(allowed, "x-request-id") {
+		t.Errorf("preflight = %d with Access-Control-Allow-Headers %q, want 204 allowing Idempotency-Key and X-Request-ID", response.Code, allowed)
+	}
+}
@@ -0,0 +1,80 @@
+// Package marc models MARC 21 bibliographic records and encodes them as MARCXML
+// (the MARC 21 slim schema).
+package marc
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Namespace is the XML namespace of the MARC 21 slim schema.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+// Record is a MARC 21 record: a 24-character leader, control fields (001-009) and
+// data fields with indicators and subfields.
+type Record struct {
+	XMLName       xml.Name       `xml:"record"`
+	Leader        string         `xml:"leader"`
+	ControlFields []ControlField `xml:"controlfield"`
+	DataFields    []DataField    `xml:"datafield"`
+}
+
+// ControlField is a field without indicators or subfields, such as 001 or 008.
+type ControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+// DataField is a field with two indicators and a list of subfields.
+type DataField struct {
+	Tag       string     `xml:"tag,attr"`
+	Ind1      string     `xml:"ind1,attr"`
+	Ind2      string     `xml:"ind2,attr"`
+	Subfields []Subfield `xml:"subfield"`
+}
+
+// Subfield is a coded part of a data field, such as $a.
+type Subfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Collection is the root element of a MARCXML document with any number of records.
+type Collection struct {
+	XMLName xml.Name `xml:"http://www.loc.gov/MARC21/slim collection"`
+	Records []Record `xml:"record"`
+}
+
+// AddControlField appends a control field.
+func (r *Record) AddControlField(tag, value string) {
+	r.ControlFields = append(r.ControlFields, ControlField{Tag: tag, Value: value})
+}
+
+// AddDataField appends a data field with the given indicators and subfields, given
+// as code-value pairs. Subfields with an empty value are left out, and so is the
+// field when none is left.
+func (r *Record) AddDataField(tag string, ind1, ind2 byte, codesAndValues ...string) {
+	field := DataField{Tag: tag, Ind1: string(ind1), Ind2: string(ind2)}
+	for i := 0; i+1 < len(codesAndValues); i += 2 {
+		if codesAndValues[i+1] != "" {
+			field.Subfields = append(field.Subfields, Subfield{Code: codesAndValues[i], Value: codesAndValues[i+1]})
+		}
+	}
+	if len(field.Subfields) > 0 {
+		r.DataFields = append(r.DataFields, field)
+	}
+}
+
+// WriteXML writes records as a MARCXML collection.
+func WriteXML(w io.Writer, records []Record) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(Collection{Records: records}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,230 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ISO 2709 delimiters.
+const (
+	subfieldDelimiter  = 0x1F
+	fieldTerminator    = 0x1E
+	recordTerminator   = 0x1D
+	leaderLength       = 24
+	directoryEntrySize = 12
+)
+
+// RecordError reports a record that could not be parsed. Reading can go on with the
+// next record.
+type RecordError struct {
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return "invalid MARC record: " + e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads MARC records one at a time.
+type Reader interface {
+	// Next returns the next record. It returns io.EOF after the last record and a
+	// *RecordError for a record that is skipped. Any other error ends the input.
+	Next() (Record, error)
+}
+
+// NewBinaryReader returns a reader of MARC 21 records in ISO 2709 transmission format,
+// the format of .mrc files. Records encoded in MARC-8 rather than UTF-8 (leader/09) are
+// read as is, which is exact for their ASCII characters only.
+func NewBinaryReader(r io.Reader) Reader {
+	return &binaryReader{reader: bufio.NewReader(r)}
+}
+
+type binaryReader struct {
+	reader *bufio.Reader
+}
+
+func (r *binaryReader) Next() (Record, error) {
+	// Skip line breaks some tools add between records
+	for {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return Record{}, err
+		}
+		if b != '\n' && b != '\r' {
+			r.reader.UnreadByte()
+			break
+		}
+	}
+
+	prefix, err := r.reader.Peek(5)
+	if err != nil {
+		if err == io.EOF {
+			return Record{}, io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	length, ok := parseNumber(string(prefix))
+	if !ok || length < leaderLength+1 {
+		// Without a usable length, resynchronize on the next record terminator
+		if _, err := r.reader.ReadBytes(recordTerminator); err != nil && err != io.EOF {
+			return Record{}, err
+		}
+		return Record{}, &RecordError{Err: fmt.Errorf("invalid record length %q", prefix)}
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	record, err := parseBinary(data)
+	if err != nil {
+		return Record{}, &RecordError{Err: err}
+	}
+	return record, nil
+}
+
+// parseBinary parses one ISO 2709 record: the leader, a directory of 12-byte entries
+// (tag, length, offset) and the variable fields starting at the base address.
+func parseBinary(data []byte) (Record, error) {
+	if data[len(data)-1] != recordTerminator {
+		return Record{}, errors.New("missing record terminator")
+	}
+	leader := string(data[:leaderLength])
+	base, ok := parseNumber(leader[12:17])
+	if !ok || base <= leaderLength || base > len(data) {
+		return Record{}, fmt.Errorf("invalid base address %q", leader[12:17])
+	}
+
+	record := Record{Leader: leader}
+	directory := data[leaderLength : base-1]
+	if len(directory)%directoryEntrySize != 0 {
+		return Record{}, errors.New("directory is not a multiple of 12 bytes")
+	}
+	for i := 0; i < len(directory); i += directoryEntrySize {
+		entry := string(directory[i : i+directoryEntrySize])
+		tag := entry[:3]
+		length, lengthOK := parseNumber(entry[3:7])
+		start, startOK := parseNumber(entry[7:12])
+		if !lengthOK || !startOK || start < 0 || base+start < leaderLength || base+start+length > len(data) || length < 1 {
+			return Record{}, fmt.Errorf("invalid directory entry %q", entry)
+		}
+		field := data[base+start : base+start+length-1] // Without the field terminator
+
+		if tag < "010" {
+			record.AddControlField(tag, string(field))
+			continue
+		}
+		if len(field) < 2 {
+			return Record{}, fmt.Errorf("field %s has no indicators", tag)
+		}
+		dataField := DataField{Tag: tag, Ind1: string(field[0]), Ind2: string(field[1])}
+		for _, subfield := range bytes.Split(field[2:], []byte{subfieldDelimiter}) {
+			if len(subfield) == 0 {
+				continue
+			}
+			dataField.Subfields = append(dataField.Subfields, Subfield{
+				Code:  string(subfield[0]),
+				Value: string(subfield[1:]),
+			})
+		}
+		record.DataFields = append(record.DataFields, dataField)
+	}
+	return record, nil
+}
+
+// parseNumber parses a numeric field of a leader or directory entry, which is made of
+// ASCII digits only: unlike strconv.Atoi, it refuses signs, so that a hostile record
+// cannot point a field before the start of the record.
+func parseNumber(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
+
+// NewXMLReader returns a reader of the <record> elements of a MARCXML document, whether
+// it is a <collection> or a single record. Records are decoded one at a time.
+func NewXMLReader(r io.Reader) Reader {
+	return &xmlReader{decoder: xml.NewDecoder(r)}
+}
+
+type xmlReader struct {
+	decoder *xml.Decoder
+}
+
+func (r *xmlReader) Next() (Record, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return Record{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var record Record
+		if err := r.decoder.DecodeElement(&record, &start); err != nil {
+			return Record{}, err
+		}
+		return record, nil
+	}
+}
+
+// ControlField returns the value of the first control field with the given tag.
+func (r *Record) ControlField(tag string) string {
+	for _, field := range r.ControlFields {
+		if field.Tag == tag {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Fields returns the data fields with the given tag.
+func (r *Record) Fields(tag string) []DataField {
+	var fields []DataField
+	for _, field := range r.DataFields {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Subfield returns the value of the first subfield with the given code.
+func (f DataField) Subfield(code string) string {
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+	return ""
+}
+
+// SubfieldValues returns the values of all subfields with the given code.
+func (f DataField) SubfieldValues(code string) []string {
+	var values []string
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			values = append(values, subfield.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,91 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// binaryRecord assembles an ISO 2709 record from its directory entries and variable
+// fields. base replaces the base address of the leader unless empty.
+func binaryRecord(base string, entries []string, fields string) string {
+	directory := strings.Join(entries, "") + string(rune(fieldTerminator))
+	if base == "" {
+		base = fmt.Sprintf("%05d", leaderLength+len(directory))
+	}
+	length := leaderLength + len(directory) + len(fields) + 1
+	return fmt.Sprintf("%05dnam a22%s   4500", length, base) + directory + fields + string(rune(recordTerminator))
+}
+
+const titleField = "10\x1faA title\x1e"
+
+func TestBinaryReaderRejectsMalformedDirectories(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+	}{
+		{"signed start", binaryRecord("", []string{"2450012-9999"}, titleField)},
+		{"signed length", binaryRecord("", []string{"245+01200000"}, titleField)},
+		{"start before the leader", binaryRecord("", []string{"2450012-0030"}, titleField)},
+		{"blank start", binaryRecord("", []string{"2450012    0"}, titleField)},
+		{"field past the end", binaryRecord("", []string{"245001200005"}, titleField)},
+		{"empty field", binaryRecord("", []string{"245000000000"}, titleField)},
+		{"signed base address", binaryRecord("+0037", []string{"245001200000"}, titleField)},
+		{"base address in the leader", binaryRecord("00010", []string{"245001200000"}, titleField)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The malformed record is skipped and the next one is read
+			input := test.record + binaryRecord("", []string{"245001200000"}, titleField)
+			reader := NewBinaryReader(strings.NewReader(input))
+
+			_, err := reader.Next()
+			var recordErr *RecordError
+			if !errors.As(err, &recordErr) {
+				t.Fatalf("Next() error = %v, want a *RecordError", err)
+			}
+
+			record, err := reader.Next()
+			if err != nil {
+				t.Fatalf("Next() after the malformed record: %v", err)
+			}
+			if len(record.DataFields) != 1 || record.DataFields[0].Tag != "245" {
+				t.Fatalf("Next() after the malformed record = %+v, want the 245 field", record.DataFields)
+			}
+			if _, err := reader.Next(); err != io.EOF {
+				t.Fatalf("Next() at the end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestBinaryReaderRejectsSignedRecordLength(t *testing.T) {
+	record := binaryRecord("", []string{"245001200000"}, titleField)
+	reader := NewBinaryReader(strings.NewReader("+" + record[1:]))
+	var recordErr *RecordError
+	if _, err := reader.Next(); !errors.As(err, &recordErr) {
+		t.Fatalf("Next() error = %v, want a *RecordError", err)
+	}
+}
+
+func TestBinaryReaderReadsFields(t *testing.T) {
+	record := binaryRecord("", []string{"001000400000", "245001200004"}, "123\x1e"+titleField)
+	got, err := NewBinaryReader(bytes.NewReader([]byte(record))).Next()
+	if err != nil {
+		t.Fatalf("Next(): %v", err)
+	}
+	if len(got.ControlFields) != 1 || got.ControlFields[0].Value != "123" {
+		t.Errorf("ControlFields = %+v, want 001 123", got.ControlFields)
+	}
+	if len(got.DataFields) != 1 {
+		t.Fatalf("DataFields = %+v, want one 245 field", got.DataFields)
+	}
+	field := got.DataFields[0]
+	if field.Tag != "245" || field.Ind1 != "1" || field.Ind2 != "0" ||
+		len(field.Subfields) != 1 || field.Subfields[0] != (Subfield{Code: "a", Value: "A title"}) {
+		t.Errorf("DataFields[0] = %+v, want 245 10 $a A title", field)
+	}
+}
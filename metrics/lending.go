@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lendingTimeout bounds the queries of the lending gauges at each scrape.
+const lendingTimeout = 5 * time.Second
+
+// Lending holds the state of the library's lending at a moment.
+type Lending struct {
+	ActiveLoans    int64 // Books on loan
+	OverdueLoans   int64 // Books on loan past their due date
+	AvailableBooks int64 // Books that may be borrowed
+}
+
+var (
+	activeLoansDesc    = prometheus.NewDesc("library_active_loans", "Books on loan.", nil, nil)
+	overdueLoansDesc   = prometheus.NewDesc("library_overdue_loans", "Books on loan past their due date.", nil, nil)
+	availableBooksDesc = prometheus.NewDesc("library_available_books", "Books that may be borrowed.", nil, nil)
+)
+
+// lendingCollector reports the lending gauges, fetched at each scrape.
+type lendingCollector struct {
+	fetch func(context.Context) (Lending, error)
+}
+
+// RegisterLending registers the lending gauges, whose values fetch returns.
+func RegisterLending(fetch func(context.Context) (Lending, error)) {
+	prometheus.MustRegister(lendingCollector{fetch: fetch})
+}
+
+// Describe implements prometheus.Collector.
+func (c lendingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeLoansDesc
+	ch <- overdueLoansDesc
+	ch <- availableBooksDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c lendingCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), lendingTimeout)
+	defer cancel()
+
+	lending, err := c.fetch(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch the lending gauges", "error", err)
+		for _, desc := range []*prometheus.Desc{activeLoansDesc, overdueLoansDesc, availableBooksDesc} {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+		}
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(activeLoansDesc, prometheus.GaugeValue, float64(lending.ActiveLoans))
+	ch <- prometheus.MustNewConstMetric(overdueLoansDesc, prometheus.GaugeValue, float64(lending.OverdueLoans))
+	ch <- prometheus.MustNewConstMetric(availableBooksDesc, prometheus.GaugeValue, float64(lending.AvailableBooks))
+}
@@ -0,0 +1,83 @@
+// Package metrics defines the Prometheus metrics of the API: HTTP requests, database
+// queries and connections, cache lookups, and the state of the library's lending.
+// They are registered with the default registry and served by Handler.
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests, by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Lookups in the Redis cache, by key family and result: hit, miss or error.",
+	}, []string{"family", "result"})
+
+	cacheWriteErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_write_errors_total",
+		Help: "Failed writes to the Redis cache, by key family.",
+	}, []string{"family"})
+)
+
+// Results of cache lookups.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Handler serves the metrics of the default registry in the Prometheus format. A
+// collector that fails, such as the lending gauges while the database is down, is left
+// out of the response rather than failing it.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// ObserveRequest records an HTTP request served for route, the pattern it matched
+// such as /api/v1/books/:id. Requests matching no route are recorded under
+// "unmatched", so that arbitrary paths do not become series of their own.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveCacheLookup records a lookup of key in the cache with the given result.
+func ObserveCacheLookup(key, result string) {
+	cacheLookups.WithLabelValues(CacheFamily(key), result).Inc()
+}
+
+// ObserveCacheWriteError records a failed write of key to the cache.
+func ObserveCacheWriteError(key string) {
+	cacheWriteErrors.WithLabelValues(CacheFamily(key)).Inc()
+}
+
+// CacheFamily returns the family of a cache key: the key itself for lists, such as
+// books_all, and the prefix of the others, such as book_* for book_12.
+func CacheFamily(key string) string {
+	if strings.HasSuffix(key, "_all") {
+		return key
+	}
+	if prefix, _, ok := strings.Cut(key, "_"); ok {
+		return prefix + "_*"
+	}
+	return key
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client identifies who sent a request, for rate limits and idempotency keys.
+// X-User-ID is not authenticated, so a user is only told apart among the clients of
+// one IP address: sending the ID of another user from elsewhere gets a client of its
+// own, rather than that user's.
+type Client struct {
+	IP     string
+	UserID uint64 // 0 for anonymous clients
+}
+
+// ClientOf returns the client that sent the request of c. Its IP address is taken from
+// X-Forwarded-For only behind the proxies trusted by the engine.
+func ClientOf(c *gin.Context) Client {
+	client := Client{IP: c.ClientIP()}
+	if id, err := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 64); err == nil {
+		client.UserID = id
+	}
+	return client
+}
+
+// Key identifies the client in cache keys, e.g. "ip:192.0.2.1_user:7".
+func (c Client) Key() string {
+	if c.UserID == 0 {
+		return "ip:" + c.IP
+	}
+	return "ip:" + c.IP + "_user:" + strconv.FormatUint(c.UserID, 10)
+}
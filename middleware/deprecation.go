@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks the routes of a deprecated version of the API, served under prefix.
+// Responses carry the date of the deprecation (RFC 9745), the date after which the
+// routes may be removed (RFC 8594) and a link to the same route in the successor
+// version.
+func Deprecated(prefix, successor string, deprecation, sunset time.Time) gin.HandlerFunc {
+	deprecationHeader := "@" + strconv.FormatInt(deprecation.Unix(), 10)
+	sunsetHeader := sunset.UTC().Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecationHeader)
+		c.Header("Sunset", sunsetHeader)
+		c.Header("Link", "<"+successor+strings.TrimPrefix(c.Request.URL.Path, prefix)+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,288 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyTTL is how long the response to a request with an Idempotency-Key is
+	// kept for replays.
+	IdempotencyTTL = 24 * time.Hour
+
+	// idempotencyLockTTL bounds how long a key stays claimed by a request still being
+	// processed, so that a key is freed if the server stops before responding. The claim
+	// is renewed every third of it while the request is processed, however long.
+	idempotencyLockTTL = time.Minute
+
+	// maxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+	maxIdempotencyKeyLength = 255
+
+	// maxIdempotentBodySize is the largest body of a request with an Idempotency-Key,
+	// which is read into memory to be fingerprinted.
+	maxIdempotentBodySize = 1 << 20
+)
+
+var (
+	// idempotencyRenewInterval is how often a claimed key is renewed.
+	idempotencyRenewInterval = idempotencyLockTTL / 3
+
+	errIdempotencyKeyInvalid = apperrors.BadRequest("invalid_idempotency_key",
+		"Idempotency-Key must be between 1 and 255 characters")
+	errIdempotencyKeyInUse = apperrors.Conflict("idempotency_key_in_use",
+		"A request with this Idempotency-Key is still being processed")
+	errIdempotencyKeyReused = apperrors.New(apperrors.KindValidation, "idempotency_key_reused",
+		"This Idempotency-Key was used for a different request")
+	errIdempotentBodyTooLarge = apperrors.New(apperrors.KindPayloadTooLarge, "request_too_large",
+		"The body of a request with an Idempotency-Key must be at most 1 MiB")
+)
+
+// IdempotentResponse is the record of a request with an Idempotency-Key: the
+// fingerprint of the request and, once it has been processed, its response.
+type IdempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"` // 0 while the request is being processed
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Idempotency honours the Idempotency-Key header of POST requests, so that a client
+// may retry a request without creating a duplicate. The first request with a key is
+// processed and its response stored for IdempotencyTTL; a retry of the same request
+// gets the stored response, with Idempotent-Replayed: true. A request sent while one
+// with the same key is being processed is refused with 409, and a different request
+// reusing a key with 422. Keys are scoped by the X-User-ID of the client, and not by its
+// address, so that a retry from another network still gets the first response; since
+// X-User-ID is not authenticated, it is the fingerprint of the request that keeps a key
+// sent with another user's ID from replaying that user's response to another request.
+// Only the headers set after this middleware are stored, so that a replay carries its
+// own request ID and rate limits. Since the body is read into memory, requests with a
+// key and a body over 1 MiB are refused with 413.
+//
+// Responses with a 5xx status are not stored, so that the request can be retried.
+// If Redis is down, requests are processed as if they had no key. renderError writes
+// the error responses.
+func Idempotency(renderError func(*gin.Context, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := c.Request.Header["Idempotency-Key"]
+		if c.Request.Method != http.MethodPost || !ok {
+			c.Next()
+			return
+		}
+		if err := CheckIdempotencyKey(key[0]); err != nil {
+			renderError(c, err)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				renderError(c, errIdempotentBodyTooLarge)
+			} else {
+				renderError(c, apperrors.BadRequest("unreadable_body", "The request body could not be read"))
+			}
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The response is stored even if the client goes away while it is being made
+		ctx := context.WithoutCancel(c.Request.Context())
+		claim, stored, err := ClaimIdempotencyKey(ctx, ClientOf(c).UserID, key[0], requestFingerprint(c.Request, body))
+		switch {
+		case err != nil:
+			renderError(c, err)
+			c.Abort()
+			return
+		case stored != nil:
+			replay(c, stored)
+			return
+		case claim == nil:
+			c.Next()
+			return
+		}
+		// Frees the key if the handler panics
+		defer claim.Release(ctx)
+
+		before := c.Writer.Header().Clone()
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			claim.Release(ctx)
+			return
+		}
+		header := setHeaders(before, recorder.Header())
+		claim.Store(ctx, IdempotentResponse{Status: status, Header: header, Body: recorder.body.Bytes()})
+	}
+}
+
+// CheckIdempotencyKey returns the error refusing key if it is not a valid
+// Idempotency-Key, or nil.
+func CheckIdempotencyKey(key string) error {
+	if len(key) == 0 || len(key) > maxIdempotencyKeyLength {
+		return errIdempotencyKeyInvalid
+	}
+	return nil
+}
+
+// IdempotencyClaim is a key claimed by a request being processed. The claim is renewed
+// until the response is stored or the key released.
+type IdempotencyClaim struct {
+	key         string
+	fingerprint string
+	stop        chan struct{}
+	stopped     chan struct{}
+	finished    bool
+}
+
+// ClaimIdempotencyKey claims key, sent by the user with userID, or 0 for anonymous
+// clients, for the request identified by
+// fingerprint. If an earlier request claimed it, it returns the response stored for
+// that request instead, or an error if that request was a different one or is still
+// being processed. It returns neither if Redis failed, in which case the request is
+// processed as if it had no key.
+func ClaimIdempotencyKey(ctx context.Context, userID uint64, key, fingerprint string) (*IdempotencyClaim, *IdempotentResponse, error) {
+	cacheKey := "idempotency_user:" + strconv.FormatUint(userID, 10) + "_" + key
+	claimed, err := cache.ClaimKey(ctx, cacheKey, IdempotentResponse{Fingerprint: fingerprint}, idempotencyLockTTL)
+	if err != nil {
+		slog.WarnContext(ctx, "Redis SETNX failed", "key", cacheKey, "error", err)
+		return nil, nil, nil
+	}
+	if claimed {
+		claim := &IdempotencyClaim{key: cacheKey, fingerprint: fingerprint, stop: make(chan struct{}), stopped: make(chan struct{})}
+		go claim.renew(ctx)
+		return claim, nil, nil
+	}
+
+	var stored IdempotentResponse
+	if !cache.GetCachedData(ctx, cacheKey, &stored) {
+		// The key expired in between, or Redis failed: the earlier request is gone
+		return nil, nil, errIdempotencyKeyInUse
+	}
+	switch {
+	case stored.Fingerprint != fingerprint:
+		return nil, nil, errIdempotencyKeyReused
+	case stored.Status == 0:
+		return nil, nil, errIdempotencyKeyInUse
+	}
+	return nil, &stored, nil
+}
+
+// renew extends the claim until it is stopped.
+func (c *IdempotencyClaim) renew(ctx context.Context) {
+	defer close(c.stopped)
+	ticker := time.NewTicker(idempotencyRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if err := cache.ExpireKey(ctx, c.key, idempotencyLockTTL); err != nil {
+				slog.WarnContext(ctx, "Redis EXPIRE failed", "key", c.key, "error", err)
+			}
+		}
+	}
+}
+
+// finish stops renewing the claim, and reports whether it was still pending. The
+// renewal is waited for, so that it cannot shorten the expiration of the response.
+func (c *IdempotencyClaim) finish() bool {
+	if c.finished {
+		return false
+	}
+	c.finished = true
+	close(c.stop)
+	<-c.stopped
+	return true
+}
+
+// Store replaces the claim with the response to the request, kept for IdempotencyTTL.
+// The status of the response must not be 0.
+func (c *IdempotencyClaim) Store(ctx context.Context, response IdempotentResponse) {
+	if !c.finish() {
+		return
+	}
+	response.Fingerprint = c.fingerprint
+	if err := cache.SetCachedData(ctx, c.key, response, IdempotencyTTL); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", c.key, "error", err)
+	}
+}
+
+// Release frees the key, so that the request can be retried with it; it does nothing
+// once the response is stored.
+func (c *IdempotencyClaim) Release(ctx context.Context) {
+	if !c.finish() {
+		return
+	}
+	if err := cache.DeleteCachedData(ctx, c.key); err != nil {
+		slog.WarnContext(ctx, "Redis DEL failed", "key", c.key, "error", err)
+	}
+}
+
+// replay answers a request with the response stored for the same earlier request.
+func replay(c *gin.Context, stored *IdempotentResponse) {
+	defer c.Abort()
+	for name, values := range stored.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(stored.Status)
+	if _, err := c.Writer.Write(stored.Body); err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to replay response", "error", err)
+	}
+}
+
+// setHeaders returns the headers of after that are not in before with the same values.
+func setHeaders(before, after http.Header) http.Header {
+	header := http.Header{}
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			header[name] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+// requestFingerprint identifies a request by its method, URL and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder is a response writer that keeps a copy of the body written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-books-api/apperrors"
+	config "gin-books-api/configs"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// renderCode writes the code of an error with its status.
+func renderCode(c *gin.Context, err error) {
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		appErr = apperrors.Internal(err)
+	}
+	c.String(appErr.Kind.Status(), appErr.Code)
+}
+
+func TestIdempotencyRefusesLargeBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Idempotency(renderCode))
+	called := false
+	router.POST("/import", func(c *gin.Context) { called = true })
+
+	body := strings.Repeat("x", maxIdempotentBodySize+1)
+	request := httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(body))
+	request.Header.Set("Idempotency-Key", "import-1")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusRequestEntityTooLarge || response.Body.String() != "request_too_large" {
+		t.Errorf("response = %d %s, want 413 request_too_large", response.Code, response.Body)
+	}
+	if called {
+		t.Error("handler called for a body over the limit")
+	}
+}
+
+// useMiniredis points the cache at an in-process Redis for the duration of the test.
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	server := miniredis.RunT(t)
+	previous := config.RedisClient
+	config.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		config.RedisClient.Close()
+		config.RedisClient = previous
+	})
+	return server
+}
+
+func TestIdempotencyRenewsTheClaimOfSlowRequests(t *testing.T) {
+	server := useMiniredis(t)
+	previous := idempotencyRenewInterval
+	idempotencyRenewInterval = 10 * time.Millisecond
+	t.Cleanup(func() { idempotencyRenewInterval = previous })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Idempotency(renderCode))
+	calls := 0
+	router.POST("/books", func(c *gin.Context) {
+		calls++
+		// Outlast the claim twice over, giving it time to be renewed in between
+		for range 2 {
+			server.FastForward(idempotencyLockTTL * 3 / 4)
+			time.Sleep(50 * time.Millisecond)
+		}
+		if len(server.Keys()) != 1 {
+			t.Error("claim expired while the request was processed")
+		}
+		c.String(http.StatusCreated, "created")
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title":"Dune"}`))
+		request.Header.Set("Idempotency-Key", "book-1")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	if response := send(); response.Code != http.StatusCreated {
+		t.Fatalf("first response = %d %s, want 201", response.Code, response.Body)
+	}
+	keys := server.Keys()
+	if len(keys) != 1 || server.TTL(keys[0]) != IdempotencyTTL {
+		t.Fatalf("stored keys = %v, want one expiring in %s", keys, IdempotencyTTL)
+	}
+
+	response := send()
+	if response.Code != http.StatusCreated || response.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Errorf("retry = %d replayed=%q after %d calls, want the replayed 201 after one call",
+			response.Code, response.Header().Get("Idempotent-Replayed"), calls)
+	}
+}
+
+func TestIdempotencyReleasesTheKeyOfFailedRequests(t *testing.T) {
+	server := useMiniredis(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.Use(Idempotency(renderCode))
+	router.POST("/books", func(c *gin.Context) { panic("failed") })
+
+	request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{}`))
+	request.Header.Set("Idempotency-Key", "book-1")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("keys after a panic = %v, want the claim released", keys)
+	}
+}
+
+func TestIdempotencyScopesKeysByUser(t *testing.T) {
+	useMiniredis(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Header("X-Request-ID", c.GetHeader("X-Request-ID"))
+		c.Next()
+	})
+	router.Use(Idempotency(renderCode))
+	calls := 0
+	router.POST("/reviews", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/reviews/"+strconv.Itoa(calls))
+		c.String(http.StatusCreated, "review %d", calls)
+	})
+
+	send := func(remoteAddr, userID, requestID string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/reviews", strings.NewReader(`{"rating":5}`))
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("Idempotency-Key", "review-1")
+		request.Header.Set("X-User-ID", userID)
+		request.Header.Set("X-Request-ID", requestID)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	send("192.0.2.1:1234", "7", "first")
+
+	// A retry from another network gets the first response, with its own request ID
+	response := send("198.51.100.4:1234", "7", "retry")
+	if response.Body.String() != "review 1" || response.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry from another address = %q, want the first review replayed", response.Body)
+	}
+	if got := response.Header().Get("X-Request-ID"); got != "retry" {
+		t.Errorf("X-Request-ID of the replay = %q, want that of the retry", got)
+	}
+	if got := response.Header().Get("Location"); got != "/reviews/1" {
+		t.Errorf("Location of the replay = %q, want that of the first response", got)
+	}
+
+	// The same key sent by another user is another request
+	if response := send("192.0.2.1:1234", "8", "other"); response.Body.String() != "review 2" {
+		t.Errorf("request of another user = %q, want a new review", response.Body)
+	}
+}
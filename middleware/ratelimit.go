@@ -0,0 +1,406 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gin-books-api/apperrors"
+	config "gin-books-api/configs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// redisRetryInterval is how long the rate limiter counts requests in process after
+// Redis failed, before trying Redis again.
+const redisRetryInterval = 10 * time.Second
+
+// Rate is a number of requests allowed in a sliding window of time.
+type Rate struct {
+	Requests int
+	Window   time.Duration
+}
+
+// RateLimitPolicy holds the rates of a group of routes for each role of client:
+// anonymous clients, and users identified by the X-User-ID header. Since any client
+// may send X-User-ID, an ID does not raise a client above the Anonymous rate: the
+// requests of users count against the Anonymous rate of their IP address too, and the
+// User rate shares out that rate among the users of one address.
+type RateLimitPolicy struct {
+	Anonymous Rate
+	User      Rate
+}
+
+// RateLimits configures the rate limiter: the policies of groups of routes, keyed by
+// the first segment of their path after the version, e.g. "books" for /api/v1/books/:id,
+// and the policy of the other routes.
+type RateLimits struct {
+	Default RateLimitPolicy
+	Groups  map[string]RateLimitPolicy
+}
+
+// policy returns the group and policy of a route, such as /api/v1/books/:id.
+func (l RateLimits) policy(route string) (string, RateLimitPolicy) {
+	path := strings.TrimPrefix(route, "/")
+	if strings.HasPrefix(path, "api/") {
+		_, path, _ = strings.Cut(strings.TrimPrefix(path, "api/"), "/")
+	}
+	group, _, _ := strings.Cut(path, "/")
+	if policy, ok := l.Groups[group]; ok {
+		return group, policy
+	}
+	return "default", l.Default
+}
+
+// rateLimitEnvPrefix prefixes the variables that set rates, such as
+// RATE_LIMIT_BOOKS_ANONYMOUS.
+const rateLimitEnvPrefix = "RATE_LIMIT_"
+
+// WithEnv returns a copy of l with the rates set by the variables of environ, of the
+// form RATE_LIMIT_<GROUP>_<ROLE>=<requests>/<window>, such as
+// RATE_LIMIT_BOOKS_ANONYMOUS=60/1m or RATE_LIMIT_DEFAULT_USER=30/1m. ROLE is ANONYMOUS
+// or USER; the group DEFAULT sets the policy of the other routes, and a group without a
+// policy in l starts from that policy. Invalid variables are ignored and reported in
+// the error.
+func (l RateLimits) WithEnv(environ []string) (RateLimits, error) {
+	limits := RateLimits{Default: l.Default, Groups: make(map[string]RateLimitPolicy, len(l.Groups))}
+	for group, policy := range l.Groups {
+		limits.Groups[group] = policy
+	}
+
+	// The default policy is set first, since groups without a policy of their own
+	// start from it
+	var errs []error
+	for _, defaults := range []bool{true, false} {
+		for _, variable := range environ {
+			name, value, _ := strings.Cut(variable, "=")
+			setting, ok := strings.CutPrefix(name, rateLimitEnvPrefix)
+			if !ok {
+				continue
+			}
+			cut := strings.LastIndex(setting, "_")
+			if cut <= 0 {
+				if defaults {
+					errs = append(errs, fmt.Errorf("%s: want %s<GROUP>_<ROLE>", name, rateLimitEnvPrefix))
+				}
+				continue
+			}
+			group, role := strings.ToLower(setting[:cut]), setting[cut+1:]
+			if (group == "default") != defaults {
+				continue
+			}
+			rate, err := ParseRate(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+
+			policy, known := limits.Groups[group]
+			if defaults || !known {
+				policy = limits.Default
+			}
+			switch role {
+			case "ANONYMOUS":
+				policy.Anonymous = rate
+			case "USER":
+				policy.User = rate
+			default:
+				errs = append(errs, fmt.Errorf("%s: role %q is neither ANONYMOUS nor USER", name, role))
+				continue
+			}
+			if defaults {
+				limits.Default = policy
+			} else {
+				limits.Groups[group] = policy
+			}
+		}
+	}
+	return limits, errors.Join(errs...)
+}
+
+// ParseRate parses a rate such as "60/1m", of a number of requests per window, or "0"
+// for no limit.
+func ParseRate(value string) (Rate, error) {
+	if strings.TrimSpace(value) == "0" {
+		return Rate{}, nil
+	}
+	requests, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q is not of the form <requests>/<window>, such as 60/1m", value)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid number of requests %q", requests)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid window %q", window)
+	}
+	return Rate{Requests: n, Window: d}, nil
+}
+
+// RateLimiter limits the requests of each client to the rate of its role in the policy
+// of the route group. All the requests of an IP address are counted together; those of
+// users are also counted by X-User-ID within their address.
+//
+// Requests are counted in Redis, so that the limits hold across instances of the API,
+// and across the REST API and the gRPC server of an instance, which share a limiter.
+// While Redis is unreachable, each limiter counts its requests in process.
+type RateLimiter struct {
+	limits  RateLimits
+	counter *rateLimiter
+}
+
+// NewRateLimiter returns a limiter of the rates of limits.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{limits: limits, counter: &rateLimiter{memory: newMemoryCounter()}}
+}
+
+// RateDecision is the decision on a request, against the tightest of the limits of its
+// client: the limit that refused it, or else the one with the fewest requests left.
+type RateDecision struct {
+	Rate       Rate
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // Until the current window ends
+	RetryAfter time.Duration // Until a refused request would be allowed
+}
+
+// Take counts a request of client to route, such as /api/v1/books/:id or
+// /api/v1/books/{id}. limited is false when no rate applies to the request.
+func (l *RateLimiter) Take(ctx context.Context, route string, client Client) (decision RateDecision, limited bool) {
+	group, policy := l.limits.policy(route)
+	rate, result, limited := l.counter.decide(ctx, group, policy, client, time.Now())
+	return RateDecision{
+		Rate:       rate,
+		Allowed:    result.allowed,
+		Remaining:  result.remaining,
+		Reset:      result.reset,
+		RetryAfter: result.retryAfter,
+	}, limited
+}
+
+// Headers returns the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers (draft-ietf-httpapi-ratelimit-headers) of the decision, and
+// Retry-After if the request was refused.
+func (d RateDecision) Headers() http.Header {
+	header := http.Header{}
+	header.Set("RateLimit-Limit", strconv.Itoa(d.Rate.Requests))
+	header.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+	header.Set("RateLimit-Policy", strconv.Itoa(d.Rate.Requests)+";w="+strconv.Itoa(seconds(d.Rate.Window)))
+	if !d.Allowed {
+		header.Set("Retry-After", strconv.Itoa(seconds(d.RetryAfter)))
+	}
+	return header
+}
+
+// Err returns the error refusing the request, or nil if it was allowed.
+func (d RateDecision) Err() error {
+	if d.Allowed {
+		return nil
+	}
+	return apperrors.New(apperrors.KindTooManyRequests, "rate_limited",
+		"Too many requests; retry after "+strconv.Itoa(seconds(d.RetryAfter))+" seconds")
+}
+
+// RateLimit limits the request rate of each client with limiter. Every response
+// carries the headers of the decision; requests over a limit are refused with 429 and
+// Retry-After. renderError writes the error responses.
+func RateLimit(limiter *RateLimiter, renderError func(*gin.Context, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision, limited := limiter.Take(c.Request.Context(), c.FullPath(), ClientOf(c))
+		if !limited {
+			c.Next()
+			return
+		}
+
+		for name, values := range decision.Headers() {
+			c.Header(name, values[0])
+		}
+		if err := decision.Err(); err != nil {
+			renderError(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimiter counts requests with a sliding window counter: the requests of the
+// current fixed window, plus those of the previous window weighted by the part of it
+// that the sliding window still covers.
+type rateLimiter struct {
+	memory *memoryCounter
+
+	// Unix nanoseconds until which Redis is not tried, after it failed
+	redisDownUntil atomic.Int64
+}
+
+// rateBucket is a count of requests, such as those of a user, and the rate it is
+// limited to.
+type rateBucket struct {
+	key  string
+	rate Rate
+}
+
+// rateResult is the decision on a request.
+type rateResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // Until the current window ends
+	retryAfter time.Duration // Until a refused request would be allowed
+}
+
+// takeScript counts a request in the window KEYS[1], unless the requests of the
+// sliding window, with those of the previous window KEYS[2] weighted by ARGV[1], would
+// exceed the limit ARGV[2]. Windows expire after ARGV[3] milliseconds. It returns
+// whether the request was counted, and the counts of both windows.
+var takeScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+if previous * tonumber(ARGV[1]) + current + 1 > tonumber(ARGV[2]) then
+	return {0, current, previous}
+end
+current = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, current, previous}
+`)
+
+// decide counts a request of client to group against the rates of policy that apply to
+// it, and returns the tightest of them with its result: the rate that refused the
+// request, or else the one with the fewest requests remaining. limited is false when
+// no rate applies.
+func (l *rateLimiter) decide(ctx context.Context, group string, policy RateLimitPolicy, client Client, now time.Time) (rate Rate, result rateResult, limited bool) {
+	// X-User-ID is not authenticated, so the address bucket counts users as well. The
+	// user bucket comes first, so that a request it refuses does not use up the rate
+	// of the other clients of the address.
+	buckets := []rateBucket{{Client{IP: client.IP}.Key(), policy.Anonymous}}
+	if client.UserID > 0 {
+		buckets = []rateBucket{{client.Key(), policy.User}, buckets[0]}
+	}
+
+	for _, bucket := range buckets {
+		if bucket.rate.Requests <= 0 || bucket.rate.Window <= 0 {
+			continue
+		}
+		taken := l.take(ctx, group+"_"+bucket.key, bucket.rate, now)
+		if !limited || !taken.allowed || taken.remaining < result.remaining {
+			rate, result, limited = bucket.rate, taken, true
+		}
+		if !taken.allowed {
+			break
+		}
+	}
+	return rate, result, limited
+}
+
+// take counts a request of client at now against rate.
+func (l *rateLimiter) take(ctx context.Context, client string, rate Rate, now time.Time) rateResult {
+	index := now.UnixNano() / int64(rate.Window)
+	elapsed := time.Duration(now.UnixNano() % int64(rate.Window))
+	weight := 1 - float64(elapsed)/float64(rate.Window)
+
+	if config.RedisClient != nil && now.UnixNano() >= l.redisDownUntil.Load() {
+		// The braces keep both windows of a client in one slot of a Redis cluster
+		prefix := "ratelimit_{" + client + "_" + rate.Window.String() + "}_"
+		keys := []string{prefix + strconv.FormatInt(index, 10), prefix + strconv.FormatInt(index-1, 10)}
+		counts, err := takeScript.Run(ctx, config.RedisClient, keys,
+			strconv.FormatFloat(weight, 'f', 6, 64), rate.Requests, (2 * rate.Window).Milliseconds()).Int64Slice()
+		if err == nil && len(counts) == 3 {
+			return rate.result(counts[0] == 1, int(counts[1]), int(counts[2]), elapsed)
+		}
+		// A request cancelled by its client says nothing of Redis
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "Rate limiter falling back to in-process counts", "error", err)
+			l.redisDownUntil.Store(now.Add(redisRetryInterval).UnixNano())
+		}
+	}
+
+	allowed, current, previous := l.memory.take(client, rate, index, weight, now)
+	return rate.result(allowed, current, previous, elapsed)
+}
+
+// result returns the decision on a request given the counts of the current window,
+// which include the request if it was allowed, and of the previous window.
+func (r Rate) result(allowed bool, current, previous int, elapsed time.Duration) rateResult {
+	weight := 1 - float64(elapsed)/float64(r.Window)
+	used := float64(previous)*weight + float64(current)
+	result := rateResult{
+		allowed:   allowed,
+		remaining: max(int(math.Floor(float64(r.Requests)-used)), 0),
+		reset:     r.Window - elapsed,
+	}
+	if allowed {
+		return result
+	}
+
+	// The requests of the previous window slide out of the window as it moves on; once
+	// the current window is full, those of the current window must slide out as well
+	free := float64(r.Requests - 1 - current)
+	if free >= 0 && previous > 0 {
+		result.retryAfter = time.Duration(float64(r.Window)*(1-free/float64(previous))) - elapsed
+	} else {
+		next := time.Duration(float64(r.Window) * (1 - float64(r.Requests-1)/float64(max(current, 1))))
+		result.retryAfter = r.Window - elapsed + max(next, 0)
+	}
+	result.retryAfter = max(result.retryAfter, time.Second)
+	return result
+}
+
+// memoryCounter counts the requests of each window in process.
+type memoryCounter struct {
+	mu        sync.Mutex
+	counts    map[windowKey]int
+	lastPrune time.Time
+}
+
+// windowKey identifies a fixed window of a client.
+type windowKey struct {
+	client string
+	window time.Duration
+	index  int64
+}
+
+func newMemoryCounter() *memoryCounter {
+	return &memoryCounter{counts: make(map[windowKey]int)}
+}
+
+// take does in process what takeScript does in Redis.
+func (m *memoryCounter) take(client string, rate Rate, index int64, weight float64, now time.Time) (bool, int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Windows older than the previous one are no longer read
+	if now.Sub(m.lastPrune) > time.Minute {
+		for key := range m.counts {
+			if time.Duration(key.index+2)*key.window < time.Duration(now.UnixNano()) {
+				delete(m.counts, key)
+			}
+		}
+		m.lastPrune = now
+	}
+
+	key := windowKey{client: client, window: rate.Window, index: index}
+	current := m.counts[key]
+	previous := m.counts[windowKey{client: client, window: rate.Window, index: index - 1}]
+	if float64(previous)*weight+float64(current+1) > float64(rate.Requests) {
+		return false, current, previous
+	}
+	m.counts[key] = current + 1
+	return true, current + 1, previous
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterCountsUsersByAddress(t *testing.T) {
+	policy := RateLimitPolicy{
+		Anonymous: Rate{Requests: 3, Window: time.Minute},
+		User:      Rate{Requests: 2, Window: time.Minute},
+	}
+	limiter := &rateLimiter{memory: newMemoryCounter()}
+	now := time.Unix(0, 0)
+	take := func(client Client) rateResult {
+		_, result, limited := limiter.decide(context.Background(), "books", policy, client, now)
+		if !limited {
+			t.Fatalf("decide(%+v) applied no rate", client)
+		}
+		return result
+	}
+
+	// Each user of an address has a share of the rate of the address
+	for range 2 {
+		if !take(Client{IP: "192.0.2.1", UserID: 7}).allowed {
+			t.Fatal("request of user 7 refused within its rate")
+		}
+	}
+	if take(Client{IP: "192.0.2.1", UserID: 7}).allowed {
+		t.Fatal("request of user 7 allowed over its rate")
+	}
+
+	// Sending the same ID from another address does not use up that user's bucket
+	if !take(Client{IP: "198.51.100.1", UserID: 7}).allowed {
+		t.Fatal("request of user 7 from another address refused")
+	}
+
+	// Rotating IDs, or sending none, does not get past the anonymous rate of the address
+	if !take(Client{IP: "192.0.2.1", UserID: 8}).allowed {
+		t.Fatal("request of user 8 refused within the rate of the address")
+	}
+	if take(Client{IP: "192.0.2.1", UserID: 9}).allowed {
+		t.Fatal("request of user 9 allowed over the rate of the address")
+	}
+	if take(Client{IP: "192.0.2.1"}).allowed {
+		t.Fatal("anonymous request allowed over the rate of the address")
+	}
+}
+
+func TestRateLimitsWithEnv(t *testing.T) {
+	defaults := RateLimits{
+		Default: RateLimitPolicy{Anonymous: Rate{Requests: 120, Window: time.Minute}, User: Rate{Requests: 60, Window: time.Minute}},
+		Groups: map[string]RateLimitPolicy{
+			"books": {Anonymous: Rate{Requests: 60, Window: time.Minute}, User: Rate{Requests: 30, Window: time.Minute}},
+		},
+	}
+	limits, err := defaults.WithEnv([]string{
+		"PATH=/usr/bin",
+		"RATE_LIMIT_BOOKS_ANONYMOUS=100/30s",
+		"RATE_LIMIT_BOOK_REVIEWS_USER=5/1h",
+		"RATE_LIMIT_DEFAULT_ANONYMOUS=200/1m",
+		"RATE_LIMIT_EXPORT_USER=0",
+		"RATE_LIMIT_BOOKS_USER=many",
+		"RATE_LIMIT_BOOKS_ADMIN=1/1m",
+	})
+	if err == nil {
+		t.Error("invalid variables not reported")
+	}
+
+	want := map[string]RateLimitPolicy{
+		"books":        {Anonymous: Rate{Requests: 100, Window: 30 * time.Second}, User: Rate{Requests: 30, Window: time.Minute}},
+		"book_reviews": {Anonymous: Rate{Requests: 200, Window: time.Minute}, User: Rate{Requests: 5, Window: time.Hour}},
+		"export":       {Anonymous: Rate{Requests: 200, Window: time.Minute}},
+	}
+	for group, policy := range want {
+		if limits.Groups[group] != policy {
+			t.Errorf("policy of %s = %+v, want %+v", group, limits.Groups[group], policy)
+		}
+	}
+	if limits.Default.Anonymous.Requests != 200 {
+		t.Errorf("default anonymous rate = %+v, want 200 per minute", limits.Default.Anonymous)
+	}
+	if defaults.Groups["books"].Anonymous.Requests != 60 {
+		t.Error("WithEnv changed the limits it was called on")
+	}
+}
@@ -0,0 +1,70 @@
+// Package middleware holds the gin middleware shared by every route.
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"gin-books-api/logging"
+	"gin-books-api/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDKey is the gin context key holding the trace ID of the request.
+const TraceIDKey = "trace_id"
+
+// TraceID traces every request as a span, continuing the trace of a traceparent
+// header. The trace ID is returned in the X-Trace-ID header and in error responses, and
+// logged with every record of the request, so that a failure reported by a client can
+// be found in the logs and traces. Without a tracer provider, the trace ID is random.
+func TraceID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		// Requests matching no route are named after their method alone, so that
+		// arbitrary paths do not become span names
+		name := c.Request.Method
+		if route := c.FullPath(); route != "" {
+			name += " " + route
+		}
+		ctx, span := tracing.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.HTTPRoute(c.FullPath()),
+			semconv.URLPath(c.Request.URL.Path),
+			semconv.ClientAddress(c.ClientIP()),
+			semconv.UserAgentOriginal(c.Request.UserAgent()),
+		))
+		defer span.End()
+
+		var traceID string
+		if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+			traceID = spanContext.TraceID().String()
+		} else {
+			id := make([]byte, 16)
+			if _, err := rand.Read(id); err == nil {
+				traceID = hex.EncodeToString(id)
+				ctx = logging.With(ctx, slog.String("trace_id", traceID))
+			}
+		}
+		if traceID != "" {
+			c.Set(TraceIDKey, traceID)
+			c.Header("X-Trace-ID", traceID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
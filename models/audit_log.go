@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// AuditLog records one create, update or delete of an entity.
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ActorID   *uint     `json:"actor_id" gorm:"index"`                              // User who made the change, NULL when anonymous
+	Action    string    `json:"action" gorm:"not null"`                             // create, update or delete
+	Entity    string    `json:"entity" gorm:"not null;index:idx_audit_logs_entity"` // e.g. book, author
+	EntityID  uint      `json:"entity_id" gorm:"not null;index:idx_audit_logs_entity"`
+	Diff      JSON      `json:"diff" gorm:"type:jsonb"` // Changed fields as {"field": {"before": ..., "after": ...}}
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
@@ -1,5 +1,12 @@
 package models
 
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Book struct {
 	ID            uint   `json:"id" gorm:"primaryKey"`
 	Title         string `json:"title"`
@@ -8,10 +15,33 @@ type Book struct {
 	AuthorID      *uint  `json:"author_id"`                        // Use pointer to allow NULL values
 	PublisherID   *uint  `json:"publisher_id"`                     // Use pointer to allow NULL values
 	CategoryID    *uint  `json:"category_id"`                      // Use pointer to allow NULL values
+	WorkID        *uint  `json:"work_id"`                          // Work this book is an edition of
+	ISBN          string `json:"isbn" gorm:"index"`                // ISBN of this edition
+	Language      string `json:"language"`                         // Language of this edition, e.g. "en"
+	Format        string `json:"format"`                           // e.g. hardcover, paperback, ebook, audiobook
 	Availability  bool   `json:"availability" gorm:"default:true"` // Indicates if the book is available for borrowing
 
-	Author    Author    `json:"-" gorm:"foreignKey:AuthorID"`    // Relation to Author
-	Publisher Publisher `json:"-" gorm:"foreignKey:PublisherID"` // Relation to Publisher
-	Category  Category  `json:"-" gorm:"foreignKey:CategoryID"`  // Relation to Category
-	Reviews   []Review  `json:"-" gorm:"foreignKey:BookID"`      // Relation to Reviews
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:CURRENT_TIMESTAMP;index"` // When the book was added to the catalogue
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:CURRENT_TIMESTAMP"`       // When the book was last changed
+
+	Author    Author    `json:"-" gorm:"foreignKey:AuthorID"`            // Relation to Author
+	Publisher Publisher `json:"-" gorm:"foreignKey:PublisherID"`         // Relation to Publisher
+	Category  Category  `json:"-" gorm:"foreignKey:CategoryID"`          // Relation to Category
+	Work      Work      `json:"-" gorm:"foreignKey:WorkID"`              // Relation to Work
+	Reviews   []Review  `json:"-" gorm:"foreignKey:BookID"`              // Relation to Reviews
+	Tags      []Tag     `json:"tags" gorm:"many2many:book_tags"`         // Free-form tags
+	Subjects  []Subject `json:"subjects" gorm:"many2many:book_subjects"` // Controlled subject headings
+}
+
+// BeforeSave stores the ISBN in its normalized form, so that a book is found by ISBN
+// whichever way it was written.
+func (b *Book) BeforeSave(tx *gorm.DB) error {
+	b.ISBN = NormalizeISBN(b.ISBN)
+	return nil
+}
+
+// NormalizeISBN strips the hyphens and spaces of an ISBN and upper-cases its check
+// digit, e.g. "0-306-40615-x" becomes "030640615X".
+func NormalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
 }
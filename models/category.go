@@ -1,8 +1,10 @@
 package models
 
 type Category struct {
-	ID   uint   `json:"id" gorm:"primaryKey"`
-	Name string `json:"name"`
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name"`
+	ParentID *uint  `json:"parent_id"` // Use pointer to allow NULL values for top-level categories
 
-	Books []Book `json:"books" gorm:"foreignKey:CategoryID"`
+	Children []Category `json:"children" gorm:"foreignKey:ParentID"` // Relation to subcategories
+	Books    []Book     `json:"books" gorm:"foreignKey:CategoryID"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SchemaVersion is the version of the schema the models describe. Bump it with every
+// change to the models, so that instances still running on an older schema are not
+// reported ready.
+const SchemaVersion = 1
+
+// SchemaMigration records that the schema was migrated to a version.
+type SchemaMigration struct {
+	Version    int       `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	MigratedAt time.Time `json:"migrated_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Series is an ordered collection of books, e.g. a trilogy.
+type Series struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	Entries []SeriesEntry `json:"entries" gorm:"foreignKey:SeriesID"` // Relation to ordered members
+}
+
+// SeriesEntry places a book in a series. Positions may be fractional (2.5 for a novella
+// set between the second and third books), and no two entries of a series share one.
+type SeriesEntry struct {
+	ID       uint    `json:"id" gorm:"primaryKey"`
+	SeriesID uint    `json:"series_id" gorm:"not null;uniqueIndex:idx_series_entries_series_book;uniqueIndex:idx_series_entries_series_position"`
+	BookID   uint    `json:"book_id" gorm:"not null;uniqueIndex:idx_series_entries_series_book"`
+	Position float64 `json:"position" gorm:"not null;uniqueIndex:idx_series_entries_series_position"`
+
+	Book Book `json:"book" gorm:"foreignKey:BookID"`
+}
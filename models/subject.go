@@ -0,0 +1,13 @@
+package models
+
+// Subject is a heading in the controlled subject vocabulary. Subjects form a tree,
+// like a trimmed LCSH or Dewey hierarchy.
+type Subject struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null"`
+	Code     string `json:"code"`      // Classification code, e.g. a Dewey number
+	ParentID *uint  `json:"parent_id"` // Use pointer to allow NULL values for top-level subjects
+
+	Children []Subject `json:"children,omitempty" gorm:"foreignKey:ParentID"` // Relation to narrower subjects
+	Books    []Book    `json:"-" gorm:"many2many:book_subjects"`
+}
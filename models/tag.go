@@ -0,0 +1,9 @@
+package models
+
+// Tag is a free-form, user-defined label attached to books.
+type Tag struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"uniqueIndex;not null"`
+
+	Books []Book `json:"-" gorm:"many2many:book_tags"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// Work groups the editions (hardcovers, paperbacks, translations, ...) of the same book.
+type Work struct {
+	ID               uint   `json:"id" gorm:"primaryKey"`
+	Title            string `json:"title"`
+	Description      string `json:"description"`
+	OriginalLanguage string `json:"original_language"`
+
+	Editions []Book `json:"editions" gorm:"foreignKey:WorkID"` // Relation to editions
+}
@@ -0,0 +1,206 @@
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName    xml.Name    `xml:"feed"`
+	Xmlns      string      `xml:"xmlns,attr"`
+	XmlnsDC    string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS  string      `xml:"xmlns:opds,attr"`
+	XmlnsOS    string      `xml:"xmlns:opensearch,attr"`
+	XmlnsThr   string      `xml:"xmlns:thr,attr"`
+	ID         string      `xml:"id"`
+	Title      string      `xml:"title"`
+	Updated    string      `xml:"updated"`
+	Author     atomPerson  `xml:"author"`
+	Links      []atomLink  `xml:"link"`
+	Total      *int        `xml:"opensearch:totalResults,omitempty"`
+	PerPage    *int        `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex *int        `xml:"opensearch:startIndex,omitempty"`
+	Entries    []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel   string `xml:"rel,attr"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	Count string `xml:"thr:count,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Language   string         `xml:"dc:language,omitempty"`
+	Issued     string         `xml:"dc:issued,omitempty"`
+	Publisher  string         `xml:"dc:publisher,omitempty"`
+	Identifier string         `xml:"dc:identifier,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Links      []atomLink     `xml:"link"`
+}
+
+// feedType returns the Atom media type of a feed of the given kind.
+func feedType(kind string) string {
+	if kind == Acquisition {
+		return TypeAcquisition
+	}
+	return TypeNavigation
+}
+
+// WriteAtom writes feed as an OPDS 1.2 Atom document.
+func WriteAtom(w io.Writer, feed Feed) error {
+	updated := feed.Updated.UTC().Format(time.RFC3339)
+	doc := atomFeed{
+		Xmlns:     namespaceAtom,
+		XmlnsDC:   namespaceDC,
+		XmlnsOPDS: namespaceOPDS,
+		XmlnsOS:   namespaceSearch,
+		XmlnsThr:  "http://purl.org/syndication/thread/1.0",
+		ID:        feed.ID,
+		Title:     feed.Title,
+		Updated:   updated,
+		Author:    atomPerson{Name: "Gin Books API", URI: feed.Start},
+	}
+
+	self := feedType(feed.Kind)
+	doc.Links = append(doc.Links,
+		atomLink{Rel: "self", Href: feed.Self, Type: self},
+		atomLink{Rel: "start", Href: feed.Start, Type: TypeNavigation})
+	if feed.Up != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "up", Href: feed.Up, Type: TypeNavigation})
+	}
+	if feed.Search != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "search", Href: feed.Search, Type: TypeOpenSearch})
+	}
+	links := pageLinks(feed.Paging)
+	for _, rel := range pageRels {
+		if href, ok := links[rel]; ok {
+			doc.Links = append(doc.Links, atomLink{Rel: rel, Href: href, Type: self})
+		}
+	}
+	if feed.Paging != nil {
+		startIndex := (feed.Paging.Page-1)*feed.Paging.PageSize + 1
+		doc.Total, doc.PerPage, doc.StartIndex = &feed.Paging.Total, &feed.Paging.PageSize, &startIndex
+	}
+
+	for _, item := range feed.Navigation {
+		rel := item.Rel
+		if rel == "" {
+			rel = "subsection"
+		}
+		link := atomLink{Rel: rel, Href: item.Href, Type: feedType(item.Kind)}
+		if item.Count > 0 {
+			link.Count = strconv.Itoa(item.Count)
+		}
+		entry := atomEntry{Title: item.Title, ID: item.ID, Updated: updated, Links: []atomLink{link}}
+		if item.Summary != "" {
+			entry.Content = &atomText{Type: "text", Text: item.Summary}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	for _, publication := range feed.Publications {
+		entry := atomEntry{
+			Title:     publication.Title,
+			ID:        publication.ID,
+			Updated:   updated,
+			Language:  publication.Language,
+			Publisher: publication.Publisher,
+			Links: []atomLink{
+				{Rel: RelAcquisition, Href: publication.Href, Type: TypePublication},
+				{Rel: "alternate", Href: publication.Href, Type: TypePublication},
+			},
+		}
+		if !publication.Updated.IsZero() {
+			entry.Updated = publication.Updated.UTC().Format(time.RFC3339)
+		}
+		for _, author := range publication.Authors {
+			entry.Authors = append(entry.Authors, atomPerson{Name: author.Name, URI: author.Href})
+		}
+		if publication.Year > 0 {
+			entry.Issued = strconv.Itoa(publication.Year)
+		}
+		if publication.ISBN != "" {
+			entry.Identifier = "urn:isbn:" + publication.ISBN
+		}
+		for _, subject := range publication.Subjects {
+			entry.Categories = append(entry.Categories, atomCategory{Term: subject, Label: subject})
+		}
+		if publication.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Text: publication.Summary}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// WriteOpenSearchDescription writes the OpenSearch description of a catalogue whose
+// search results are at searchHref, with "{searchTerms}" in place of the query.
+func WriteOpenSearchDescription(w io.Writer, searchHref string) error {
+	doc := struct {
+		XMLName     xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+		ShortName   string          `xml:"ShortName"`
+		Description string          `xml:"Description"`
+		InputEnc    string          `xml:"InputEncoding"`
+		OutputEnc   string          `xml:"OutputEncoding"`
+		URLs        []openSearchURL `xml:"Url"`
+	}{
+		ShortName:   "Gin Books",
+		Description: "Search the catalogue by title",
+		InputEnc:    "UTF-8",
+		OutputEnc:   "UTF-8",
+	}
+	for _, mediaType := range []string{TypeAcquisition, typeSearchResults, TypeJSON} {
+		doc.URLs = append(doc.URLs, openSearchURL{Type: mediaType, Template: searchHref})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
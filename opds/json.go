@@ -0,0 +1,142 @@
+package opds
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified"`
+	NumberOfItems *int   `json:"numberOfItems,omitempty"`
+	ItemsPerPage  *int   `json:"itemsPerPage,omitempty"`
+	CurrentPage   *int   `json:"currentPage,omitempty"`
+}
+
+type jsonLink struct {
+	Rel        string          `json:"rel,omitempty"`
+	Href       string          `json:"href"`
+	Type       string          `json:"type,omitempty"`
+	Title      string          `json:"title,omitempty"`
+	Properties *jsonProperties `json:"properties,omitempty"`
+}
+
+type jsonProperties struct {
+	NumberOfItems int `json:"numberOfItems"`
+}
+
+type jsonPublication struct {
+	Metadata jsonPublicationMetadata `json:"metadata"`
+	Links    []jsonLink              `json:"links"`
+}
+
+type jsonPublicationMetadata struct {
+	Type        string            `json:"@type"`
+	Identifier  string            `json:"identifier"`
+	Title       string            `json:"title"`
+	Author      []jsonContributor `json:"author,omitempty"`
+	Publisher   string            `json:"publisher,omitempty"`
+	Language    string            `json:"language,omitempty"`
+	Published   string            `json:"published,omitempty"`
+	Modified    string            `json:"modified,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Subject     []string          `json:"subject,omitempty"`
+}
+
+type jsonContributor struct {
+	Name  string     `json:"name"`
+	Links []jsonLink `json:"links,omitempty"`
+}
+
+// WriteJSON writes feed as an OPDS 2.0 document.
+func WriteJSON(w io.Writer, feed Feed) error {
+	doc := jsonFeed{
+		Metadata: jsonFeedMetadata{Title: feed.Title, Modified: feed.Updated.UTC().Format(time.RFC3339)},
+		Links: []jsonLink{
+			{Rel: "self", Href: feed.Self, Type: TypeJSON},
+			{Rel: "start", Href: feed.Start, Type: TypeJSON},
+		},
+	}
+	if feed.Up != "" {
+		doc.Links = append(doc.Links, jsonLink{Rel: "up", Href: feed.Up, Type: TypeJSON})
+	}
+	if feed.Search != "" {
+		doc.Links = append(doc.Links, jsonLink{Rel: "search", Href: feed.Search, Type: TypeOpenSearch})
+	}
+	links := pageLinks(feed.Paging)
+	for _, rel := range pageRels {
+		if href, ok := links[rel]; ok {
+			doc.Links = append(doc.Links, jsonLink{Rel: rel, Href: href, Type: TypeJSON})
+		}
+	}
+	if feed.Paging != nil {
+		doc.Metadata.NumberOfItems = &feed.Paging.Total
+		doc.Metadata.ItemsPerPage = &feed.Paging.PageSize
+		doc.Metadata.CurrentPage = &feed.Paging.Page
+	}
+
+	for _, item := range feed.Navigation {
+		link := jsonLink{Rel: item.Rel, Href: item.Href, Type: TypeJSON, Title: item.Title}
+		if item.Count > 0 {
+			link.Properties = &jsonProperties{NumberOfItems: item.Count}
+		}
+		doc.Navigation = append(doc.Navigation, link)
+	}
+
+	for _, publication := range feed.Publications {
+		metadata := jsonPublicationMetadata{
+			Type:        "http://schema.org/Book",
+			Identifier:  publication.ID,
+			Title:       publication.Title,
+			Publisher:   publication.Publisher,
+			Language:    publication.Language,
+			Description: publication.Summary,
+			Subject:     publication.Subjects,
+		}
+		if publication.ISBN != "" {
+			metadata.Identifier = "urn:isbn:" + publication.ISBN
+		}
+		if publication.Year > 0 {
+			metadata.Published = strconv.Itoa(publication.Year)
+		}
+		if !publication.Updated.IsZero() {
+			metadata.Modified = publication.Updated.UTC().Format(time.RFC3339)
+		}
+		for _, author := range publication.Authors {
+			contributor := jsonContributor{Name: author.Name}
+			if author.Href != "" {
+				contributor.Links = []jsonLink{{Href: author.Href, Type: TypeJSON}}
+			}
+			metadata.Author = append(metadata.Author, contributor)
+		}
+		doc.Publications = append(doc.Publications, jsonPublication{
+			Metadata: metadata,
+			Links: []jsonLink{
+				{Rel: "self", Href: publication.Href, Type: TypePublication},
+				{Rel: RelAcquisition, Href: publication.Href, Type: TypePublication},
+			},
+		})
+	}
+
+	// An acquisition feed lists its publications even when there are none
+	if feed.Kind == Acquisition && doc.Publications == nil {
+		doc.Publications = []jsonPublication{}
+	}
+	if feed.Kind == Navigation && doc.Navigation == nil {
+		doc.Navigation = []jsonLink{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
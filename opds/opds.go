@@ -0,0 +1,116 @@
+// Package opds renders catalogue feeds for e-reader apps, as Atom (OPDS 1.2) or JSON
+// (OPDS 2.0), from one description of the feed.
+package opds
+
+import (
+	"time"
+)
+
+// Media types of OPDS documents.
+const (
+	TypeAtom          = "application/atom+xml;profile=opds-catalog"
+	TypeNavigation    = TypeAtom + ";kind=navigation"
+	TypeAcquisition   = TypeAtom + ";kind=acquisition"
+	TypeJSON          = "application/opds+json"
+	TypeOpenSearch    = "application/opensearchdescription+xml"
+	TypePublication   = "application/json" // The book resource of the API
+	RelAcquisition    = "http://opds-spec.org/acquisition/borrow"
+	RelSortNew        = "http://opds-spec.org/sort/new"
+	namespaceAtom     = "http://www.w3.org/2005/Atom"
+	namespaceDC       = "http://purl.org/dc/terms/"
+	namespaceOPDS     = "http://opds-spec.org/2010/catalog"
+	namespaceSearch   = "http://a9.com/-/spec/opensearch/1.1/"
+	typeSearchResults = "application/atom+xml"
+)
+
+// Kinds of feed.
+const (
+	Navigation  = "navigation"  // Lists other feeds
+	Acquisition = "acquisition" // Lists publications
+)
+
+// Feed describes a catalogue feed independently of its format.
+type Feed struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	Kind    string // Navigation or Acquisition
+	Self    string // Path of the feed, with its query
+	Start   string // Path of the root feed
+	Up      string // Path of the parent feed, if any
+	Search  string // Path of the OpenSearch description, if any
+	Paging  *Paging
+
+	Navigation   []NavigationItem
+	Publications []Publication
+}
+
+// Paging describes the page of a paged feed. Links to the other pages are built from
+// PageHref.
+type Paging struct {
+	Page     int
+	PageSize int
+	Total    int
+	PageHref func(page int) string
+}
+
+// LastPage returns the number of the last page, at least 1.
+func (p *Paging) LastPage() int {
+	if p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+// NavigationItem is an entry of a navigation feed, which links to another feed.
+type NavigationItem struct {
+	ID      string
+	Title   string
+	Summary string
+	Href    string
+	Kind    string // Kind of the linked feed
+	Rel     string // Optional relation, such as RelSortNew
+	Count   int    // Number of publications behind the link, if known
+}
+
+// Publication is an entry of an acquisition feed.
+type Publication struct {
+	ID        string
+	Title     string
+	Authors   []Contributor
+	Publisher string
+	Language  string
+	Year      int
+	ISBN      string
+	Summary   string
+	Subjects  []string
+	Href      string // Path of the book resource, linked as the way to borrow it
+	Updated   time.Time
+}
+
+// Contributor is an author of a publication, with the path of their feed.
+type Contributor struct {
+	Name string
+	Href string
+}
+
+// pageLinks returns the first, previous, next and last links of a paged feed.
+func pageLinks(paging *Paging) map[string]string {
+	links := map[string]string{}
+	if paging == nil {
+		return links
+	}
+	last := paging.LastPage()
+	links["first"] = paging.PageHref(1)
+	links["last"] = paging.PageHref(last)
+	if paging.Page > 1 {
+		links["previous"] = paging.PageHref(paging.Page - 1)
+	}
+	if paging.Page < last {
+		links["next"] = paging.PageHref(paging.Page + 1)
+	}
+	return links
+}
+
+// pageRels orders the page links in documents.
+var pageRels = []string{"first", "previous", "next", "last"}
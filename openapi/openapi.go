@@ -0,0 +1,306 @@
+// Package openapi generates an OpenAPI 3.1 document from the routes of the API and a
+// description of each operation. Schemas are derived from the Go types of request and
+// response bodies: their json tags name the properties and their binding tags give
+// the constraints.
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Media types of PATCH bodies.
+const (
+	MergePatch = "application/merge-patch+json" // RFC 7396
+	JSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// Route is a registered route, as reported by the router.
+type Route struct {
+	Method     string
+	Path       string // With gin parameters, e.g. /books/:id
+	Handler    string // Name of the handler function
+	Version    string // Path prefix of the version of the API serving the route, e.g. /api/v1
+	Deprecated bool
+	Alias      bool // Duplicates another route; checked but left out of the document
+}
+
+// Key returns the key of the route in the operations: "METHOD /path" as registered.
+func (r Route) Key() string {
+	return r.Method + " " + r.Path
+}
+
+// Operation describes what a route does and what it takes and returns.
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string      // Defaults to the first segment of the path
+	Query       []Parameter // Query parameters; path parameters are derived from the path
+	Body        interface{} // Value of the type of the JSON request body, nil for none
+	BodyTypes   []string    // Media types of a request body that is not JSON
+	Patch       interface{} // Value of the type a PATCH body applies to, as a merge or JSON patch
+	Status      int         // Status of a successful response, 200 by default
+	Response    interface{} // Value of the type of the JSON response body
+	Produces    []string    // Media types of a response that is not JSON
+}
+
+// Parameter is a query parameter.
+type Parameter struct {
+	Name        string
+	Description string
+	Type        string   // string, integer or boolean
+	Enum        []string // Allowed values, if restricted
+	Required    bool
+}
+
+// Info describes the API as a whole. Problem is a value of the type of error
+// responses, which every operation may return.
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+	Problem     interface{}
+	ProblemType string // Media type of error responses
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       infoObject                      `json:"info"`
+	Paths      map[string]map[string]*opObject `json:"paths"`
+	Components components                      `json:"components"`
+}
+
+type infoObject struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type opObject struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []parameterObject    `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Undocumented returns the routes, as "METHOD /path", that have no operation.
+func Undocumented(routes []Route, operations map[string]Operation) []string {
+	var missing []string
+	for _, route := range routes {
+		if _, ok := lookup(route, operations); !ok {
+			missing = append(missing, route.Key())
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// lookup returns the operation of a route. The routes of a version are described by
+// the operation of their path within the version, unless the version has its own.
+func lookup(route Route, operations map[string]Operation) (Operation, bool) {
+	if operation, ok := operations[route.Key()]; ok {
+		return operation, true
+	}
+	if route.Version == "" {
+		return Operation{}, false
+	}
+	operation, ok := operations[route.Method+" "+strings.TrimPrefix(route.Path, route.Version)]
+	return operation, ok
+}
+
+// Generate builds the document of the given routes. Routes without an operation are
+// left out; see Undocumented.
+func Generate(info Info, routes []Route, operations map[string]Operation) *Document {
+	schemas := newSchemaSet()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    infoObject{Title: info.Title, Version: info.Version, Description: info.Description},
+		Paths:   map[string]map[string]*opObject{},
+	}
+	problem := schemas.of(info.Problem, false)
+
+	for _, route := range routes {
+		operation, ok := lookup(route, operations)
+		if !ok || route.Alias {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+
+		op := &opObject{
+			OperationID: operationID(route),
+			Summary:     operation.Summary,
+			Description: operation.Description,
+			Tags:        []string{operation.Tag},
+			Deprecated:  route.Deprecated,
+			Responses:   map[string]*response{},
+		}
+		if op.Tags[0] == "" {
+			op.Tags[0] = strings.SplitN(strings.TrimPrefix(route.Path, route.Version+"/"), "/", 2)[0]
+		}
+		for _, name := range params {
+			op.Parameters = append(op.Parameters, parameterObject{
+				Name: name, In: "path", Required: true, Schema: pathParameterSchema(name),
+			})
+		}
+		for _, param := range operation.Query {
+			schema := &Schema{Type: param.Type}
+			for _, value := range param.Enum {
+				schema.Enum = append(schema.Enum, value)
+			}
+			op.Parameters = append(op.Parameters, parameterObject{
+				Name: param.Name, In: "query", Description: param.Description, Required: param.Required, Schema: schema,
+			})
+		}
+
+		if operation.Body != nil || len(operation.BodyTypes) > 0 || operation.Patch != nil {
+			op.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{}}
+			if operation.Body != nil {
+				op.RequestBody.Content["application/json"] = mediaType{Schema: schemas.of(operation.Body, true)}
+			}
+			for _, contentType := range operation.BodyTypes {
+				op.RequestBody.Content[contentType] = mediaType{Schema: mediaSchema(contentType)}
+			}
+			if operation.Patch != nil {
+				op.RequestBody.Content[MergePatch] = mediaType{Schema: schemas.mergePatch(operation.Patch)}
+				op.RequestBody.Content[JSONPatch] = mediaType{Schema: jsonPatchSchema()}
+			}
+		}
+
+		status := operation.Status
+		if status == 0 {
+			status = 200
+		}
+		success := &response{Description: statusText(status), Content: map[string]mediaType{}}
+		if operation.Response != nil {
+			success.Content["application/json"] = mediaType{Schema: schemas.of(operation.Response, false)}
+		}
+		for _, contentType := range operation.Produces {
+			success.Content[contentType] = mediaType{Schema: mediaSchema(contentType)}
+		}
+		if len(success.Content) == 0 {
+			success.Content = nil
+		}
+		op.Responses[itoa(status)] = success
+		op.Responses["default"] = &response{
+			Description: "Error",
+			Content:     map[string]mediaType{info.ProblemType: {Schema: problem}},
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*opObject{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components.Schemas = schemas.components
+	return doc
+}
+
+// openAPIPath turns /books/:id into /books/{id} and returns the names of the path
+// parameters.
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// pathParameterSchema returns the schema of a path parameter: IDs are positive
+// integers, anything else a string.
+func pathParameterSchema(name string) *Schema {
+	if name == "id" || strings.HasSuffix(name, "_id") {
+		return &Schema{Type: "integer", Minimum: float(1)}
+	}
+	return &Schema{Type: "string"}
+}
+
+// mediaSchema returns the schema of a body described only by its media type: any JSON
+// value for JSON media types, a string otherwise.
+func mediaSchema(contentType string) *Schema {
+	if strings.HasSuffix(contentType, "json") {
+		return &Schema{}
+	}
+	return &Schema{Type: "string"}
+}
+
+// jsonPatchSchema returns the schema of an RFC 6902 JSON Patch document.
+func jsonPatchSchema() *Schema {
+	return &Schema{
+		Type: "array",
+		Items: &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"op":    {Type: "string", Enum: []interface{}{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string"},
+				"from":  {Type: "string"},
+				"value": {},
+			},
+			Required: []string{"op", "path"},
+		},
+	}
+}
+
+// operationID derives an operation ID from the name of the handler, such as
+// "gin-books-api/handlers.GetBooks", and the version serving the route.
+func operationID(route Route) string {
+	name := strings.TrimSuffix(route.Handler, "-fm")
+	// Handlers returned by a function are named after it, e.g. handlers.OpenAPI.func1
+	for i := strings.LastIndex(name, "."); i >= 0 && strings.HasPrefix(name[i+1:], "func"); i = strings.LastIndex(name, ".") {
+		name = name[:i]
+	}
+	name = name[strings.LastIndex(name, ".")+1:]
+	if version := route.Version[strings.LastIndex(route.Version, "/")+1:]; version != "" {
+		return version + name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// statusText names the successful statuses operations return.
+func statusText(status int) string {
+	switch status {
+	case 201:
+		return "Created"
+	case 204:
+		return "No Content"
+	}
+	return "OK"
+}
@@ -0,0 +1,251 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema, as OpenAPI 3.1 uses them.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // A name, or names for nullable types
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaSet collects the schemas of named structs as components, so that each is
+// described once and recursive types end in references.
+type schemaSet struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// of returns the schema of the type of v. The binding tags of request bodies mark
+// required fields and constraints; those of responses are ignored, since a response
+// carries every field whether or not a request must.
+func (s *schemaSet) of(v interface{}, request bool) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return s.schema(reflect.TypeOf(v), request)
+}
+
+func (s *schemaSet) schema(t reflect.Type, request bool) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() != reflect.Pointer && t.Implements(marshalerType):
+		// Types with their own JSON encoding, such as raw JSON columns, may hold anything
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(s.schema(t.Elem(), request))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem(), request)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem(), request)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t, request)
+		}
+		return s.reference(t, request)
+	}
+	return &Schema{}
+}
+
+// mergePatch returns the schema of a JSON merge patch of a request body: the fields of
+// the body, none of them required. Nested objects are replaced whole, so they keep
+// their own required fields.
+func (s *schemaSet) mergePatch(v interface{}) *Schema {
+	body := s.of(v, true)
+	if body.Ref != "" {
+		body = s.components[strings.TrimPrefix(body.Ref, "#/components/schemas/")]
+	}
+	return &Schema{Type: "object", Properties: body.Properties}
+}
+
+// reference returns a reference to the component of a named struct, describing it
+// first if needed.
+func (s *schemaSet) reference(t reflect.Type, request bool) *Schema {
+	if name, ok := s.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// Unexported types, such as the envelopes of responses, are named like the others
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := s.components[name]; taken {
+		// Types of the same name from different packages, such as a request and the
+		// model it creates, are told apart by their package
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.names[t] = name
+	s.components[name] = &Schema{} // Reserved while the fields are described
+	s.components[name] = s.object(t, request)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object describes the exported fields of a struct, flattening embedded ones as
+// encoding/json does.
+func (s *schemaSet) object(t reflect.Type, request bool) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := s.object(field.Type, request)
+			for property, schema := range embedded.Properties {
+				object.Properties[property] = schema
+			}
+			object.Required = append(object.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := s.schema(field.Type, request)
+		if request && applyBinding(schema, field.Tag.Get("binding")) {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = schema
+	}
+	return object
+}
+
+// applyBinding adds the constraints of a binding tag to the schema of a field, and
+// reports whether the field is required.
+func applyBinding(schema *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			// The rules after dive apply to the elements, which describe themselves
+			return required
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "unique":
+			schema.UniqueItems = true
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "min", "max", "gt":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(schema, name, n)
+		}
+	}
+	return required
+}
+
+// applyBound applies a min, max or gt rule, which bound the length of strings and
+// slices and the value of numbers.
+func applyBound(schema *Schema, rule string, n float64) {
+	target := schema
+	if len(schema.AnyOf) > 0 {
+		target = schema.AnyOf[0]
+	}
+	typ, _ := target.Type.(string)
+	if names, ok := target.Type.([]string); ok {
+		typ = names[0]
+	}
+
+	switch typ {
+	case "string":
+		length := int(n)
+		switch rule {
+		case "min":
+			target.MinLength = &length
+		case "max":
+			target.MaxLength = &length
+		}
+	case "array":
+		length := int(n)
+		switch rule {
+		case "min":
+			target.MinItems = &length
+		case "max":
+			target.MaxItems = &length
+		}
+	case "integer", "number":
+		switch rule {
+		case "min":
+			target.Minimum = float(n)
+		case "max":
+			target.Maximum = float(n)
+		case "gt":
+			target.Minimum = nil
+			target.ExclusiveMinimum = float(n)
+		}
+	}
+}
+
+// nullable allows null besides the values of a schema.
+func nullable(schema *Schema) *Schema {
+	if typ, ok := schema.Type.(string); ok {
+		schema.Type = []string{typ, "null"}
+		return schema
+	}
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+	}
+	return schema
+}
+
+func float(n float64) *float64 {
+	return &n
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
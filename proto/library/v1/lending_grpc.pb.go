@@ -0,0 +1,252 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: library/v1/lending.proto
+
+package libraryv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	LendingService_GetLoan_FullMethodName    = "/library.v1.LendingService/GetLoan"
+	LendingService_ListLoans_FullMethodName  = "/library.v1.LendingService/ListLoans"
+	LendingService_BorrowBook_FullMethodName = "/library.v1.LendingService/BorrowBook"
+	LendingService_ReturnBook_FullMethodName = "/library.v1.LendingService/ReturnBook"
+)
+
+// LendingServiceClient is the client API for LendingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// LendingService lends books to users. A book on loan is unavailable until it is
+// returned.
+type LendingServiceClient interface {
+	GetLoan(ctx context.Context, in *GetLoanRequest, opts ...grpc.CallOption) (*Loan, error)
+	// ListLoans streams the books on loan, ordered by ID.
+	ListLoans(ctx context.Context, in *ListLoansRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Loan], error)
+	// BorrowBook fails with FAILED_PRECONDITION if the book is already on loan.
+	BorrowBook(ctx context.Context, in *BorrowBookRequest, opts ...grpc.CallOption) (*Loan, error)
+	// ReturnBook ends a loan and makes the book available again.
+	ReturnBook(ctx context.Context, in *ReturnBookRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type lendingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLendingServiceClient(cc grpc.ClientConnInterface) LendingServiceClient {
+	return &lendingServiceClient{cc}
+}
+
+func (c *lendingServiceClient) GetLoan(ctx context.Context, in *GetLoanRequest, opts ...grpc.CallOption) (*Loan, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Loan)
+	err := c.cc.Invoke(ctx, LendingService_GetLoan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lendingServiceClient) ListLoans(ctx context.Context, in *ListLoansRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Loan], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LendingService_ServiceDesc.Streams[0], LendingService_ListLoans_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListLoansRequest, Loan]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LendingService_ListLoansClient = grpc.ServerStreamingClient[Loan]
+
+func (c *lendingServiceClient) BorrowBook(ctx context.Context, in *BorrowBookRequest, opts ...grpc.CallOption) (*Loan, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Loan)
+	err := c.cc.Invoke(ctx, LendingService_BorrowBook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lendingServiceClient) ReturnBook(ctx context.Context, in *ReturnBookRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, LendingService_ReturnBook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LendingServiceServer is the server API for LendingService service.
+// All implementations must embed UnimplementedLendingServiceServer
+// for forward compatibility.
+//
+// LendingService lends books to users. A book on loan is unavailable until it is
+// returned.
+type LendingServiceServer interface {
+	GetLoan(context.Context, *GetLoanRequest) (*Loan, error)
+	// ListLoans streams the books on loan, ordered by ID.
+	ListLoans(*ListLoansRequest, grpc.ServerStreamingServer[Loan]) error
+	// BorrowBook fails with FAILED_PRECONDITION if the book is already on loan.
+	BorrowBook(context.Context, *BorrowBookRequest) (*Loan, error)
+	// ReturnBook ends a loan and makes the book available again.
+	ReturnBook(context.Context, *ReturnBookRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedLendingServiceServer()
+}
+
+// UnimplementedLendingServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedLendingServiceServer struct{}
+
+func (UnimplementedLendingServiceServer) GetLoan(context.Context, *GetLoanRequest) (*Loan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLoan not implemented")
+}
+func (UnimplementedLendingServiceServer) ListLoans(*ListLoansRequest, grpc.ServerStreamingServer[Loan]) error {
+	return status.Errorf(codes.Unimplemented, "method ListLoans not implemented")
+}
+func (UnimplementedLendingServiceServer) BorrowBook(context.Context, *BorrowBookRequest) (*Loan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BorrowBook not implemented")
+}
+func (UnimplementedLendingServiceServer) ReturnBook(context.Context, *ReturnBookRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReturnBook not implemented")
+}
+func (UnimplementedLendingServiceServer) mustEmbedUnimplementedLendingServiceServer() {}
+func (UnimplementedLendingServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeLendingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LendingServiceServer will
+// result in compilation errors.
+type UnsafeLendingServiceServer interface {
+	mustEmbedUnimplementedLendingServiceServer()
+}
+
+func RegisterLendingServiceServer(s grpc.ServiceRegistrar, srv LendingServiceServer) {
+	// If the following call pancis, it indicates UnimplementedLendingServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&LendingService_ServiceDesc, srv)
+}
+
+func _LendingService_GetLoan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLoanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LendingServiceServer).GetLoan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LendingService_GetLoan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LendingServiceServer).GetLoan(ctx, req.(*GetLoanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LendingService_ListLoans_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListLoansRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LendingServiceServer).ListLoans(m, &grpc.GenericServerStream[ListLoansRequest, Loan]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LendingService_ListLoansServer = grpc.ServerStreamingServer[Loan]
+
+func _LendingService_BorrowBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BorrowBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LendingServiceServer).BorrowBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LendingService_BorrowBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LendingServiceServer).BorrowBook(ctx, req.(*BorrowBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LendingService_ReturnBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReturnBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LendingServiceServer).ReturnBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LendingService_ReturnBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LendingServiceServer).ReturnBook(ctx, req.(*ReturnBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LendingService_ServiceDesc is the grpc.ServiceDesc for LendingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LendingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "library.v1.LendingService",
+	HandlerType: (*LendingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLoan",
+			Handler:    _LendingService_GetLoan_Handler,
+		},
+		{
+			MethodName: "BorrowBook",
+			Handler:    _LendingService_BorrowBook_Handler,
+		},
+		{
+			MethodName: "ReturnBook",
+			Handler:    _LendingService_ReturnBook_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListLoans",
+			Handler:       _LendingService_ListLoans_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "library/v1/lending.proto",
+}
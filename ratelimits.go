@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"gin-books-api/middleware"
+)
+
+// rateLimiter limits the request rates of both the REST API and the gRPC server, so
+// that a client cannot get twice its rate by using both.
+var rateLimiter = middleware.NewRateLimiter(loadRateLimits())
+
+// rateLimits are the default request rates allowed to each client, by group of routes
+// and by role: all the clients of an IP address together, at the anonymous rate, and
+// each user identified by X-User-ID within their address.
+var rateLimits = middleware.RateLimits{
+	Default: middleware.RateLimitPolicy{
+		Anonymous: middleware.Rate{Requests: 120, Window: time.Minute},
+		User:      middleware.Rate{Requests: 60, Window: time.Minute},
+	},
+	Groups: map[string]middleware.RateLimitPolicy{
+		// A miss of the cache of GET /books loads the whole catalogue
+		"books": {
+			Anonymous: middleware.Rate{Requests: 60, Window: time.Minute},
+			User:      middleware.Rate{Requests: 30, Window: time.Minute},
+		},
+		"graphql": {
+			Anonymous: middleware.Rate{Requests: 60, Window: time.Minute},
+			User:      middleware.Rate{Requests: 30, Window: time.Minute},
+		},
+		"import": {
+			Anonymous: middleware.Rate{Requests: 10, Window: time.Hour},
+			User:      middleware.Rate{Requests: 5, Window: time.Hour},
+		},
+		"export": {
+			Anonymous: middleware.Rate{Requests: 10, Window: time.Minute},
+			User:      middleware.Rate{Requests: 5, Window: time.Minute},
+		},
+	},
+}
+
+// loadRateLimits returns rateLimits with the rates set by the RATE_LIMIT_<GROUP>_<ROLE>
+// variables, such as RATE_LIMIT_BOOKS_ANONYMOUS=60/1m.
+func loadRateLimits() middleware.RateLimits {
+	limits, err := rateLimits.WithEnv(os.Environ())
+	if err != nil {
+		slog.Warn("Invalid rate limits ignored", "error", err)
+	}
+	return limits
+}
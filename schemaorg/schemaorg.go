@@ -0,0 +1,170 @@
+// Package schemaorg describes books and authors with the schema.org vocabulary, as
+// JSON-LD for search engines and linked-data tools.
+package schemaorg
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"gin-books-api/models"
+)
+
+// ContentType is the media type of JSON-LD documents.
+const ContentType = "application/ld+json"
+
+const vocabulary = "https://schema.org"
+
+// bookFormats maps the formats of books onto schema.org BookFormatType values.
+var bookFormats = map[string]string{
+	"hardcover": "https://schema.org/Hardcover",
+	"paperback": "https://schema.org/Paperback",
+	"ebook":     "https://schema.org/EBook",
+	"audiobook": "https://schema.org/AudiobookFormat",
+}
+
+// Rating summarises the reviews of a book.
+type Rating struct {
+	Count   int64
+	Average float64
+}
+
+// Book is a schema.org Book.
+type Book struct {
+	Context         string           `json:"@context,omitempty"`
+	Type            string           `json:"@type"`
+	ID              string           `json:"@id"`
+	URL             string           `json:"url"`
+	Name            string           `json:"name"`
+	Description     string           `json:"description,omitempty"`
+	ISBN            string           `json:"isbn,omitempty"`
+	InLanguage      string           `json:"inLanguage,omitempty"`
+	BookFormat      string           `json:"bookFormat,omitempty"`
+	DatePublished   string           `json:"datePublished,omitempty"`
+	Author          *Person          `json:"author,omitempty"`
+	Publisher       *Organization    `json:"publisher,omitempty"`
+	Genre           string           `json:"genre,omitempty"`
+	About           []string         `json:"about,omitempty"`
+	Keywords        string           `json:"keywords,omitempty"`
+	AggregateRating *AggregateRating `json:"aggregateRating,omitempty"`
+}
+
+// Person is a schema.org Person. Reverse lists the books whose author the person is.
+type Person struct {
+	Context     string         `json:"@context,omitempty"`
+	Type        string         `json:"@type"`
+	ID          string         `json:"@id"`
+	URL         string         `json:"url,omitempty"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Reverse     *personReverse `json:"@reverse,omitempty"`
+}
+
+type personReverse struct {
+	Author []Book `json:"author"`
+}
+
+// Organization is a schema.org Organization, used for publishers.
+type Organization struct {
+	Type    string `json:"@type"`
+	ID      string `json:"@id"`
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+}
+
+// AggregateRating is a schema.org AggregateRating on the 1 to 5 scale of reviews.
+type AggregateRating struct {
+	Type        string  `json:"@type"`
+	RatingValue float64 `json:"ratingValue"`
+	ReviewCount int64   `json:"reviewCount"`
+	BestRating  int     `json:"bestRating"`
+	WorstRating int     `json:"worstRating"`
+}
+
+// NewBook describes a book, with its author, publisher, category, tags and subjects
+// loaded, as a standalone document. base is the absolute URL of the API, from which
+// the @id of the book and of the related resources are built.
+func NewBook(book models.Book, rating Rating, base string) Book {
+	described := newBook(book, rating, base)
+	described.Context = vocabulary
+	if book.AuthorID != nil && book.Author.Name != "" {
+		described.Author = &Person{
+			Type: "Person",
+			ID:   resourceID(base, "authors", *book.AuthorID),
+			Name: book.Author.Name,
+		}
+	}
+	return described
+}
+
+// NewPerson describes an author and the given books, which may carry their publishers
+// and categories but need not carry the author.
+func NewPerson(author models.Author, books []models.Book, ratings map[uint]Rating, base string) Person {
+	person := Person{
+		Context:     vocabulary,
+		Type:        "Person",
+		ID:          resourceID(base, "authors", author.ID),
+		URL:         resourceID(base, "authors", author.ID),
+		Name:        author.Name,
+		Description: author.Bio,
+	}
+	if len(books) > 0 {
+		person.Reverse = &personReverse{}
+		for _, book := range books {
+			person.Reverse.Author = append(person.Reverse.Author, newBook(book, ratings[book.ID], base))
+		}
+	}
+	return person
+}
+
+// newBook describes the book itself, without its author.
+func newBook(book models.Book, rating Rating, base string) Book {
+	id := resourceID(base, "books", book.ID)
+	described := Book{
+		Type:        "Book",
+		ID:          id,
+		URL:         id,
+		Name:        book.Title,
+		Description: book.Description,
+		ISBN:        book.ISBN,
+		InLanguage:  book.Language,
+		BookFormat:  bookFormats[strings.ToLower(book.Format)],
+		Genre:       book.Category.Name,
+	}
+	if book.PublishedYear > 0 {
+		described.DatePublished = strconv.Itoa(book.PublishedYear)
+	}
+	if book.PublisherID != nil && book.Publisher.Name != "" {
+		described.Publisher = &Organization{
+			Type:    "Organization",
+			ID:      resourceID(base, "publishers", *book.PublisherID),
+			Name:    book.Publisher.Name,
+			Address: book.Publisher.Address,
+		}
+	}
+	for _, subject := range book.Subjects {
+		described.About = append(described.About, subject.Name)
+	}
+	var keywords []string
+	for _, tag := range book.Tags {
+		keywords = append(keywords, tag.Name)
+	}
+	described.Keywords = strings.Join(keywords, ", ")
+
+	// Search engines ignore ratings without reviews, so a book without any has none
+	if rating.Count > 0 {
+		described.AggregateRating = &AggregateRating{
+			Type:        "AggregateRating",
+			RatingValue: math.Round(rating.Average*10) / 10,
+			ReviewCount: rating.Count,
+			BestRating:  5,
+			WorstRating: 1,
+		}
+	}
+	return described
+}
+
+// resourceID returns the absolute URL of a resource of the API.
+func resourceID(base, collection string, id uint) string {
+	return base + "/" + collection + "/" + strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Audited actions.
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// Audited entities.
+const (
+	AuditEntityAuthor    = "author"
+	AuditEntityBook      = "book"
+	AuditEntityCategory  = "category"
+	AuditEntityLoan      = "loan"
+	AuditEntityPublisher = "publisher"
+	AuditEntityReview    = "review"
+	AuditEntitySeries    = "series"
+	AuditEntitySubject   = "subject"
+	AuditEntityUser      = "user"
+	AuditEntityWork      = "work"
+)
+
+// redactedFields are never written to the audit trail.
+var redactedFields = map[string]bool{"password": true}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the ID of the user making the request.
+// Mutations made with that context are attributed to the user in the audit trail.
+func WithActor(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// actorFrom returns the acting user stored in ctx, or nil for anonymous requests.
+func actorFrom(ctx context.Context) *uint {
+	if id, ok := ctx.Value(actorKey{}).(uint); ok {
+		return &id
+	}
+	return nil
+}
+
+// AuditFilter selects audit log entries. Zero values match everything.
+type AuditFilter struct {
+	Entity   string
+	EntityID uint
+	ActorID  uint
+	Page     int
+	PageSize int
+}
+
+// FetchAuditLogs fetches audit log entries, newest first, and the total number of matches.
+func FetchAuditLogs(ctx context.Context, filter AuditFilter) ([]models.AuditLog, int64, error) {
+	query := config.GetDB().WithContext(ctx).Model(&models.AuditLog{})
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.AuditLog
+	if err := query.
+		Order("created_at DESC, id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// recordAudit writes an audit log entry for a mutation inside the mutation's transaction.
+// before is nil for creates and after is nil for deletes.
+func recordAudit(ctx context.Context, tx *gorm.DB, action, entity string, entityID uint, before, after interface{}) error {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.AuditLog{
+		ActorID:  actorFrom(ctx),
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		Diff:     diff,
+	}).Error
+}
+
+// updateAudited sets column to value on the rows of T matching query and args, as a
+// cascade of another mutation, and audits the update of each row inside tx. id returns
+// the ID of a row. It returns the IDs of the rows updated.
+func updateAudited[T any](ctx context.Context, tx *gorm.DB, entity string, id func(*T) uint, column string, value interface{}, query string, args ...interface{}) ([]uint, error) {
+	var before []T
+	if err := tx.Where(query, args...).Order("id").Find(&before).Error; err != nil {
+		return nil, err
+	}
+	if len(before) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(before))
+	for i := range before {
+		ids[i] = id(&before[i])
+	}
+
+	if err := tx.Model(new(T)).Where("id IN ?", ids).Update(column, value).Error; err != nil {
+		return nil, err
+	}
+	var after []T
+	if err := tx.Where("id IN ?", ids).Order("id").Find(&after).Error; err != nil {
+		return nil, err
+	}
+	for i := range after {
+		if err := recordAudit(ctx, tx, AuditActionUpdate, entity, ids[i], &before[i], &after[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// auditDiff returns the fields that differ between the JSON forms of before and after,
+// as {"field": {"before": ..., "after": ...}}.
+func auditDiff(before, after interface{}) (models.JSON, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+	changes := make(map[string]change)
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			changes[field] = change{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, seen := beforeFields[field]; !seen && value != nil {
+			changes[field] = change{After: value}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+// auditFields flattens an entity into its JSON fields, without redacted fields.
+func auditFields(entity interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if entity == nil {
+		return fields, nil
+	}
+	if value := reflect.ValueOf(entity); value.Kind() == reflect.Ptr && value.IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for field := range redactedFields {
+		delete(fields, field)
+	}
+	return fields, nil
+}
@@ -1,100 +1,151 @@
 package services
 
 import (
-    "context"
-    "log"
-    "strconv"
+	"context"
+	"log/slog"
+	"strconv"
 
-    "gin-books-api/cache"
-    config "gin-books-api/configs"
-    "gin-books-api/models"
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
 )
 
 const (
-    cacheKeyAuthorsAll     = "authors_all"
-    cacheKeyAuthorPrefix   = "author_"
+	cacheKeyAuthorsAll   = "authors_all"
+	cacheKeyAuthorPrefix = "author_"
 )
 
 // FetchAuthorsFromDB fetches authors from the database, caches them, and returns the result.
 func FetchAuthorsFromDB(ctx context.Context, cacheKey string) ([]models.Author, error) {
-    var authors []models.Author
-    if err := config.GetDB().Preload("Book").Find(&authors).Error; err != nil {
-        log.Printf("Database error while fetching authors: %v", err)
-        return nil, err
-    }
-
-    // Cache the complete list of authors
-    if err := cache.SetCachedData(ctx, cacheKey, authors, cache.CacheExpiration); err != nil {
-        log.Printf("Redis SET error for key %s: %v", cacheKey, err)
-        // Proceed without caching
-    }
-
-    return authors, nil
+	var authors []models.Author
+	if err := config.GetDB().WithContext(ctx).Preload("Book").Find(&authors).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching authors", "error", err)
+		return nil, err
+	}
+
+	// Cache the complete list of authors
+	if err := cache.SetCachedData(ctx, cacheKey, authors, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return authors, nil
 }
 
 // FetchAuthorFromDB fetches a single author from the database, caches it, and returns the result.
 func FetchAuthorFromDB(ctx context.Context, cacheKey string, id int) (*models.Author, error) {
-    var author models.Author
-    if result := config.GetDB().Preload("Book").First(&author, id); result.Error != nil {
-        return nil, result.Error
-    }
-
-    // Cache the fetched author
-    if err := cache.SetCachedData(ctx, cacheKey, author, cache.CacheExpiration); err != nil {
-        log.Printf("Redis SET error for key %s: %v", cacheKey, err)
-        // Proceed without caching
-    }
-
-    return &author, nil
+	var author models.Author
+	if result := config.GetDB().WithContext(ctx).Preload("Book").First(&author, id); result.Error != nil {
+		return nil, result.Error
+	}
+
+	// Cache the fetched author
+	if err := cache.SetCachedData(ctx, cacheKey, author, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return &author, nil
 }
 
 // CreateAuthor creates a new author and stores it in the database.
 func CreateAuthor(ctx context.Context, author *models.Author) error {
-    if err := config.GetDB().Create(author).Error; err != nil {
-        return err
-    }
-
-    // Invalidate cache
-    if err := config.RedisClient.Del(ctx, cacheKeyAuthorsAll).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-
-    return nil
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(author).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityAuthor, author.ID, nil, author)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	invalidateCache(ctx, cacheKeyAuthorsAll)
+
+	return nil
 }
 
 // UpdateAuthor updates an existing author by its ID.
 func UpdateAuthor(ctx context.Context, id int, author *models.Author) error {
-    author.ID = uint(id)
-    if err := config.GetDB().Save(author).Error; err != nil {
-        return err
-    }
-
-    // Invalidate cache
-    cacheKey := cacheKeyAuthorPrefix + strconv.Itoa(id)
-    if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-    if err := config.RedisClient.Del(ctx, cacheKeyAuthorsAll).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-
-    return nil
+	author.ID = uint(id)
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Author
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(author).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityAuthor, author.ID, &previous, author)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	cacheKey := cacheKeyAuthorPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyAuthorsAll)
+	invalidateFeeds(ctx)
+
+	return nil
+}
+
+// PatchAuthor writes only the given columns of author to the author with the given ID and
+// reloads author from the stored row.
+func PatchAuthor(ctx context.Context, id int, author *models.Author, columns []string) error {
+	author.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(author, id).Error
+	}
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Author
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := updateColumns(tx, author, columns); err != nil {
+			return err
+		}
+		if err := tx.First(author, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityAuthor, author.ID, &previous, author)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	cacheKey := cacheKeyAuthorPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyAuthorsAll)
+	invalidateFeeds(ctx)
+
+	return nil
 }
 
 // DeleteAuthor deletes an author by its ID.
 func DeleteAuthor(ctx context.Context, id int) error {
-    if err := config.GetDB().Delete(&models.Author{}, id).Error; err != nil {
-        return err
-    }
-
-    // Invalidate cache
-    cacheKey := cacheKeyAuthorPrefix + strconv.Itoa(id)
-    if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-    if err := config.RedisClient.Del(ctx, cacheKeyAuthorsAll).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-
-    return nil
-}
\ No newline at end of file
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Author
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&previous).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityAuthor, previous.ID, &previous, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	cacheKey := cacheKeyAuthorPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyAuthorsAll)
+	invalidateFeeds(ctx)
+
+	return nil
+}
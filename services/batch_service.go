@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+
+	config "gin-books-api/configs"
+)
+
+// FetchByIDs returns the rows of T with the given IDs, without their relations. IDs
+// without a row are left out. Along with FetchByColumn it lets the GraphQL API load the
+// relations of many rows in one query.
+func FetchByIDs[T any](ctx context.Context, ids []uint) ([]T, error) {
+	var rows []T
+	if err := config.GetDB().WithContext(ctx).Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// FetchByColumn returns the first limit rows of T, by ID, whose column, a foreign key
+// named by the caller, holds each of ids: the books of some authors, for instance, up to
+// limit books for each author. Rows are ordered by ID.
+func FetchByColumn[T any](ctx context.Context, column string, ids []uint, limit int) ([]T, error) {
+	db := config.GetDB().WithContext(ctx)
+	ranked := db.Model(new(T)).
+		Select("*, ROW_NUMBER() OVER (PARTITION BY "+column+" ORDER BY id) AS rank_in_key").
+		Where(column+" IN ?", ids)
+
+	var rows []T
+	if err := db.Table("(?) AS ranked", ranked).Where("rank_in_key <= ?", limit).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// FetchPage returns up to limit rows of T following the row with ID after, ordered by
+// ID, and the number of rows of T in all. Paging by key rather than offset keeps pages
+// stable while rows are added.
+func FetchPage[T any](ctx context.Context, after uint, limit int) ([]T, int64, error) {
+	var rows []T
+	var total int64
+	db := config.GetDB().WithContext(ctx)
+	if err := db.Model(new(T)).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := db.Where("id > ?", after).Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
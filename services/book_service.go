@@ -2,12 +2,16 @@ package services
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strconv"
+	"strings"
 
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
 	config "gin-books-api/configs"
 	"gin-books-api/models"
+
+	"gorm.io/gorm"
 )
 
 const (
@@ -15,24 +19,31 @@ const (
 	cacheKeyBookPrefix = "book_"
 )
 
+// withBookRelations preloads the author, publisher, category, reviews, tags and subjects of books.
+func withBookRelations(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Author").
+		Preload("Publisher").
+		Preload("Category").
+		Preload("Reviews").
+		Preload("Tags").
+		Preload("Subjects")
+}
+
 // FetchBooksFromDB fetches books from the database, caches them, and returns the result.
 func FetchBooksFromDB(ctx context.Context, cacheKey string) ([]models.Book, error) {
 	var books []models.Book
-	query := config.GetDB().
-		Preload("Author"). // Preload books for each author
-		Preload("Publisher").
-		Preload("Category").
-		Preload("Reviews")
+	query := withBookRelations(config.GetDB().WithContext(ctx))
 
 	// Fetch all books for caching
 	if err := query.Find(&books).Error; err != nil {
-		log.Printf("Database error while fetching books: %v", err)
+		slog.ErrorContext(ctx, "Database error while fetching books", "error", err)
 		return nil, err
 	}
 
 	// Cache the complete list of books
 	if err := cache.SetCachedData(ctx, cacheKey, books, cache.CacheExpiration); err != nil {
-		log.Printf("Redis SET error for key %s: %v", cacheKey, err)
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
 		// Proceed without caching
 	}
 
@@ -42,29 +53,85 @@ func FetchBooksFromDB(ctx context.Context, cacheKey string) ([]models.Book, erro
 // FetchBookFromDB fetches a single book from the database, caches it, and returns the result.
 func FetchBookFromDB(ctx context.Context, cacheKey string, id int) (*models.Book, error) {
 	var book models.Book
-	if result := config.GetDB().Preload("Author").Preload("Publisher").Preload("Category").Preload("Reviews").First(&book, id); result.Error != nil {
+	if result := withBookRelations(config.GetDB().WithContext(ctx)).First(&book, id); result.Error != nil {
 		return nil, result.Error
 	}
 
 	// Cache the fetched book
 	if err := cache.SetCachedData(ctx, cacheKey, book, cache.CacheExpiration); err != nil {
-		log.Printf("Redis SET error for key %s: %v", cacheKey, err)
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
 		// Proceed without caching
 	}
 
 	return &book, nil
 }
 
+// FetchBooksByIDs fetches the books with the given IDs, in that order, with the related
+// rows that bibliographic formats describe. It bypasses the cache, whose entries leave
+// out the author, publisher and category.
+func FetchBooksByIDs(ctx context.Context, ids []uint) ([]models.Book, error) {
+	var books []models.Book
+	err := config.GetDB().WithContext(ctx).
+		Preload("Author").
+		Preload("Publisher").
+		Preload("Category").
+		Preload("Tags").
+		Preload("Subjects").
+		Where("id IN ?", ids).
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Book, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+	ordered := make([]models.Book, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		book, ok := byID[id]
+		if !ok {
+			missing = append(missing, strconv.FormatUint(uint64(id), 10))
+			continue
+		}
+		ordered = append(ordered, book)
+	}
+	if len(missing) > 0 {
+		return nil, apperrors.NotFound("book_not_found", "Books not found: "+strings.Join(missing, ", "))
+	}
+	return ordered, nil
+}
+
+// NormalizeStoredISBNs rewrites the ISBNs stored before books normalized them on save,
+// such as "978-0-306-40615-7", to the form models.NormalizeISBN gives them, so that
+// imports find those books by ISBN. It is run with the migration at startup.
+func NormalizeStoredISBNs(ctx context.Context) error {
+	return config.GetDB().WithContext(ctx).Model(&models.Book{}).
+		Where("isbn LIKE ? OR isbn LIKE ? OR isbn LIKE ?", "%-%", "% %", "%x%").
+		UpdateColumn("isbn", gorm.Expr("UPPER(REPLACE(REPLACE(isbn, '-', ''), ' ', ''))")).Error
+}
+
 // CreateBook creates a new book and stores it in the database.
 func CreateBook(ctx context.Context, book *models.Book) error {
-	if err := config.GetDB().Create(book).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tags", "Subjects").Create(book).Error; err != nil {
+			return err
+		}
+		if err := replaceBookLabels(tx, book); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityBook, book.ID, nil, book)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
-	if err := config.RedisClient.Del(ctx, cacheKeyBooksAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
+	invalidateCache(ctx, cacheKeyBooksAll)
+	invalidateWorkOf(ctx, book)
+	invalidateCache(ctx, cacheKeyTagCloud)
+	invalidateFeeds(ctx)
 
 	return nil
 }
@@ -72,36 +139,158 @@ func CreateBook(ctx context.Context, book *models.Book) error {
 // UpdateBook updates an existing book by its ID.
 func UpdateBook(ctx context.Context, id int, book *models.Book) error {
 	book.ID = uint(id)
-	if err := config.GetDB().Save(book).Error; err != nil {
+	var previous models.Book
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Tags").Preload("Subjects").First(&previous, id).Error; err != nil {
+			return err
+		}
+		book.CreatedAt = previous.CreatedAt
+		if err := tx.Omit("Tags", "Subjects").Save(book).Error; err != nil {
+			return err
+		}
+		if err := replaceBookLabels(tx, book); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityBook, book.ID, &previous, book)
+	})
+	if err != nil {
 		return err
 	}
+	invalidateWorkOf(ctx, &previous)
+	invalidateWorkOf(ctx, book)
+	invalidateCache(ctx, cacheKeyTagCloud)
+	invalidateFeeds(ctx)
 
 	// Invalidate cache
 	cacheKey := cacheKeyBookPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	invalidateCache(ctx, cacheKey, cacheKeyBooksAll)
+
+	return nil
+}
+
+// PatchBook writes only the given columns of book to the book with the given ID and
+// reloads book from the stored row. Tags and subjects are replaced only when "tags" or
+// "subjects" is among the columns.
+func PatchBook(ctx context.Context, id int, book *models.Book, columns []string) error {
+	book.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).Preload("Tags").Preload("Subjects").First(book, id).Error
 	}
-	if err := config.RedisClient.Del(ctx, cacheKeyBooksAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	var previous models.Book
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Tags").Preload("Subjects").First(&previous, id).Error; err != nil {
+			return err
+		}
+		var bookColumns []string
+		for _, column := range columns {
+			if column != "tags" && column != "subjects" {
+				bookColumns = append(bookColumns, column)
+			}
+		}
+		if err := updateColumns(tx, book, bookColumns); err != nil {
+			return err
+		}
+		if patches(columns, "tags") || patches(columns, "subjects") {
+			if !patches(columns, "tags") {
+				book.Tags = previous.Tags
+			}
+			if !patches(columns, "subjects") {
+				book.Subjects = previous.Subjects
+			}
+			if err := replaceBookLabels(tx, book); err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("Tags").Preload("Subjects").First(book, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityBook, book.ID, &previous, book)
+	})
+	if err != nil {
+		return err
 	}
+	invalidateWorkOf(ctx, &previous)
+	invalidateWorkOf(ctx, book)
+	invalidateCache(ctx, cacheKeyTagCloud)
+	invalidateFeeds(ctx)
+
+	// Invalidate cache
+	cacheKey := cacheKeyBookPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyBooksAll)
 
 	return nil
 }
 
 // DeleteBook deletes a book by its ID.
 func DeleteBook(ctx context.Context, id int) error {
-	if err := config.GetDB().Delete(&models.Book{}, id).Error; err != nil {
+	var book models.Book
+	var seriesIDs []uint
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Tags").Preload("Subjects").First(&book, id).Error; err != nil {
+			return err
+		}
+
+		// Close the gaps the book leaves in its series
+		var err error
+		if seriesIDs, err = removeBookFromAllSeries(ctx, tx, id); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&book).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Model(&book).Association("Subjects").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Book{}, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityBook, book.ID, &book, nil)
+	})
+	if err != nil {
 		return err
 	}
+	invalidateWorkOf(ctx, &book)
+	invalidateSeries(ctx, seriesIDs)
+	invalidateCache(ctx, cacheKeyTagCloud)
+	invalidateFeeds(ctx)
 
 	// Invalidate cache
 	cacheKey := cacheKeyBookPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	invalidateCache(ctx, cacheKey, cacheKeyBooksAll)
+
+	return nil
+}
+
+// replaceBookLabels sets the tags and subjects of a book to the ones it carries,
+// creating any tag that does not exist yet.
+func replaceBookLabels(tx *gorm.DB, book *models.Book) error {
+	tags, err := resolveTags(tx, book.Tags)
+	if err != nil {
+		return err
 	}
-	if err := config.RedisClient.Del(ctx, cacheKeyBooksAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	subjects, err := resolveSubjects(tx, book.Subjects)
+	if err != nil {
+		return err
 	}
 
+	if err := tx.Model(book).Association("Tags").Replace(tags); err != nil {
+		return err
+	}
+	if err := tx.Model(book).Association("Subjects").Replace(subjects); err != nil {
+		return err
+	}
+
+	book.Tags = tags
+	book.Subjects = subjects
 	return nil
 }
+
+// invalidateWorkOf drops the cached copy of the work the book is an edition of, if any.
+func invalidateWorkOf(ctx context.Context, book *models.Book) {
+	if book.WorkID == nil {
+		return
+	}
+	invalidateCache(ctx, cacheKeyWorkPrefix+strconv.FormatUint(uint64(*book.WorkID), 10), cacheKeyWorksAll)
+}
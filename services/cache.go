@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	config "gin-books-api/configs"
+)
+
+// invalidateCache deletes the given keys from the Redis cache.
+// Failures are logged and otherwise ignored. The keys are deleted even if ctx has been
+// cancelled, since a change committed by a request whose client went away must not
+// leave stale data in the cache.
+func invalidateCache(ctx context.Context, keys ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := config.RedisClient.Del(ctx, key).Err(); err != nil {
+			slog.ErrorContext(ctx, "Failed to invalidate cache", "key", key, "error", err)
+		}
+	}
+}
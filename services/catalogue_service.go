@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+)
+
+// BookFilter selects a page of books for the catalogue. Zero values match everything.
+type BookFilter struct {
+	Title       string // Case-insensitive substring of the title
+	AuthorID    uint
+	PublisherID uint
+	CategoryID  uint // Includes the books of its subcategories
+	Newest      bool // Most recently added first instead of by title
+	Page        int
+	PageSize    int
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// FetchBookPage fetches a page of books with their author, publisher and subjects, and
+// the total number of matches.
+func FetchBookPage(ctx context.Context, filter BookFilter) ([]models.Book, int64, error) {
+	query := config.GetDB().WithContext(ctx).Model(&models.Book{})
+	if filter.Title != "" {
+		query = query.Where("title ILIKE ?", "%"+likeEscaper.Replace(filter.Title)+"%")
+	}
+	if filter.AuthorID != 0 {
+		query = query.Where("author_id = ?", filter.AuthorID)
+	}
+	if filter.PublisherID != 0 {
+		query = query.Where("publisher_id = ?", filter.PublisherID)
+	}
+	if filter.CategoryID != 0 {
+		categoryIDs, err := subtreeIDs(config.GetDB().WithContext(ctx), "categories", filter.CategoryID)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "title ASC, id ASC"
+	if filter.Newest {
+		order = "created_at DESC, id DESC"
+	}
+	var books []models.Book
+	if err := query.
+		Preload("Author").
+		Preload("Publisher").
+		Preload("Subjects").
+		Order(order).
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&books).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return books, total, nil
+}
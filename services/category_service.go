@@ -2,12 +2,15 @@ package services
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strconv"
 
+	"gin-books-api/apperrors"
 	"gin-books-api/cache"
 	config "gin-books-api/configs"
 	"gin-books-api/models"
+
+	"gorm.io/gorm"
 )
 
 const (
@@ -15,81 +18,259 @@ const (
 	cacheKeyCategoryPrefix = "category_"
 )
 
-// FetchCategoriesFromDB fetches categories from the database, caches them, and returns the result.
+// Strategies for deleting a category that still has subcategories or books.
+const (
+	CategoryDeleteReparent = "reparent" // Move subcategories and books to the parent category
+	CategoryDeleteRefuse   = "refuse"   // Only delete categories without subcategories or books
+)
+
+// ErrCategoryNotEmpty is returned when deleting a category that still has subcategories or books
+// with the refuse strategy.
+var ErrCategoryNotEmpty = apperrors.Conflict("category_not_empty", "Category still has subcategories or books")
+
+// ErrCategoryHasNoParent is returned when reparenting the books of a top-level category,
+// which would leave them without a category.
+var ErrCategoryHasNoParent = apperrors.Conflict("category_has_no_parent", "Top-level category has no parent to move its books to")
+
+// ErrUnknownDeleteStrategy is returned for a delete strategy other than reparent or refuse.
+var ErrUnknownDeleteStrategy = apperrors.BadRequest("invalid_strategy", "The strategy parameter must be either reparent or refuse")
+
+// FetchCategoriesFromDB fetches categories from the database as a tree of top-level
+// categories and their subcategories, caches it, and returns the result.
 func FetchCategoriesFromDB(ctx context.Context, cacheKey string) ([]models.Category, error) {
-    var categories []models.Category
-    if err := config.GetDB().Preload("Books").Find(&categories).Error; err != nil {
-        log.Printf("Database error while fetching categories: %v", err)
-        return nil, err
-    }
-
-    // Cache the complete list of categories
-    if err := cache.SetCachedData(ctx, cacheKey, categories, cache.CacheExpiration); err != nil {
-        log.Printf("Redis SET error for key %s: %v", cacheKey, err)
-        // Proceed without caching
-    }
-
-    return categories, nil
+	var categories []models.Category
+	if err := config.GetDB().WithContext(ctx).Preload("Books").Order("name ASC").Find(&categories).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching categories", "error", err)
+		return nil, err
+	}
+
+	tree := buildCategoryTree(categories)
+
+	// Cache the complete category tree
+	if err := cache.SetCachedData(ctx, cacheKey, tree, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return tree, nil
 }
 
-// FetchCategoryFromDB fetches a single category from the database, caches it, and returns the result.
+// FetchCategoryFromDB fetches a single category with its direct subcategories, caches it, and returns the result.
 func FetchCategoryFromDB(ctx context.Context, cacheKey string, id int) (*models.Category, error) {
-    var category models.Category
-    if result := config.GetDB().Preload("Books").First(&category, id); result.Error != nil {
-        return nil, result.Error
-    }
+	var category models.Category
+	if result := config.GetDB().WithContext(ctx).Preload("Books").Preload("Children").First(&category, id); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, category, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Failed to cache category", "error", err)
+	}
+
+	return &category, nil
+}
+
+// FetchCategoryBooks fetches the books of a category and of all of its subcategories.
+func FetchCategoryBooks(ctx context.Context, id int) ([]models.Book, error) {
+	if err := config.GetDB().WithContext(ctx).Select("id").First(&models.Category{}, id).Error; err != nil {
+		return nil, err
+	}
+
+	categoryIDs, err := subtreeIDs(config.GetDB().WithContext(ctx), "categories", uint(id))
+	if err != nil {
+		return nil, err
+	}
 
-    if err := cache.SetCachedData(ctx, cacheKey, category, cache.CacheExpiration); err != nil {
-        log.Printf("Failed to cache category: %v", err)
-    }
+	var books []models.Book
+	if err := withBookRelations(config.GetDB().WithContext(ctx)).Where("category_id IN ?", categoryIDs).Find(&books).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching books of category", "id", id, "error", err)
+		return nil, err
+	}
 
-    return &category, nil
+	return books, nil
 }
 
 func CreateCategory(ctx context.Context, category *models.Category) error {
-	if err := config.GetDB().Create(category).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkParent(tx, "categories", 0, category.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Omit("Children", "Books").Create(category).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityCategory, category.ID, nil, category)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
-	if err := config.RedisClient.Del(ctx, cacheKeyCategoriesAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
+	invalidateCache(ctx, cacheKeyCategoriesAll)
+	invalidateCategory(ctx, category.ParentID)
 
 	return nil
 }
 
+// UpdateCategory updates an existing category by its ID. Moving a category under itself
+// or one of its subcategories is rejected with ErrHierarchyCycle.
 func UpdateCategory(ctx context.Context, id int, category *models.Category) error {
 	category.ID = uint(id)
-	if err := config.GetDB().Save(category).Error; err != nil {
+	var previous models.Category
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := checkParent(tx, "categories", category.ID, category.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Omit("Children", "Books").Save(category).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityCategory, category.ID, &previous, category)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
 	cacheKey := cacheKeyCategoryPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	invalidateCache(ctx, cacheKey, cacheKeyCategoriesAll)
+	invalidateCategory(ctx, previous.ParentID)
+	invalidateCategory(ctx, category.ParentID)
+
+	return nil
+}
+
+// PatchCategory writes only the given columns of category to the category with the given ID and
+// reloads category from the stored row.
+func PatchCategory(ctx context.Context, id int, category *models.Category, columns []string) error {
+	category.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(category, id).Error
 	}
-	if err := config.RedisClient.Del(ctx, cacheKeyCategoriesAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	var previous models.Category
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if patches(columns, "parent_id") {
+			if err := checkParent(tx, "categories", category.ID, category.ParentID); err != nil {
+				return err
+			}
+		}
+		if err := updateColumns(tx, category, columns); err != nil {
+			return err
+		}
+		if err := tx.First(category, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityCategory, category.ID, &previous, category)
+	})
+	if err != nil {
+		return err
 	}
 
+	// Invalidate cache
+	cacheKey := cacheKeyCategoryPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyCategoriesAll)
+	invalidateCategory(ctx, previous.ParentID)
+	invalidateCategory(ctx, category.ParentID)
+
 	return nil
 }
 
-func DeleteCategory(ctx context.Context, id int) error {
-	if err := config.GetDB().Delete(&models.Category{}, id).Error; err != nil {
+// DeleteCategory deletes a category by its ID. A category that still has subcategories
+// or books is either refused (CategoryDeleteRefuse) or has them moved to its parent
+// (CategoryDeleteReparent), so that no book is left without a category.
+func DeleteCategory(ctx context.Context, id int, strategy string) error {
+	if strategy != CategoryDeleteReparent && strategy != CategoryDeleteRefuse {
+		return ErrUnknownDeleteStrategy
+	}
+
+	var category models.Category
+	var bookIDs []uint
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+
+		var children int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Book{}).Where("category_id = ?", id).Pluck("id", &bookIDs).Error; err != nil {
+			return err
+		}
+
+		if children > 0 || len(bookIDs) > 0 {
+			if strategy == CategoryDeleteRefuse {
+				return ErrCategoryNotEmpty
+			}
+			if len(bookIDs) > 0 && category.ParentID == nil {
+				return ErrCategoryHasNoParent
+			}
+			// Each moved subcategory and book is audited as updated
+			if _, err := updateAudited(ctx, tx, AuditEntityCategory, func(c *models.Category) uint { return c.ID },
+				"parent_id", category.ParentID, "parent_id = ?", id); err != nil {
+				return err
+			}
+			if _, err := updateAudited(ctx, tx, AuditEntityBook, func(b *models.Book) uint { return b.ID },
+				"category_id", category.ParentID, "category_id = ?", id); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&models.Category{}, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityCategory, category.ID, &category, nil)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
 	cacheKey := cacheKeyCategoryPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
-	if err := config.RedisClient.Del(ctx, cacheKeyCategoriesAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	invalidateCache(ctx, cacheKey, cacheKeyCategoriesAll)
+	invalidateCategory(ctx, category.ParentID)
+	if len(bookIDs) > 0 {
+		invalidateBooks(ctx, bookIDs)
 	}
 
 	return nil
 }
+
+// buildCategoryTree arranges a flat list of categories into a tree of top-level
+// categories, each with its subcategories attached.
+func buildCategoryTree(categories []models.Category) []models.Category {
+	roots := []models.Category{}
+	children := make(map[uint][]models.Category)
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+			if nodes[i].Children == nil {
+				nodes[i].Children = []models.Category{}
+			}
+		}
+		return nodes
+	}
+
+	return attach(roots)
+}
+
+// invalidateCategory drops the cached copy of a category, if any.
+func invalidateCategory(ctx context.Context, id *uint) {
+	if id == nil {
+		return
+	}
+	invalidateCache(ctx, cacheKeyCategoryPrefix+strconv.FormatUint(uint64(*id), 10))
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+
+	"gin-books-api/apperrors"
+	config "gin-books-api/configs"
+
+	"gorm.io/gorm"
+)
+
+// ExportFilter narrows an export the same way the query parameters of the list
+// endpoints do. Zero fields are not applied.
+type ExportFilter struct {
+	SeriesID int // Books of a series, in reading order
+	BookID   int // Reviews and loans of a book
+	UserID   int // Reviews and loans of a user
+}
+
+// exportQueries build the query of each exportable entity. Every query returns flat
+// rows, with the names of related rows joined in.
+var exportQueries = map[string]func(db *gorm.DB, filter ExportFilter) *gorm.DB{
+	"books": func(db *gorm.DB, filter ExportFilter) *gorm.DB {
+		query := db.Table("books").
+			Select("books.id, books.title, books.isbn, books.description, books.published_year, " +
+				"books.language, books.format, books.availability, " +
+				"authors.name AS author, publishers.name AS publisher, categories.name AS category").
+			Joins("LEFT JOIN authors ON authors.id = books.author_id").
+			Joins("LEFT JOIN publishers ON publishers.id = books.publisher_id").
+			Joins("LEFT JOIN categories ON categories.id = books.category_id")
+		if filter.SeriesID != 0 {
+			return query.
+				Joins("JOIN series_entries ON series_entries.book_id = books.id AND series_entries.series_id = ?", filter.SeriesID).
+				Order("series_entries.position")
+		}
+		return query.Order("books.id")
+	},
+	"reviews": func(db *gorm.DB, filter ExportFilter) *gorm.DB {
+		query := db.Table("reviews").
+			Select("reviews.id, reviews.book_id, books.title AS book_title, " +
+				"reviews.user_id, users.username, reviews.rating, reviews.comment").
+			Joins("LEFT JOIN books ON books.id = reviews.book_id").
+			Joins("LEFT JOIN users ON users.id = reviews.user_id")
+		return filterByBookAndUser(query, "reviews", filter).Order("reviews.id")
+	},
+	"loans": func(db *gorm.DB, filter ExportFilter) *gorm.DB {
+		query := db.Table("borrowed_books").
+			Select("borrowed_books.id, borrowed_books.book_id, books.title AS book_title, " +
+				"borrowed_books.user_id, users.username, borrowed_books.borrowed_at, borrowed_books.due_date").
+			Joins("LEFT JOIN books ON books.id = borrowed_books.book_id").
+			Joins("LEFT JOIN users ON users.id = borrowed_books.user_id")
+		return filterByBookAndUser(query, "borrowed_books", filter).Order("borrowed_books.id")
+	},
+}
+
+func filterByBookAndUser(query *gorm.DB, table string, filter ExportFilter) *gorm.DB {
+	if filter.BookID != 0 {
+		query = query.Where(table+".book_id = ?", filter.BookID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where(table+".user_id = ?", filter.UserID)
+	}
+	return query
+}
+
+// ErrUnknownExport is returned for an entity that cannot be exported.
+var ErrUnknownExport = apperrors.NotFound("unknown_export", "Only books, reviews and loans can be exported")
+
+// IsExportable reports whether entity can be exported.
+func IsExportable(entity string) bool {
+	_, ok := exportQueries[entity]
+	return ok
+}
+
+// ExportRows streams the rows of entity from a database cursor. It calls header once
+// with the column names, then row for every row, so that the result is never held in
+// memory. Values are the ones returned by the driver; row may reuse its slice.
+func ExportRows(ctx context.Context, entity string, filter ExportFilter,
+	header func(columns []string) error, row func(values []interface{}) error) error {
+	query, ok := exportQueries[entity]
+	if !ok {
+		return ErrUnknownExport
+	}
+
+	rows, err := query(config.GetDB().WithContext(ctx), filter).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if err := header(columns); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		if err := row(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
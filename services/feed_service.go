@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+)
+
+const (
+	// cacheKeyFeedPrefix starts the cache keys of all feeds, which are dropped together
+	// whenever books, authors or publishers change.
+	cacheKeyFeedPrefix = "feed_"
+
+	// FeedSize is the number of books a feed lists.
+	FeedSize = 50
+)
+
+// FeedBook is a book as listed in a feed.
+type FeedBook struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Author      string    `json:"author"`
+	AuthorID    *uint     `json:"author_id"`
+	Publisher   string    `json:"publisher"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FetchFeedBooks fetches the most recently added books selected by filter, caches them,
+// and returns the result. cacheKey must start with "feed_".
+func FetchFeedBooks(ctx context.Context, cacheKey string, filter BookFilter) ([]FeedBook, error) {
+	filter.Newest, filter.Page, filter.PageSize = true, 1, FeedSize
+	books, _, err := FetchBookPage(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching feed", "key", cacheKey, "error", err)
+		return nil, err
+	}
+
+	feed := make([]FeedBook, len(books))
+	for i, book := range books {
+		feed[i] = FeedBook{
+			ID:          book.ID,
+			Title:       book.Title,
+			Description: book.Description,
+			Author:      book.Author.Name,
+			AuthorID:    book.AuthorID,
+			Publisher:   book.Publisher.Name,
+			CreatedAt:   book.CreatedAt,
+			UpdatedAt:   book.UpdatedAt,
+		}
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, feed, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return feed, nil
+}
+
+// invalidateFeeds drops every cached feed. Feeds are keyed by category, author and
+// publisher, so rather than working out which ones a change affects, all are dropped
+// whenever the book list is, and whenever an author or publisher, whose names the
+// feeds list, is changed or deleted.
+func invalidateFeeds(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	iter := config.RedisClient.Scan(ctx, 0, cacheKeyFeedPrefix+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate cache", "key", cacheKeyFeedPrefix+"*", "error", err)
+		return
+	}
+	invalidateCache(ctx, keys...)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// healthCheckTimeout bounds each check of a dependency, so that a hung dependency
+// fails the readiness probe rather than outlasting it.
+const healthCheckTimeout = 2 * time.Second
+
+// readinessTTL is how long the outcome of the checks is reused, so that however often
+// /readyz is requested, the dependencies are checked at most once a second.
+const readinessTTL = time.Second
+
+// Readiness statuses of an instance.
+const (
+	ReadinessReady       = "ready"       // Every dependency is up
+	ReadinessDegraded    = "degraded"    // Redis is down; requests are served without the cache
+	ReadinessUnavailable = "unavailable" // The database is down or its schema is behind
+	ReadinessDraining    = "draining"    // The instance is shutting down
+)
+
+// Statuses of a dependency.
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// Readiness is whether an instance should receive traffic, with the state of each of
+// its dependencies.
+type Readiness struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
+
+// Ready reports whether the instance should receive traffic: it may serve requests
+// without the cache, but not without the database.
+func (r Readiness) Ready() bool {
+	return r.Status == ReadinessReady || r.Status == ReadinessDegraded
+}
+
+// DependencyHealth is the state of one dependency.
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Version   *int    `json:"version,omitempty"`  // Schema version found, for migrations
+	Expected  *int    `json:"expected,omitempty"` // Schema version of the models, for migrations
+}
+
+// draining is set once the instance starts shutting down.
+var draining atomic.Bool
+
+// readinessChecks is the outcome of the latest checks of the dependencies.
+var readinessChecks struct {
+	sync.Mutex
+	checked      time.Time
+	dependencies map[string]DependencyHealth
+}
+
+// dependencyCheck checks a dependency. Its error is logged; the readiness probe, which
+// anyone may request, reports only the message.
+type dependencyCheck struct {
+	check   func(context.Context, *DependencyHealth) error
+	message string
+}
+
+// Drain marks the instance as shutting down, so that readiness fails and load
+// balancers stop sending it requests, while those under way are finished.
+func Drain() {
+	draining.Store(true)
+}
+
+// RecordSchemaVersion records that the schema was migrated to models.SchemaVersion.
+func RecordSchemaVersion(ctx context.Context) error {
+	migration := models.SchemaMigration{Version: models.SchemaVersion, MigratedAt: time.Now()}
+	return config.GetDB().WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&migration).Error
+}
+
+// CheckReadiness pings the database and Redis, and checks that the schema has been
+// migrated to the version of the models. The checks run concurrently, each within
+// healthCheckTimeout, and their outcome is reused for readinessTTL; requests arriving
+// while they run wait for them.
+func CheckReadiness(ctx context.Context) Readiness {
+	readinessChecks.Lock()
+	if time.Since(readinessChecks.checked) >= readinessTTL {
+		// The outcome is shared, so a client hanging up must not cut the checks short
+		readinessChecks.dependencies = checkDependencies(context.WithoutCancel(ctx))
+		readinessChecks.checked = time.Now()
+	}
+	dependencies := readinessChecks.dependencies
+	readinessChecks.Unlock()
+
+	readiness := Readiness{Status: ReadinessReady, Dependencies: dependencies}
+	switch {
+	case draining.Load():
+		readiness.Status = ReadinessDraining
+	case dependencies["database"].Status == DependencyDown || dependencies["migrations"].Status == DependencyDown:
+		readiness.Status = ReadinessUnavailable
+	case dependencies["redis"].Status == DependencyDown:
+		readiness.Status = ReadinessDegraded
+	}
+	return readiness
+}
+
+// checkDependencies runs the checks of the dependencies concurrently and returns the
+// state of each.
+func checkDependencies(ctx context.Context) map[string]DependencyHealth {
+	checks := map[string]dependencyCheck{
+		"database":   {pingDatabase, "The database is unreachable"},
+		"redis":      {pingRedis, "Redis is unreachable"},
+		"migrations": {checkSchemaVersion, "The schema is not migrated to the version of the models"},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	dependencies := make(map[string]DependencyHealth, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			health := DependencyHealth{Status: DependencyUp}
+			start := time.Now()
+			err := check.check(ctx, &health)
+			health.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+			if err != nil {
+				slog.WarnContext(ctx, "Readiness check failed", "dependency", name, "error", err)
+				health.Status, health.Error = DependencyDown, check.message
+			}
+			mu.Lock()
+			dependencies[name] = health
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return dependencies
+}
+
+func pingDatabase(ctx context.Context, _ *DependencyHealth) error {
+	db, err := config.GetDB().DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
+func pingRedis(ctx context.Context, _ *DependencyHealth) error {
+	if config.RedisClient == nil {
+		return errors.New("redis is not configured")
+	}
+	return config.RedisClient.Ping(ctx).Err()
+}
+
+// checkSchemaVersion fails while the schema is behind the models, e.g. after a failed
+// migration. A schema ahead of the models is fine: during a rolling deployment, the
+// instances still running the previous release keep serving on the migrated schema.
+func checkSchemaVersion(ctx context.Context, health *DependencyHealth) error {
+	expected := models.SchemaVersion
+	health.Expected = &expected
+
+	var latest sql.NullInt64
+	if err := config.GetDB().WithContext(ctx).Model(&models.SchemaMigration{}).
+		Select("MAX(version)").Scan(&latest).Error; err != nil {
+		return err
+	}
+	if !latest.Valid {
+		return errors.New("the schema has not been migrated")
+	}
+	version := int(latest.Int64)
+	health.Version = &version
+	if version < expected {
+		return fmt.Errorf("the schema is at version %d, behind version %d of the models", version, expected)
+	}
+	return nil
+}
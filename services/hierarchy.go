@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+
+	"gin-books-api/apperrors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrHierarchyCycle is returned when moving a node under itself or one of its descendants.
+var ErrHierarchyCycle = apperrors.Conflict("hierarchy_cycle", "A node cannot be moved under itself or one of its descendants")
+
+// ErrUnknownParent is returned when a node is placed under a parent that does not exist.
+var ErrUnknownParent = apperrors.Validation(apperrors.FieldError{Field: "parent_id", Code: "not_found", Message: "does not exist"})
+
+// subtreeIDs returns the ID of the node and of all of its descendants in a
+// self-referencing table with a parent_id column, walking it with a recursive CTE.
+// UNION rather than UNION ALL stops the walk should the table already hold a cycle.
+func subtreeIDs(db *gorm.DB, table string, id uint) ([]uint, error) {
+	query := fmt.Sprintf(`
+WITH RECURSIVE subtree AS (
+	SELECT id FROM %[1]s WHERE id = ?
+	UNION
+	SELECT child.id FROM %[1]s child JOIN subtree ON child.parent_id = subtree.id
+)
+SELECT id FROM subtree`, table)
+
+	var ids []uint
+	if err := db.Raw(query, id).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// checkParent verifies that parentID exists and that placing node id under it keeps the
+// hierarchy a tree. Pass id 0 for a node that is being created. Call it in the
+// transaction that writes the node: it locks the parent against deletion until the
+// transaction ends, and for a move it takes a lock on the whole table's hierarchy, so
+// that concurrent moves run one after the other and each one checks the tree left by
+// the others. Locking rows alone would not do: moving P under Q and S under T lock
+// disjoint rows, yet together they close the cycle P→Q→S→T→P if S is above Q and P
+// above T.
+func checkParent(tx *gorm.DB, table string, id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+
+	if id != 0 {
+		// Released when the transaction ends; other writes to the table go on
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "hierarchy_"+table).Error; err != nil {
+			return err
+		}
+	}
+
+	var found []uint
+	err := tx.Table(table).Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("id = ?", *parentID).Pluck("id", &found).Error
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return ErrUnknownParent
+	}
+	if id == 0 {
+		return nil
+	}
+
+	subtree, err := subtreeIDs(tx, table, id)
+	if err != nil {
+		return err
+	}
+	for _, descendant := range subtree {
+		if descendant == *parentID {
+			return ErrHierarchyCycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"gin-books-api/apperrors"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Outcomes of an imported row.
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+	ImportMatched = "matched" // For authors, publishers, categories and subjects
+)
+
+// importBatchSize is the number of rows committed together by an import.
+const importBatchSize = 500
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// ImportRow is one decoded row of a book import. Related rows are given by name and
+// are found, ignoring case, or created.
+type ImportRow struct {
+	Row            int                    // 1-based position of the row in the input
+	Book           models.Book            // Book fields and tags of the row
+	Author         string                 // Name of the author, as "Given Family"
+	Contributors   []string               // Further authors; books have one author, so they are only reported
+	Publisher      string                 // Name of the publisher
+	PublisherPlace string                 // Address of a created publisher
+	Category       string                 // Name of the category
+	Subjects       []string               // Names of subject headings; none keeps those of an updated book
+	Errors         []apperrors.FieldError // Set when the row could not be decoded or is invalid
+}
+
+// ImportedEntity is a row related to an imported book, and whether the import found it
+// or created it.
+type ImportedEntity struct {
+	ID     uint   `json:"id,omitempty"` // Not set for rows created by a dry run
+	Name   string `json:"name"`
+	Status string `json:"status"` // ImportCreated or ImportMatched
+}
+
+// ImportRowResult is the outcome of one row of an import.
+type ImportRowResult struct {
+	Row    int                    `json:"row"`
+	Status string                 `json:"status"`
+	ID     uint                   `json:"id,omitempty"` // ID of the book, not set for books created by a dry run
+	Title  string                 `json:"title,omitempty"`
+	Reason string                 `json:"reason,omitempty"`
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+
+	Authors      []ImportedEntity `json:"authors,omitempty"`
+	Contributors []string         `json:"contributors,omitempty"` // Further authors of the row, not imported
+	Publisher    *ImportedEntity  `json:"publisher,omitempty"`
+	Category     *ImportedEntity  `json:"category,omitempty"`
+	Subjects     []ImportedEntity `json:"subjects,omitempty"`
+}
+
+// forgetCreatedIDs drops the IDs of rows created by a dry run, which were rolled back.
+func (r *ImportRowResult) forgetCreatedIDs() {
+	if r.Status == ImportCreated {
+		r.ID = 0
+	}
+	for _, entities := range [][]ImportedEntity{r.Authors, r.Subjects} {
+		for i := range entities {
+			if entities[i].Status == ImportCreated {
+				entities[i].ID = 0
+			}
+		}
+	}
+	for _, entity := range []*ImportedEntity{r.Publisher, r.Category} {
+		if entity != nil && entity.Status == ImportCreated {
+			entity.ID = 0
+		}
+	}
+}
+
+// ImportReport summarizes an import.
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+	Error   *ImportError      `json:"error,omitempty"`
+}
+
+// ImportError is the error that stopped an import after the rows of its report were
+// committed; the rest of the input was not imported.
+type ImportError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r *ImportReport) add(result ImportRowResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportSkipped:
+		r.Skipped++
+	case ImportFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// ImportBooks creates or updates a book for every row returned by next, until next
+// returns io.EOF. A row updates the book with the same ISBN, with or without hyphens,
+// or, without an ISBN, the book with the same title and author; rows that would change
+// nothing are skipped.
+// Rows are committed in batches, and each row is written in its own savepoint, so that
+// a failing row is reported without undoing the others. If next returns another error,
+// the rows read so far are committed and that error is returned. If a batch fails, the
+// report of the batches committed before it is returned with the error.
+//
+// A dry run writes every batch in a transaction that is rolled back, so that the report
+// shows what the import would do. Since batches do not see those before them, a row
+// matching a row created by an earlier batch of a dry run reports it created again.
+func ImportBooks(ctx context.Context, next func() (*ImportRow, error), dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Rows: []ImportRowResult{}}
+
+	var readErr, batchErr error
+	for done := false; !done; {
+		var updated []models.Book
+		var updatedIDs []uint
+		committed := *report
+		err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for count := 0; count < importBatchSize; count++ {
+				row, err := next()
+				if err != nil {
+					done = true
+					if err != io.EOF {
+						readErr = err
+					}
+					break
+				}
+
+				result, book := importBookRow(ctx, tx, row)
+				if dryRun {
+					result.forgetCreatedIDs()
+				}
+				if result.Status == ImportUpdated {
+					updated = append(updated, book)
+					updatedIDs = append(updatedIDs, book.ID)
+				}
+				report.add(result)
+			}
+			if dryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errDryRun) {
+			// The rows of the failed batch were rolled back
+			*report = committed
+			batchErr = err
+			break
+		}
+
+		if !dryRun {
+			invalidateBooks(ctx, updatedIDs)
+			for i := range updated {
+				invalidateWorkOf(ctx, &updated[i])
+			}
+		}
+	}
+
+	if !dryRun && report.Created+report.Updated > 0 {
+		invalidateCache(ctx, cacheKeyBooksAll, cacheKeyAuthorsAll, cacheKeyPublishersAll, cacheKeyCategoriesAll, cacheKeyTagCloud)
+		invalidateFeeds(ctx)
+	}
+	if batchErr != nil {
+		return report, batchErr
+	}
+	return report, readErr
+}
+
+// importBookRow writes one row in a savepoint and returns its outcome with the book
+// as written.
+func importBookRow(ctx context.Context, tx *gorm.DB, row *ImportRow) (ImportRowResult, models.Book) {
+	result := ImportRowResult{Row: row.Row, Title: row.Book.Title, Contributors: row.Contributors}
+	if len(row.Errors) > 0 {
+		result.Status = ImportFailed
+		result.Reason = "Validation failed"
+		result.Errors = row.Errors
+		return result, models.Book{}
+	}
+
+	// Normalized as it is stored, to match it and to compare the rows
+	book := row.Book
+	book.ISBN = models.NormalizeISBN(book.ISBN)
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		author, err := findOrCreateAuthor(ctx, tx, row.Author)
+		if err != nil {
+			return err
+		}
+		if author != nil {
+			book.AuthorID = &author.ID
+			result.Authors = append(result.Authors, *author)
+		}
+		publisher, err := findOrCreatePublisher(ctx, tx, row.Publisher, row.PublisherPlace)
+		if err != nil {
+			return err
+		}
+		if publisher != nil {
+			book.PublisherID = &publisher.ID
+			result.Publisher = publisher
+		}
+		category, err := findOrCreateCategory(ctx, tx, row.Category)
+		if err != nil {
+			return err
+		}
+		if category != nil {
+			book.CategoryID = &category.ID
+			result.Category = category
+		}
+		var subjects []models.Subject
+		for _, name := range row.Subjects {
+			subject, err := findOrCreateSubject(ctx, tx, name)
+			if err != nil {
+				return err
+			}
+			subjects = append(subjects, models.Subject{ID: subject.ID})
+			result.Subjects = append(result.Subjects, *subject)
+		}
+		book.Subjects = subjects
+
+		var previous models.Book
+		query := tx.Preload("Tags").Preload("Subjects")
+		if book.ISBN != "" {
+			query = query.Where("isbn = ?", book.ISBN)
+		} else {
+			query = query.Where("LOWER(title) = LOWER(?) AND author_id IS NOT DISTINCT FROM ?", book.Title, book.AuthorID)
+		}
+		err = query.Order("id").First(&previous).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Omit("Tags", "Subjects").Create(&book).Error; err != nil {
+				return err
+			}
+			if err := replaceBookLabels(tx, &book); err != nil {
+				return err
+			}
+			result.Status = ImportCreated
+			return recordAudit(ctx, tx, AuditActionCreate, AuditEntityBook, book.ID, nil, &book)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Keep what the import does not carry: the work, the timestamps and, for rows
+		// without them, the tags and subjects
+		book.ID = previous.ID
+		book.WorkID = previous.WorkID
+		book.CreatedAt, book.UpdatedAt = previous.CreatedAt, previous.UpdatedAt
+		if len(book.Tags) == 0 {
+			book.Tags = previous.Tags
+		} else if book.Tags, err = resolveTags(tx, book.Tags); err != nil {
+			return err
+		}
+		if len(book.Subjects) == 0 {
+			book.Subjects = previous.Subjects
+		} else if book.Subjects, err = resolveSubjects(tx, book.Subjects); err != nil {
+			return err
+		}
+		sortTags(previous.Tags)
+		sortTags(book.Tags)
+		sortSubjects(previous.Subjects)
+		sortSubjects(book.Subjects)
+
+		diff, err := auditDiff(&previous, &book)
+		if err != nil {
+			return err
+		}
+		if string(diff) == "{}" {
+			result.Status = ImportSkipped
+			result.Reason = "Book is unchanged"
+			return nil
+		}
+		if err := tx.Omit("Tags", "Subjects").Save(&book).Error; err != nil {
+			return err
+		}
+		if err := replaceBookLabels(tx, &book); err != nil {
+			return err
+		}
+		result.Status = ImportUpdated
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityBook, book.ID, &previous, &book)
+	})
+	if err != nil {
+		problem := apperrors.FromDB(err, "book")
+		failed := ImportRowResult{Row: row.Row, Title: row.Book.Title, Contributors: row.Contributors, Status: ImportFailed}
+		failed.Reason = problem.Message
+		failed.Errors = problem.Details
+		return failed, models.Book{}
+	}
+
+	result.ID = book.ID
+	return result, book
+}
+
+// findOrCreateAuthor finds the author with the given name, ignoring case and whether it
+// is stored as "Given Family" or "Family, Given", and creates the author if there is
+// none. An empty name returns nil.
+func findOrCreateAuthor(ctx context.Context, tx *gorm.DB, name string) (*ImportedEntity, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return nil, nil
+	}
+	var author models.Author
+	err := tx.Where("LOWER(name) IN (LOWER(?), LOWER(?))", name, invertedName(name)).Order("id").First(&author).Error
+	if err == nil {
+		return &ImportedEntity{ID: author.ID, Name: author.Name, Status: ImportMatched}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	author = models.Author{Name: name}
+	if err := tx.Create(&author).Error; err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityAuthor, author.ID, nil, &author); err != nil {
+		return nil, err
+	}
+	return &ImportedEntity{ID: author.ID, Name: author.Name, Status: ImportCreated}, nil
+}
+
+// findOrCreatePublisher finds the publisher with the given name, ignoring case, and
+// creates the publisher at address if there is none. An empty name returns nil.
+func findOrCreatePublisher(ctx context.Context, tx *gorm.DB, name, address string) (*ImportedEntity, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return nil, nil
+	}
+	var publisher models.Publisher
+	err := tx.Where("LOWER(name) = LOWER(?)", name).Order("id").First(&publisher).Error
+	if err == nil {
+		return &ImportedEntity{ID: publisher.ID, Name: publisher.Name, Status: ImportMatched}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	publisher = models.Publisher{Name: name, Address: address}
+	if err := tx.Create(&publisher).Error; err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityPublisher, publisher.ID, nil, &publisher); err != nil {
+		return nil, err
+	}
+	return &ImportedEntity{ID: publisher.ID, Name: publisher.Name, Status: ImportCreated}, nil
+}
+
+// findOrCreateCategory finds the category with the given name, ignoring case, anywhere
+// in the tree, and creates a top-level category if there is none. An empty name
+// returns nil.
+func findOrCreateCategory(ctx context.Context, tx *gorm.DB, name string) (*ImportedEntity, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return nil, nil
+	}
+	var category models.Category
+	err := tx.Where("LOWER(name) = LOWER(?)", name).Order("id").First(&category).Error
+	if err == nil {
+		return &ImportedEntity{ID: category.ID, Name: category.Name, Status: ImportMatched}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	category = models.Category{Name: name}
+	if err := tx.Omit("Children", "Books").Create(&category).Error; err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityCategory, category.ID, nil, &category); err != nil {
+		return nil, err
+	}
+	return &ImportedEntity{ID: category.ID, Name: category.Name, Status: ImportCreated}, nil
+}
+
+// findOrCreateSubject finds the subject heading with the given name, ignoring case,
+// and creates a top-level heading if there is none.
+func findOrCreateSubject(ctx context.Context, tx *gorm.DB, name string) (*ImportedEntity, error) {
+	var subject models.Subject
+	err := tx.Where("LOWER(name) = LOWER(?)", name).Order("id").First(&subject).Error
+	if err == nil {
+		return &ImportedEntity{ID: subject.ID, Name: subject.Name, Status: ImportMatched}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	subject = models.Subject{Name: name}
+	if err := tx.Omit("Children", "Books").Create(&subject).Error; err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntitySubject, subject.ID, nil, &subject); err != nil {
+		return nil, err
+	}
+	return &ImportedEntity{ID: subject.ID, Name: subject.Name, Status: ImportCreated}, nil
+}
+
+// invertedName turns "Given Family" into "Family, Given", the form library records use.
+func invertedName(name string) string {
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		return name[i+1:] + ", " + name[:i]
+	}
+	return name
+}
+
+// sortTags orders tags by ID, so that tag lists can be compared.
+func sortTags(tags []models.Tag) {
+	sort.Slice(tags, func(i, j int) bool { return tags[i].ID < tags[j].ID })
+}
+
+// sortSubjects orders subjects by ID, so that subject lists can be compared.
+func sortSubjects(subjects []models.Subject) {
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].ID < subjects[j].ID })
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	cacheKeyLoansAll   = "loans_all"
+	cacheKeyLoanPrefix = "loan_"
+)
+
+// LoanPeriod is how long a book is lent when the loan sets no due date.
+const LoanPeriod = 14 * 24 * time.Hour
+
+var (
+	// ErrBookOnLoan is returned when lending a book that is not available.
+	ErrBookOnLoan = apperrors.Conflict("book_on_loan", "The book is already on loan")
+
+	// ErrDueDateInPast is returned when lending a book until a date that has passed.
+	ErrDueDateInPast = apperrors.Validation(apperrors.FieldError{Field: "due_date", Code: "future", Message: "must be in the future"})
+)
+
+// FetchLoansFromDB fetches loans from the database, caches them, and returns the result.
+func FetchLoansFromDB(ctx context.Context, cacheKey string) ([]models.BorrowedBook, error) {
+	var loans []models.BorrowedBook
+	if err := withLoanRelations(config.GetDB().WithContext(ctx)).Find(&loans).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching loans", "error", err)
+		return nil, err
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, loans, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+	}
+
+	return loans, nil
+}
+
+// FetchLoanFromDB fetches a single loan from the database, caches it, and returns the result.
+func FetchLoanFromDB(ctx context.Context, cacheKey string, id int) (*models.BorrowedBook, error) {
+	var loan models.BorrowedBook
+	if err := withLoanRelations(config.GetDB().WithContext(ctx)).First(&loan, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, loan, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Failed to cache loan", "error", err)
+	}
+
+	return &loan, nil
+}
+
+// withLoanRelations preloads the book and borrower of loans, leaving out the password of
+// the borrower.
+func withLoanRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Book").Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "username", "email", "active")
+	})
+}
+
+// BorrowBook lends a book to a user and marks the book unavailable until it is returned.
+// A loan without a due date is due after LoanPeriod.
+func BorrowBook(ctx context.Context, loan *models.BorrowedBook) error {
+	now := time.Now()
+	if loan.DueDate.IsZero() {
+		loan.DueDate = now.Add(LoanPeriod)
+	} else if !loan.DueDate.After(now) {
+		return ErrDueDateInPast
+	}
+	loan.BorrowedAt = now
+
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the book so that two loans cannot both find it available
+		var book models.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, loan.BookID).Error; err != nil {
+			return err
+		}
+		if !book.Availability {
+			return ErrBookOnLoan
+		}
+		if _, err := updateAudited(ctx, tx, AuditEntityBook, func(b *models.Book) uint { return b.ID }, "availability", false, "id = ?", book.ID); err != nil {
+			return err
+		}
+		if err := tx.Omit("Book", "User").Create(loan).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityLoan, loan.ID, nil, loan)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateLoan(ctx, loan)
+	return nil
+}
+
+// ReturnBook ends the loan with the given ID and makes the book available again.
+func ReturnBook(ctx context.Context, id int) error {
+	var previous models.BorrowedBook
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&previous).Error; err != nil {
+			return err
+		}
+		if _, err := updateAudited(ctx, tx, AuditEntityBook, func(b *models.Book) uint { return b.ID }, "availability", true, "id = ?", previous.BookID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityLoan, previous.ID, &previous, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateLoan(ctx, &previous)
+	return nil
+}
+
+// invalidateLoan drops the cached loans and the book lent, whose availability changed.
+func invalidateLoan(ctx context.Context, loan *models.BorrowedBook) {
+	invalidateCache(ctx,
+		cacheKeyLoanPrefix+strconv.FormatUint(uint64(loan.ID), 10),
+		cacheKeyLoansAll,
+		cacheKeyBookPrefix+strconv.FormatUint(uint64(loan.BookID), 10),
+		cacheKeyBooksAll,
+	)
+}
+
+// LendingStats is the state of lending at a moment.
+type LendingStats struct {
+	ActiveLoans    int64
+	OverdueLoans   int64
+	AvailableBooks int64
+}
+
+// FetchLendingStats counts the books on loan, those of them past their due date, and
+// the books available for borrowing. Returned books have no loan, so every loan is
+// active.
+func FetchLendingStats(ctx context.Context) (LendingStats, error) {
+	var stats LendingStats
+	db := config.GetDB().WithContext(ctx)
+	if err := db.Model(&models.BorrowedBook{}).
+		Select("COUNT(*) AS active_loans, COUNT(*) FILTER (WHERE due_date < ?) AS overdue_loans", time.Now()).
+		Scan(&stats).Error; err != nil {
+		return LendingStats{}, err
+	}
+	if err := db.Model(&models.Book{}).Where("availability = ?", true).Count(&stats.AvailableBooks).Error; err != nil {
+		return LendingStats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"io"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/biblio"
+	"gin-books-api/dto"
+	"gin-books-api/marc"
+	"gin-books-api/utils"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// marcFields are the MARC fields the fields of a book come from, as reported for a record
+// that fails validation. The year and language may also come from 008.
+var marcFields = map[string]string{
+	"title":          "245$a",
+	"description":    "520$a",
+	"published_year": "260$c",
+	"isbn":           "020$a",
+	"language":       "041$a",
+	"author":         "100$a",
+	"publisher":      "260$b",
+}
+
+// MARCImportRows adapts a reader of MARC records to ImportBooks. Records that cannot be
+// parsed or fail the validation of a bulk import row, such as a record without a title
+// or with a wrong ISBN check digit, are reported as failed rows; if the input breaks
+// off, the last row says so and the import ends there.
+func MARCImportRows(reader marc.Reader) func() (*ImportRow, error) {
+	count, broken := 0, false
+	return func() (*ImportRow, error) {
+		if broken {
+			return nil, io.EOF
+		}
+		source, err := reader.Next()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		count++
+		row := &ImportRow{Row: count}
+
+		var recordErr *marc.RecordError
+		if errors.As(err, &recordErr) {
+			row.Errors = []apperrors.FieldError{{Code: "malformed_record", Message: recordErr.Error()}}
+			return row, nil
+		}
+		if err != nil {
+			broken = true
+			row.Errors = []apperrors.FieldError{{
+				Code:    "malformed_body",
+				Message: err.Error() + "; the rest of the input was not imported",
+			}}
+			return row, nil
+		}
+
+		// The record is checked as a row of the bulk import is, with its errors named
+		// after the MARC fields they come from
+		record := biblio.FromMARC(source)
+		imported := dto.BookImportRow{
+			Title:         record.Title,
+			Description:   record.Description,
+			PublishedYear: record.Year,
+			ISBN:          record.ISBN,
+			Language:      record.Language,
+			Format:        record.Format,
+			Author:        record.Author.Natural(),
+			Publisher:     record.Publisher,
+		}
+		if err := binding.Validator.ValidateStruct(&imported); err != nil {
+			row.Errors = utils.ValidationErrors(err)
+			for i := range row.Errors {
+				if field, ok := marcFields[row.Errors[i].Field]; ok {
+					row.Errors[i].Field = field
+				}
+			}
+			return row, nil
+		}
+		row.Book = imported.Model()
+		row.Author = imported.Author
+		for _, contributor := range record.Contributors {
+			row.Contributors = append(row.Contributors, contributor.Natural())
+		}
+		row.Publisher = record.Publisher
+		row.PublisherPlace = record.PublisherPlace
+		row.Subjects = record.Subjects
+		return row, nil
+	}
+}
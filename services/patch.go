@@ -0,0 +1,21 @@
+package services
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// updateColumns writes only the given columns of model, which must have its primary key
+// set. Zero values are written too, so a patch can clear a column.
+func updateColumns(tx *gorm.DB, model interface{}, columns []string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	return tx.Model(model).Select(columns).Updates(model).Error
+}
+
+// patches reports whether column is among the patched columns.
+func patches(columns []string, column string) bool {
+	return slices.Contains(columns, column)
+}
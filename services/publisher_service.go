@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strconv"
 
 	"gin-books-api/cache"
 	config "gin-books-api/configs"
 	"gin-books-api/models"
+
+	"gorm.io/gorm"
 )
 
 const (
@@ -18,14 +20,14 @@ const (
 // FetchPublishersFromDB fetches publishers from the database, caches them, and returns the result.
 func FetchPublishersFromDB(ctx context.Context, cacheKey string) ([]models.Publisher, error) {
 	var publishers []models.Publisher
-	if err := config.GetDB().Preload("Books").Find(&publishers).Error; err != nil {
-		log.Printf("Database error while fetching publishers: %v", err)
+	if err := config.GetDB().WithContext(ctx).Preload("Books").Find(&publishers).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching publishers", "error", err)
 		return nil, err
 	}
 
 	// Cache the complete list of authors
 	if err := cache.SetCachedData(ctx, cacheKey, publishers, cache.CacheExpiration); err != nil {
-		log.Printf("Redis SET error for key %s: %v", cacheKey, err)
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
 		// Proceed without caching
 	}
 
@@ -34,61 +36,110 @@ func FetchPublishersFromDB(ctx context.Context, cacheKey string) ([]models.Publi
 
 func FetchPublisherFromDB(ctx context.Context, cacheKey string, id int) (*models.Publisher, error) {
 	var publisher models.Publisher
-	if result := config.GetDB().Preload("Books").First(&publisher, id); result.Error != nil {
+	if result := config.GetDB().WithContext(ctx).Preload("Books").First(&publisher, id); result.Error != nil {
 		return nil, result.Error
 	}
 
 	if err := cache.SetCachedData(ctx, cacheKey, publisher, cache.CacheExpiration); err != nil {
-		log.Printf("Failed to cache publisher: %v", err)
+		slog.WarnContext(ctx, "Failed to cache publisher", "error", err)
 	}
 
 	return &publisher, nil
 }
 
 func CreatePublisher(ctx context.Context, publisher *models.Publisher) error {
-	if err := config.GetDB().Create(publisher).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(publisher).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityPublisher, publisher.ID, nil, publisher)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
-	if err := config.RedisClient.Del(ctx, cacheKeyPublishersAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
+	invalidateCache(ctx, cacheKeyPublishersAll)
 
 	return nil
 }
 
 func UpdatePublisher(ctx context.Context, id int, publisher *models.Publisher) error {
 	publisher.ID = uint(id)
-	if err := config.GetDB().Save(publisher).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Publisher
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(publisher).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityPublisher, publisher.ID, &previous, publisher)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
 	cacheKey := cacheKeyPublisherPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	invalidateCache(ctx, cacheKey, cacheKeyPublishersAll)
+	invalidateFeeds(ctx)
+
+	return nil
+}
+
+// PatchPublisher writes only the given columns of publisher to the publisher with the given ID and
+// reloads publisher from the stored row.
+func PatchPublisher(ctx context.Context, id int, publisher *models.Publisher, columns []string) error {
+	publisher.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(publisher, id).Error
 	}
-	if err := config.RedisClient.Del(ctx, cacheKeyPublishersAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Publisher
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := updateColumns(tx, publisher, columns); err != nil {
+			return err
+		}
+		if err := tx.First(publisher, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityPublisher, publisher.ID, &previous, publisher)
+	})
+	if err != nil {
+		return err
 	}
 
+	// Invalidate cache
+	cacheKey := cacheKeyPublisherPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyPublishersAll)
+	invalidateFeeds(ctx)
+
 	return nil
 }
 
 func DeletePublisher(ctx context.Context, id int) error {
-	if err := config.GetDB().Delete(&models.Publisher{}, id).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Publisher
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&previous).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityPublisher, previous.ID, &previous, nil)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
 	cacheKey := cacheKeyPublisherPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
-	if err := config.RedisClient.Del(ctx, cacheKeyPublishersAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
+	invalidateCache(ctx, cacheKey, cacheKeyPublishersAll)
+	invalidateFeeds(ctx)
 
 	return nil
 }
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"gin-books-api/apperrors"
+	config "gin-books-api/configs"
+	"gin-books-api/dto"
+)
+
+// ReferenceExists reports whether the row with the given ID exists in table.
+func ReferenceExists(ctx context.Context, table string, id uint) (bool, error) {
+	var count int64
+	if err := config.GetDB().WithContext(ctx).Table(table).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CheckReferences returns a validation error naming every row referenced by req that
+// does not exist, or nil if they all do.
+func CheckReferences(ctx context.Context, req dto.Request) error {
+	var details []apperrors.FieldError
+	for _, ref := range req.References() {
+		exists, err := ReferenceExists(ctx, ref.Table, ref.ID)
+		if err != nil {
+			return apperrors.FromDB(err, ref.Table)
+		}
+		if !exists {
+			details = append(details, apperrors.FieldError{
+				Field:   ref.Field,
+				Code:    "not_found",
+				Message: fmt.Sprintf("%d does not exist", ref.ID),
+			})
+		}
+	}
+	if len(details) > 0 {
+		return apperrors.Validation(details...)
+	}
+	return nil
+}
@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strconv"
 
 	"gin-books-api/cache"
 	config "gin-books-api/configs"
 	"gin-books-api/models"
+
+	"gorm.io/gorm"
 )
 
 const (
@@ -18,14 +20,14 @@ const (
 // FetchReviewsFromDB fetches reviews from the database, caches them, and returns the result.
 func FetchReviewsFromDB(ctx context.Context, cacheKey string) ([]models.Review, error) {
 	var reviews []models.Review
-	if err := config.GetDB().Preload("Book").Preload("User").Find(&reviews).Error; err != nil {
-		log.Printf("Database error while fetching reviews: %v", err)
+	if err := config.GetDB().WithContext(ctx).Preload("Book").Preload("User").Find(&reviews).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching reviews", "error", err)
 		return nil, err
 	}
 
 	// Cache the complete list of reviews
 	if err := cache.SetCachedData(ctx, cacheKey, reviews, cache.CacheExpiration); err != nil {
-		log.Printf("Redis SET error for key %s: %v", cacheKey, err)
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
 		// Proceed without caching
 	}
 
@@ -34,61 +36,133 @@ func FetchReviewsFromDB(ctx context.Context, cacheKey string) ([]models.Review,
 
 func FetchReviewFromDB(ctx context.Context, cacheKey string, id int) (*models.Review, error) {
 	var review models.Review
-	if result := config.GetDB().Preload("Book").Preload("User").First(&review, id); result.Error != nil {
+	if result := config.GetDB().WithContext(ctx).Preload("Book").Preload("User").First(&review, id); result.Error != nil {
 		return nil, result.Error
 	}
 
 	if err := cache.SetCachedData(ctx, cacheKey, review, cache.CacheExpiration); err != nil {
-		log.Printf("Failed to cache review: %v", err)
+		slog.WarnContext(ctx, "Failed to cache review", "error", err)
 	}
 
 	return &review, nil
 }
 
 func CreateReview(ctx context.Context, review *models.Review) error {
-	if err := config.GetDB().Create(review).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityReview, review.ID, nil, review)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
-	if err := config.RedisClient.Del(ctx, cacheKeyReviewsAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
+	invalidateCache(ctx, cacheKeyReviewsAll)
 
 	return nil
 }
 
 func UpdateReview(ctx context.Context, id int, review *models.Review) error {
 	review.ID = uint(id)
-	if err := config.GetDB().Save(review).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Review
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(review).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityReview, review.ID, &previous, review)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
 	cacheKey := cacheKeyReviewPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	invalidateCache(ctx, cacheKey, cacheKeyReviewsAll)
+
+	return nil
+}
+
+// PatchReview writes only the given columns of review to the review with the given ID and
+// reloads review from the stored row.
+func PatchReview(ctx context.Context, id int, review *models.Review, columns []string) error {
+	review.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(review, id).Error
 	}
-	if err := config.RedisClient.Del(ctx, cacheKeyReviewsAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Review
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := updateColumns(tx, review, columns); err != nil {
+			return err
+		}
+		if err := tx.First(review, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityReview, review.ID, &previous, review)
+	})
+	if err != nil {
+		return err
 	}
 
+	// Invalidate cache
+	cacheKey := cacheKeyReviewPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyReviewsAll)
+
 	return nil
 }
 
 func DeleteReview(ctx context.Context, id int) error {
-	if err := config.GetDB().Delete(&models.Review{}, id).Error; err != nil {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Review
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&previous).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityReview, previous.ID, &previous, nil)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate cache
 	cacheKey := cacheKeyReviewPrefix + strconv.Itoa(id)
-	if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
-	if err := config.RedisClient.Del(ctx, cacheKeyReviewsAll).Err(); err != nil {
-		log.Printf("Failed to invalidate cache: %v", err)
-	}
+	invalidateCache(ctx, cacheKey, cacheKeyReviewsAll)
 
 	return nil
 }
+
+// BookRating summarises the reviews of a book.
+type BookRating struct {
+	BookID  uint
+	Count   int64
+	Average float64
+}
+
+// FetchBookRatings returns the number and average rating of the reviews of the given
+// books. Books without reviews are left out.
+func FetchBookRatings(ctx context.Context, bookIDs []uint) (map[uint]BookRating, error) {
+	var rows []BookRating
+	if err := config.GetDB().WithContext(ctx).Model(&models.Review{}).
+		Select("book_id, COUNT(*) AS count, AVG(rating) AS average").
+		Where("book_id IN ?", bookIDs).
+		Group("book_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	ratings := make(map[uint]BookRating, len(rows))
+	for _, row := range rows {
+		ratings[row.BookID] = row
+	}
+	return ratings, nil
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	cacheKeySeriesAll    = "series_all"
+	cacheKeySeriesPrefix = "series_"
+)
+
+// ErrSeriesPositionTaken is returned when a book is placed at the position of another
+// book of the series.
+var ErrSeriesPositionTaken = apperrors.Conflict("series_position_taken", "Another book is at this position of the series")
+
+// SeriesNavigation describes where a book sits in one series, with its neighbours.
+type SeriesNavigation struct {
+	Series   models.Series       `json:"series"`
+	Position float64             `json:"position"`
+	Previous *models.SeriesEntry `json:"previous"`
+	Next     *models.SeriesEntry `json:"next"`
+}
+
+// orderedEntries preloads series entries in reading order, with their books.
+func orderedEntries(db *gorm.DB) *gorm.DB {
+	return entriesByPosition(db).Preload("Book")
+}
+
+// entriesByPosition orders series entries in reading order.
+func entriesByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC, id ASC")
+}
+
+// FetchSeriesListFromDB fetches all series with their ordered entries, caches them, and returns the result.
+func FetchSeriesListFromDB(ctx context.Context, cacheKey string) ([]models.Series, error) {
+	var series []models.Series
+	if err := config.GetDB().WithContext(ctx).Preload("Entries", orderedEntries).Find(&series).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching series", "error", err)
+		return nil, err
+	}
+
+	// Cache the complete list of series
+	if err := cache.SetCachedData(ctx, cacheKey, series, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return series, nil
+}
+
+// FetchSeriesFromDB fetches a single series with its ordered entries, caches it, and returns the result.
+func FetchSeriesFromDB(ctx context.Context, cacheKey string, id int) (*models.Series, error) {
+	var series models.Series
+	if result := config.GetDB().WithContext(ctx).Preload("Entries", orderedEntries).First(&series, id); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, series, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+	}
+
+	return &series, nil
+}
+
+// FetchBooksBySeries fetches the books of a series in reading order.
+func FetchBooksBySeries(ctx context.Context, seriesID int) ([]models.Book, error) {
+	var books []models.Book
+	if err := withBookRelations(config.GetDB().WithContext(ctx)).
+		Joins("JOIN series_entries ON series_entries.book_id = books.id").
+		Where("series_entries.series_id = ?", seriesID).
+		Order("series_entries.position ASC, series_entries.id ASC").
+		Find(&books).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching books of series", "id", seriesID, "error", err)
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// FetchBookSeriesNavigation returns, for every series the book belongs to, the previous
+// and next entries around it.
+func FetchBookSeriesNavigation(ctx context.Context, bookID int) ([]SeriesNavigation, error) {
+	if err := config.GetDB().WithContext(ctx).Select("id").First(&models.Book{}, bookID).Error; err != nil {
+		return nil, err
+	}
+
+	var entries []models.SeriesEntry
+	if err := config.GetDB().WithContext(ctx).Where("book_id = ?", bookID).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	navigation := make([]SeriesNavigation, 0, len(entries))
+	for _, entry := range entries {
+		nav := SeriesNavigation{Position: entry.Position}
+		if err := config.GetDB().WithContext(ctx).First(&nav.Series, entry.SeriesID).Error; err != nil {
+			return nil, err
+		}
+
+		// Entries are compared by position, then ID, so that entries stored at the same
+		// position before positions were unique are not skipped
+		var previous models.SeriesEntry
+		err := config.GetDB().WithContext(ctx).Preload("Book").
+			Where("series_id = ? AND (position < ? OR (position = ? AND id < ?))", entry.SeriesID, entry.Position, entry.Position, entry.ID).
+			Order("position DESC, id DESC").
+			Take(&previous).Error
+		if err == nil {
+			nav.Previous = &previous
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+
+		var next models.SeriesEntry
+		err = config.GetDB().WithContext(ctx).Preload("Book").
+			Where("series_id = ? AND (position > ? OR (position = ? AND id > ?))", entry.SeriesID, entry.Position, entry.Position, entry.ID).
+			Order("position ASC, id ASC").
+			Take(&next).Error
+		if err == nil {
+			nav.Next = &next
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+
+		navigation = append(navigation, nav)
+	}
+
+	return navigation, nil
+}
+
+// CreateSeries creates a new series and stores it in the database.
+func CreateSeries(ctx context.Context, series *models.Series) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Entries").Create(series).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntitySeries, series.ID, nil, series)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySeriesAll)
+
+	return nil
+}
+
+// UpdateSeries updates an existing series by its ID. Membership is managed through
+// AddBookToSeries and RemoveBookFromSeries.
+func UpdateSeries(ctx context.Context, id int, series *models.Series) error {
+	series.ID = uint(id)
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Series
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Entries").Save(series).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntitySeries, series.ID, &previous, series)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySeriesPrefix+strconv.Itoa(id), cacheKeySeriesAll)
+
+	return nil
+}
+
+// PatchSeries writes only the given columns of series to the series with the given ID and
+// reloads series from the stored row.
+func PatchSeries(ctx context.Context, id int, series *models.Series, columns []string) error {
+	series.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(series, id).Error
+	}
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Series
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := updateColumns(tx, series, columns); err != nil {
+			return err
+		}
+		if err := tx.First(series, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntitySeries, series.ID, &previous, series)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySeriesPrefix+strconv.Itoa(id), cacheKeySeriesAll)
+
+	return nil
+}
+
+// DeleteSeries deletes a series and its entries. The books themselves are kept.
+func DeleteSeries(ctx context.Context, id int) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var series models.Series
+		if err := tx.Preload("Entries", entriesByPosition).First(&series, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("series_id = ?", id).Delete(&models.SeriesEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Series{}, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntitySeries, series.ID, &series, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySeriesPrefix+strconv.Itoa(id), cacheKeySeriesAll)
+
+	return nil
+}
+
+// AddBookToSeries places a book at the given position in a series, or moves it there
+// if it is already a member.
+func AddBookToSeries(ctx context.Context, seriesID int, entry *models.SeriesEntry) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before models.Series
+		if err := tx.Preload("Entries", entriesByPosition).First(&before, seriesID).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id").First(&models.Book{}, entry.BookID).Error; err != nil {
+			return err
+		}
+
+		entry.SeriesID = uint(seriesID)
+		var taken int64
+		if err := tx.Model(&models.SeriesEntry{}).
+			Where("series_id = ? AND position = ? AND book_id <> ?", seriesID, entry.Position, entry.BookID).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrSeriesPositionTaken
+		}
+
+		var existing models.SeriesEntry
+		err := tx.Where("series_id = ? AND book_id = ?", seriesID, entry.BookID).First(&existing).Error
+		switch err {
+		case nil:
+			entry.ID = existing.ID
+			err = tx.Model(&existing).Update("position", entry.Position).Error
+		case gorm.ErrRecordNotFound:
+			err = tx.Omit("Book").Create(entry).Error
+		}
+		if err != nil {
+			return err
+		}
+		return recordSeriesMembership(ctx, tx, &before)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySeriesPrefix+strconv.Itoa(seriesID), cacheKeySeriesAll)
+
+	return nil
+}
+
+// RemoveBookFromSeries removes a book from a series and closes the gap it leaves.
+func RemoveBookFromSeries(ctx context.Context, seriesID, bookID int) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before models.Series
+		if err := tx.Preload("Entries", entriesByPosition).First(&before, seriesID).Error; err != nil {
+			return err
+		}
+		var entry models.SeriesEntry
+		if err := tx.Where("series_id = ? AND book_id = ?", seriesID, bookID).First(&entry).Error; err != nil {
+			return err
+		}
+		if err := removeSeriesEntry(tx, &entry); err != nil {
+			return err
+		}
+		return recordSeriesMembership(ctx, tx, &before)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySeriesPrefix+strconv.Itoa(seriesID), cacheKeySeriesAll)
+
+	return nil
+}
+
+// recordSeriesMembership audits a change to the entries of a series, given the series
+// as it was before the change.
+func recordSeriesMembership(ctx context.Context, tx *gorm.DB, before *models.Series) error {
+	var after models.Series
+	if err := tx.Preload("Entries", entriesByPosition).First(&after, before.ID).Error; err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, AuditActionUpdate, AuditEntitySeries, after.ID, before, &after)
+}
+
+// removeBookFromAllSeries removes every series entry of a book, closing the gaps,
+// audits the change to each series, and returns the IDs of the affected series.
+func removeBookFromAllSeries(ctx context.Context, tx *gorm.DB, bookID int) ([]uint, error) {
+	var entries []models.SeriesEntry
+	if err := tx.Where("book_id = ?", bookID).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	seriesIDs := make([]uint, 0, len(entries))
+	for i := range entries {
+		var before models.Series
+		if err := tx.Preload("Entries", entriesByPosition).First(&before, entries[i].SeriesID).Error; err != nil {
+			return nil, err
+		}
+		if err := removeSeriesEntry(tx, &entries[i]); err != nil {
+			return nil, err
+		}
+		if err := recordSeriesMembership(ctx, tx, &before); err != nil {
+			return nil, err
+		}
+		seriesIDs = append(seriesIDs, entries[i].SeriesID)
+	}
+
+	return seriesIDs, nil
+}
+
+// removeSeriesEntry deletes an entry. When it held a whole-number position, every later
+// entry moves up by one so that the numbering stays contiguous; fractional positions
+// (novellas and other in-between works) leave no gap behind. Nor does a whole-number
+// position whose in-between entries on either side would pass each other: with 1.5 and
+// 2.5 around 2, 2.5 would move onto 1.5.
+func removeSeriesEntry(tx *gorm.DB, entry *models.SeriesEntry) error {
+	if err := tx.Delete(entry).Error; err != nil {
+		return err
+	}
+	if entry.Position != math.Trunc(entry.Position) {
+		return nil
+	}
+
+	var before, after []float64
+	if err := tx.Model(&models.SeriesEntry{}).
+		Where("series_id = ? AND position > ? AND position < ?", entry.SeriesID, entry.Position-1, entry.Position).
+		Order("position DESC").Limit(1).
+		Pluck("position", &before).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.SeriesEntry{}).
+		Where("series_id = ? AND position > ? AND position < ?", entry.SeriesID, entry.Position, entry.Position+1).
+		Order("position ASC").Limit(1).
+		Pluck("position", &after).Error; err != nil {
+		return err
+	}
+	if len(before) > 0 && len(after) > 0 && after[0]-1 <= before[0] {
+		return nil
+	}
+
+	// Positions are unique and checked row by row as they are updated, so the later
+	// entries go through negative positions, which no entry holds, lest one land on the
+	// position another has yet to leave
+	if err := tx.Model(&models.SeriesEntry{}).
+		Where("series_id = ? AND position > ?", entry.SeriesID, entry.Position).
+		Update("position", gorm.Expr("1 - position")).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.SeriesEntry{}).
+		Where("series_id = ? AND position < 0", entry.SeriesID).
+		Update("position", gorm.Expr("-position")).Error
+}
+
+// SeparateTiedSeriesEntries spreads the entries of a series stored at the same position,
+// before positions were unique, between that position and the next, in ID order, so that
+// the unique index on series_id and position can be created. It is run before the
+// migration at startup.
+func SeparateTiedSeriesEntries(ctx context.Context) error {
+	db := config.GetDB().WithContext(ctx)
+	if !db.Migrator().HasTable(&models.SeriesEntry{}) {
+		return nil
+	}
+
+	var ties []struct {
+		SeriesID uint
+		Position float64
+	}
+	if err := db.Model(&models.SeriesEntry{}).
+		Select("series_id, position").
+		Group("series_id, position").
+		Having("COUNT(*) > 1").
+		Scan(&ties).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, tie := range ties {
+			var entries []models.SeriesEntry
+			if err := tx.Where("series_id = ? AND position = ?", tie.SeriesID, tie.Position).Order("id").Find(&entries).Error; err != nil {
+				return err
+			}
+			var following []float64
+			if err := tx.Model(&models.SeriesEntry{}).
+				Where("series_id = ? AND position > ?", tie.SeriesID, tie.Position).
+				Order("position").Limit(1).
+				Pluck("position", &following).Error; err != nil {
+				return err
+			}
+			next := tie.Position + 1
+			if len(following) > 0 {
+				next = following[0]
+			}
+
+			step := (next - tie.Position) / float64(len(entries))
+			for i := 1; i < len(entries); i++ {
+				if err := tx.Model(&entries[i]).UpdateColumn("position", tie.Position+float64(i)*step).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// invalidateSeries drops the cached copies of the given series and of the series list.
+func invalidateSeries(ctx context.Context, ids []uint) {
+	keys := []string{cacheKeySeriesAll}
+	for _, id := range ids {
+		keys = append(keys, cacheKeySeriesPrefix+strconv.FormatUint(uint64(id), 10))
+	}
+	invalidateCache(ctx, keys...)
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+)
+
+// SitemapEntry is a resource listed in the sitemap, by its path.
+type SitemapEntry struct {
+	Path         string
+	LastModified time.Time // Zero when unknown
+}
+
+// FetchSitemapEntries lists every book and author.
+func FetchSitemapEntries(ctx context.Context) ([]SitemapEntry, error) {
+	var books []models.Book
+	if err := config.GetDB().WithContext(ctx).Select("id", "updated_at").Order("id").Find(&books).Error; err != nil {
+		return nil, err
+	}
+	var authors []models.Author
+	if err := config.GetDB().WithContext(ctx).Select("id").Order("id").Find(&authors).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]SitemapEntry, 0, len(books)+len(authors))
+	for _, book := range books {
+		entries = append(entries, SitemapEntry{Path: "/books/" + strconv.FormatUint(uint64(book.ID), 10), LastModified: book.UpdatedAt})
+	}
+	for _, author := range authors {
+		entries = append(entries, SitemapEntry{Path: "/authors/" + strconv.FormatUint(uint64(author.ID), 10)})
+	}
+	return entries, nil
+}
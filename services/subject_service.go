@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownSubject is returned when a book references a subject that does not exist.
+var ErrUnknownSubject = apperrors.Validation(apperrors.FieldError{Field: "subjects", Code: "not_found", Message: "references a subject that does not exist"})
+
+const (
+	cacheKeySubjectsAll   = "subjects_all"
+	cacheKeySubjectPrefix = "subject_"
+)
+
+// FetchSubjectsFromDB fetches all subjects as a flat list, caches them, and returns the result.
+func FetchSubjectsFromDB(ctx context.Context, cacheKey string) ([]models.Subject, error) {
+	var subjects []models.Subject
+	if err := config.GetDB().WithContext(ctx).Order("name ASC").Find(&subjects).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching subjects", "error", err)
+		return nil, err
+	}
+
+	// Cache the complete list of subjects
+	if err := cache.SetCachedData(ctx, cacheKey, subjects, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return subjects, nil
+}
+
+// FetchSubjectFromDB fetches a single subject with its narrower subjects, caches it, and returns the result.
+func FetchSubjectFromDB(ctx context.Context, cacheKey string, id int) (*models.Subject, error) {
+	var subject models.Subject
+	if result := config.GetDB().WithContext(ctx).Preload("Children").First(&subject, id); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, subject, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+	}
+
+	return &subject, nil
+}
+
+// FetchSubjectBooks fetches the books filed under a subject. With descendants set,
+// books filed under any narrower subject are included too.
+func FetchSubjectBooks(ctx context.Context, id int, descendants bool) ([]models.Book, error) {
+	if err := config.GetDB().WithContext(ctx).Select("id").First(&models.Subject{}, id).Error; err != nil {
+		return nil, err
+	}
+
+	subjectIDs := []uint{uint(id)}
+	if descendants {
+		var err error
+		if subjectIDs, err = subtreeIDs(config.GetDB().WithContext(ctx), "subjects", uint(id)); err != nil {
+			return nil, err
+		}
+	}
+
+	var books []models.Book
+	if err := withBookRelations(config.GetDB().WithContext(ctx)).
+		Where("id IN (?)", config.GetDB().WithContext(ctx).Table("book_subjects").Select("book_id").Where("subject_id IN ?", subjectIDs)).
+		Find(&books).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching books of subject", "id", id, "error", err)
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// CreateSubject creates a new subject and stores it in the database.
+func CreateSubject(ctx context.Context, subject *models.Subject) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkParent(tx, "subjects", 0, subject.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Omit("Children").Create(subject).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntitySubject, subject.ID, nil, subject)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySubjectsAll)
+	invalidateSubject(ctx, subject.ParentID)
+
+	return nil
+}
+
+// UpdateSubject updates an existing subject by its ID. Moving a subject under itself
+// or one of its narrower subjects is rejected with ErrHierarchyCycle.
+func UpdateSubject(ctx context.Context, id int, subject *models.Subject) error {
+	subject.ID = uint(id)
+	var previous models.Subject
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := checkParent(tx, "subjects", subject.ID, subject.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Omit("Children").Save(subject).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntitySubject, subject.ID, &previous, subject)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySubjectPrefix+strconv.Itoa(id), cacheKeySubjectsAll)
+	invalidateSubject(ctx, previous.ParentID)
+	invalidateSubject(ctx, subject.ParentID)
+
+	return nil
+}
+
+// PatchSubject writes only the given columns of subject to the subject with the given ID and
+// reloads subject from the stored row.
+func PatchSubject(ctx context.Context, id int, subject *models.Subject, columns []string) error {
+	subject.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(subject, id).Error
+	}
+	var previous models.Subject
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if patches(columns, "parent_id") {
+			if err := checkParent(tx, "subjects", subject.ID, subject.ParentID); err != nil {
+				return err
+			}
+		}
+		if err := updateColumns(tx, subject, columns); err != nil {
+			return err
+		}
+		if err := tx.First(subject, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntitySubject, subject.ID, &previous, subject)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySubjectPrefix+strconv.Itoa(id), cacheKeySubjectsAll)
+	invalidateSubject(ctx, previous.ParentID)
+	invalidateSubject(ctx, subject.ParentID)
+
+	return nil
+}
+
+// DeleteSubject deletes a subject by its ID. Its narrower subjects move up to its parent.
+func DeleteSubject(ctx context.Context, id int) error {
+	var subject models.Subject
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&subject, id).Error; err != nil {
+			return err
+		}
+		if _, err := updateAudited(ctx, tx, AuditEntitySubject, func(s *models.Subject) uint { return s.ID }, "parent_id", subject.ParentID, "parent_id = ?", id); err != nil {
+			return err
+		}
+		if err := tx.Model(&subject).Association("Books").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Delete(&subject).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntitySubject, subject.ID, &subject, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeySubjectPrefix+strconv.Itoa(id), cacheKeySubjectsAll, cacheKeyBooksAll)
+	invalidateFeeds(ctx)
+	invalidateSubject(ctx, subject.ParentID)
+
+	return nil
+}
+
+// resolveSubjects loads the referenced subjects, failing with ErrUnknownSubject
+// when one of them does not exist.
+func resolveSubjects(tx *gorm.DB, subjects []models.Subject) ([]models.Subject, error) {
+	if len(subjects) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(subjects))
+	for _, subject := range subjects {
+		ids = append(ids, subject.ID)
+	}
+
+	var resolved []models.Subject
+	if err := tx.Where("id IN ?", ids).Find(&resolved).Error; err != nil {
+		return nil, err
+	}
+	if len(resolved) != len(uniqueIDs(ids)) {
+		return nil, ErrUnknownSubject
+	}
+	return resolved, nil
+}
+
+// invalidateSubject drops the cached copy of a subject, if any.
+func invalidateSubject(ctx context.Context, id *uint) {
+	if id == nil {
+		return
+	}
+	invalidateCache(ctx, cacheKeySubjectPrefix+strconv.FormatUint(uint64(*id), 10))
+}
+
+// uniqueIDs returns ids without duplicates, in their original order.
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const cacheKeyTagCloud = "tags_cloud"
+
+// TagUsage is a tag with the number of books carrying it. Weight buckets the count
+// from 1 (rare) to 5 (most used) for rendering a tag cloud.
+type TagUsage struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Weight int    `json:"weight"`
+}
+
+// FetchTagCloudFromDB fetches every tag with its usage count, caches the result, and returns it.
+func FetchTagCloudFromDB(ctx context.Context, cacheKey string) ([]TagUsage, error) {
+	var usages []TagUsage
+	if err := config.GetDB().WithContext(ctx).
+		Table("tags").
+		Select("tags.id, tags.name, COUNT(book_tags.book_id) AS count").
+		Joins("LEFT JOIN book_tags ON book_tags.tag_id = tags.id").
+		Group("tags.id, tags.name").
+		Order("count DESC, tags.name ASC").
+		Scan(&usages).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching tags", "error", err)
+		return nil, err
+	}
+
+	maxCount := 0
+	for _, usage := range usages {
+		if usage.Count > maxCount {
+			maxCount = usage.Count
+		}
+	}
+	for i := range usages {
+		usages[i].Weight = 1
+		if maxCount > 0 {
+			usages[i].Weight = 1 + usages[i].Count*4/maxCount
+		}
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, usages, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+	}
+
+	return usages, nil
+}
+
+// resolveTags finds or creates the named tags. Names are trimmed and lower-cased,
+// and duplicates or empty names are dropped.
+func resolveTags(tx *gorm.DB, tags []models.Tag) ([]models.Tag, error) {
+	resolved := make([]models.Tag, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		name := strings.ToLower(strings.TrimSpace(tag.Name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Tag{Name: name}).Error; err != nil {
+			return nil, err
+		}
+		var existing models.Tag
+		if err := tx.Where("name = ?", name).First(&existing).Error; err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, existing)
+	}
+	return resolved, nil
+}
@@ -1,94 +1,142 @@
 package services
 
 import (
-    "context"
-    "log"
-    "strconv"
+	"context"
+	"log/slog"
+	"strconv"
 
-    "gin-books-api/cache"
-    config "gin-books-api/configs"
-    "gin-books-api/models"
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
 )
 
 const (
-    cacheKeyUsersAll   = "users_all"
-    cacheKeyUserPrefix = "user_"
+	cacheKeyUsersAll   = "users_all"
+	cacheKeyUserPrefix = "user_"
 )
 
 // FetchUsersFromDB fetches users from the database, caches them, and returns the result.
 func FetchUsersFromDB(ctx context.Context, cacheKey string) ([]models.User, error) {
-    var users []models.User
-    if err := config.GetDB().Find(&users).Error; err != nil {
-        log.Printf("Database error while fetching users: %v", err)
-        return nil, err
-    }
-
-    // Cache the complete list of users
-    if err := cache.SetCachedData(ctx, cacheKey, users, cache.CacheExpiration); err != nil {
-        log.Printf("Redis SET error for key %s: %v", cacheKey, err)
-        // Proceed without caching
-    }
-
-    return users, nil
+	var users []models.User
+	if err := config.GetDB().WithContext(ctx).Find(&users).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching users", "error", err)
+		return nil, err
+	}
+
+	// Cache the complete list of users
+	if err := cache.SetCachedData(ctx, cacheKey, users, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return users, nil
 }
 
 func FetchUserFromDB(ctx context.Context, cacheKey string, id int) (*models.User, error) {
-    var user models.User
-    if result := config.GetDB().First(&user, id); result.Error != nil {
-        return nil, result.Error
-    }
+	var user models.User
+	if result := config.GetDB().WithContext(ctx).First(&user, id); result.Error != nil {
+		return nil, result.Error
+	}
 
-    if err := cache.SetCachedData(ctx, cacheKey, user, cache.CacheExpiration); err != nil {
-        log.Printf("Failed to cache user: %v", err)
-    }
+	if err := cache.SetCachedData(ctx, cacheKey, user, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Failed to cache user", "error", err)
+	}
 
-    return &user, nil
+	return &user, nil
 }
 
 func CreateUser(ctx context.Context, user *models.User) error {
-    if err := config.GetDB().Create(user).Error; err != nil {
-        return err
-    }
-
-    // Invalidate cache
-    if err := config.RedisClient.Del(ctx, cacheKeyUsersAll).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-
-    return nil
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	invalidateCache(ctx, cacheKeyUsersAll)
+
+	return nil
 }
 
 func UpdateUser(ctx context.Context, id int, user *models.User) error {
-    user.ID = uint(id)
-    if err := config.GetDB().Save(user).Error; err != nil {
-        return err
-    }
-
-    // Invalidate cache
-    cacheKey := cacheKeyUserPrefix + strconv.Itoa(id)
-    if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-    if err := config.RedisClient.Del(ctx, cacheKeyUsersAll).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-
-    return nil
+	user.ID = uint(id)
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.User
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityUser, user.ID, &previous, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	cacheKey := cacheKeyUserPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyUsersAll)
+
+	return nil
+}
+
+// PatchUser writes only the given columns of user to the user with the given ID and
+// reloads user from the stored row.
+func PatchUser(ctx context.Context, id int, user *models.User, columns []string) error {
+	user.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(user, id).Error
+	}
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.User
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := updateColumns(tx, user, columns); err != nil {
+			return err
+		}
+		if err := tx.First(user, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityUser, user.ID, &previous, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	cacheKey := cacheKeyUserPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyUsersAll)
+
+	return nil
 }
 
 func DeleteUser(ctx context.Context, id int) error {
-    if err := config.GetDB().Delete(&models.User{}, id).Error; err != nil {
-        return err
-    }
-
-    // Invalidate cache
-    cacheKey := cacheKeyUserPrefix + strconv.Itoa(id)
-    if err := config.RedisClient.Del(ctx, cacheKey).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-    if err := config.RedisClient.Del(ctx, cacheKeyUsersAll).Err(); err != nil {
-        log.Printf("Failed to invalidate cache: %v", err)
-    }
-
-    return nil
-}
\ No newline at end of file
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.User
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&previous).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityUser, previous.ID, &previous, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	cacheKey := cacheKeyUserPrefix + strconv.Itoa(id)
+	invalidateCache(ctx, cacheKey, cacheKeyUsersAll)
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/cache"
+	config "gin-books-api/configs"
+	"gin-books-api/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	cacheKeyWorksAll   = "works_all"
+	cacheKeyWorkPrefix = "work_"
+)
+
+// ErrSameWork is returned when a work is merged into itself.
+var ErrSameWork = apperrors.Validation(apperrors.FieldError{Field: "work_id", Code: "same_work", Message: "cannot merge a work into itself"})
+
+// ErrNoEditions is returned when a split does not name any edition of the work.
+var ErrNoEditions = apperrors.Validation(apperrors.FieldError{Field: "book_ids", Code: "not_an_edition", Message: "every book must be an edition of the work"})
+
+// WorkDetail is a work together with all of its editions and the reviews of every edition.
+type WorkDetail struct {
+	models.Work
+	Reviews       []models.Review `json:"reviews"`
+	ReviewCount   int             `json:"review_count"`
+	AverageRating float64         `json:"average_rating"`
+}
+
+// FetchWorksFromDB fetches works from the database, caches them, and returns the result.
+func FetchWorksFromDB(ctx context.Context, cacheKey string) ([]models.Work, error) {
+	var works []models.Work
+	if err := config.GetDB().WithContext(ctx).Preload("Editions").Find(&works).Error; err != nil {
+		slog.ErrorContext(ctx, "Database error while fetching works", "error", err)
+		return nil, err
+	}
+
+	// Cache the complete list of works
+	if err := cache.SetCachedData(ctx, cacheKey, works, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+		// Proceed without caching
+	}
+
+	return works, nil
+}
+
+// FetchWorkFromDB fetches a single work with its editions and the reviews of all editions,
+// caches it, and returns the result.
+func FetchWorkFromDB(ctx context.Context, cacheKey string, id int) (*WorkDetail, error) {
+	var work models.Work
+	if result := config.GetDB().WithContext(ctx).Preload("Editions").First(&work, id); result.Error != nil {
+		return nil, result.Error
+	}
+
+	// Combine the reviews of every edition
+	var reviews []models.Review
+	if err := config.GetDB().WithContext(ctx).
+		Joins("JOIN books ON books.id = reviews.book_id").
+		Where("books.work_id = ?", work.ID).
+		Preload("User").
+		Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+
+	detail := WorkDetail{Work: work, Reviews: reviews, ReviewCount: len(reviews)}
+	if len(reviews) > 0 {
+		total := 0
+		for _, review := range reviews {
+			total += review.Rating
+		}
+		detail.AverageRating = float64(total) / float64(len(reviews))
+	}
+
+	if err := cache.SetCachedData(ctx, cacheKey, detail, cache.CacheExpiration); err != nil {
+		slog.WarnContext(ctx, "Redis SET failed", "key", cacheKey, "error", err)
+	}
+
+	return &detail, nil
+}
+
+// CreateWork creates a new work and stores it in the database.
+func CreateWork(ctx context.Context, work *models.Work) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Editions").Create(work).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionCreate, AuditEntityWork, work.ID, nil, work)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeyWorksAll)
+
+	return nil
+}
+
+// UpdateWork updates an existing work by its ID. Editions are managed through
+// MergeWorks and SplitWork, or by setting work_id on a book.
+func UpdateWork(ctx context.Context, id int, work *models.Work) error {
+	work.ID = uint(id)
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Work
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Editions").Save(work).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityWork, work.ID, &previous, work)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeyWorkPrefix+strconv.Itoa(id), cacheKeyWorksAll)
+
+	return nil
+}
+
+// PatchWork writes only the given columns of work to the work with the given ID and
+// reloads work from the stored row.
+func PatchWork(ctx context.Context, id int, work *models.Work, columns []string) error {
+	work.ID = uint(id)
+	if len(columns) == 0 {
+		// The patch changes nothing: there is nothing to write, audit or invalidate
+		return config.GetDB().WithContext(ctx).First(work, id).Error
+	}
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous models.Work
+		if err := tx.First(&previous, id).Error; err != nil {
+			return err
+		}
+		if err := updateColumns(tx, work, columns); err != nil {
+			return err
+		}
+		if err := tx.First(work, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityWork, work.ID, &previous, work)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeyWorkPrefix+strconv.Itoa(id), cacheKeyWorksAll)
+
+	return nil
+}
+
+// DeleteWork deletes a work by its ID. Its editions are kept as standalone books.
+func DeleteWork(ctx context.Context, id int) error {
+	var bookIDs []uint
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var work models.Work
+		if err := tx.First(&work, id).Error; err != nil {
+			return err
+		}
+		var err error
+		bookIDs, err = updateAudited(ctx, tx, AuditEntityBook, func(b *models.Book) uint { return b.ID }, "work_id", nil, "work_id = ?", id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&work).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityWork, work.ID, &work, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeyWorkPrefix+strconv.Itoa(id), cacheKeyWorksAll)
+	invalidateBooks(ctx, bookIDs)
+
+	return nil
+}
+
+// MergeWorks moves every edition of the source work into the target work and deletes the source work.
+func MergeWorks(ctx context.Context, targetID, sourceID int) (*models.Work, error) {
+	if targetID == sourceID {
+		return nil, ErrSameWork
+	}
+
+	var target models.Work
+	var bookIDs []uint
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&target, targetID).Error; err != nil {
+			return err
+		}
+		var source models.Work
+		if err := tx.Preload("Editions").First(&source, sourceID).Error; err != nil {
+			return err
+		}
+		var before models.Work
+		if err := tx.Preload("Editions").First(&before, targetID).Error; err != nil {
+			return err
+		}
+		var err error
+		bookIDs, err = updateAudited(ctx, tx, AuditEntityBook, func(b *models.Book) uint { return b.ID }, "work_id", targetID, "work_id = ?", sourceID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Work{}, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Editions").First(&target, targetID).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditActionDelete, AuditEntityWork, source.ID, &source, nil); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityWork, target.ID, &before, &target)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateCache(ctx,
+		cacheKeyWorkPrefix+strconv.Itoa(targetID),
+		cacheKeyWorkPrefix+strconv.Itoa(sourceID),
+		cacheKeyWorksAll)
+	invalidateBooks(ctx, bookIDs)
+
+	return &target, nil
+}
+
+// SplitWork moves the given editions of a work into a newly created work.
+func SplitWork(ctx context.Context, id int, bookIDs []uint, work *models.Work) error {
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var source models.Work
+		if err := tx.Preload("Editions").First(&source, id).Error; err != nil {
+			return err
+		}
+
+		var editionIDs []uint
+		if err := tx.Model(&models.Book{}).
+			Where("work_id = ? AND id IN ?", id, bookIDs).
+			Pluck("id", &editionIDs).Error; err != nil {
+			return err
+		}
+		if len(editionIDs) == 0 || len(editionIDs) != len(bookIDs) {
+			return ErrNoEditions
+		}
+
+		// The new work inherits the source's metadata unless it is overridden
+		if work.Title == "" {
+			work.Title = source.Title
+		}
+		if work.Description == "" {
+			work.Description = source.Description
+		}
+		if work.OriginalLanguage == "" {
+			work.OriginalLanguage = source.OriginalLanguage
+		}
+		work.ID = 0
+		if err := tx.Omit("Editions").Create(work).Error; err != nil {
+			return err
+		}
+		if _, err := updateAudited(ctx, tx, AuditEntityBook, func(b *models.Book) uint { return b.ID }, "work_id", work.ID, "id IN ?", editionIDs); err != nil {
+			return err
+		}
+		if err := tx.Preload("Editions").First(work, work.ID).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityWork, work.ID, nil, work); err != nil {
+			return err
+		}
+
+		var after models.Work
+		if err := tx.Preload("Editions").First(&after, id).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionUpdate, AuditEntityWork, source.ID, &source, &after)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCache(ctx, cacheKeyWorkPrefix+strconv.Itoa(id), cacheKeyWorksAll)
+	invalidateBooks(ctx, bookIDs)
+
+	return nil
+}
+
+// invalidateBooks drops the cached copies of the given books, of the book list and of
+// the feeds.
+func invalidateBooks(ctx context.Context, ids []uint) {
+	keys := []string{cacheKeyBooksAll}
+	for _, id := range ids {
+		keys = append(keys, cacheKeyBookPrefix+strconv.FormatUint(uint64(id), 10))
+	}
+	invalidateCache(ctx, keys...)
+	invalidateFeeds(ctx)
+}
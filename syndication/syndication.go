@@ -0,0 +1,185 @@
+// Package syndication renders feeds that readers subscribe to, as Atom 1.0 or RSS 2.0.
+package syndication
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Media types of feeds.
+const (
+	TypeAtom = "application/atom+xml"
+	TypeRSS  = "application/rss+xml"
+)
+
+const (
+	namespaceAtom = "http://www.w3.org/2005/Atom"
+	namespaceDC   = "http://purl.org/dc/elements/1.1/"
+)
+
+// Feed describes a feed independently of its format. Links are absolute URLs.
+type Feed struct {
+	ID          string
+	Title       string
+	Description string
+	Link        string // Page the feed is about
+	Self        string // URL of the feed itself
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item is an entry of a feed.
+type Item struct {
+	ID        string
+	Title     string
+	Link      string
+	Summary   string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Author   atomPerson  `xml:"author"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Summary   string      `xml:"summary,omitempty"`
+	Link      atomLink    `xml:"link"`
+}
+
+// WriteAtom writes feed as an Atom 1.0 document.
+func WriteAtom(w io.Writer, feed Feed) error {
+	doc := atomFeed{
+		ID:       feed.ID,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Updated:  feed.Updated.UTC().Format(time.RFC3339),
+		Author:   atomPerson{Name: "Gin Books API"},
+		Links: []atomLink{
+			{Rel: "self", Href: feed.Self, Type: TypeAtom},
+			{Rel: "alternate", Href: feed.Link},
+		},
+	}
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Published: item.Published.UTC().Format(time.RFC3339),
+			Updated:   item.Updated.UTC().Format(time.RFC3339),
+			Summary:   item.Summary,
+			Link:      atomLink{Rel: "alternate", Href: item.Link},
+		}
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	XmlnsAtom string     `xml:"xmlns:atom,attr"`
+	XmlnsDC   string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          rssSelf   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description,omitempty"`
+	Author      string  `xml:"dc:creator,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+// WriteRSS writes feed as an RSS 2.0 document. RSS has no updated date for items, so
+// they carry their publication date only.
+func WriteRSS(w io.Writer, feed Feed) error {
+	description := feed.Description
+	if description == "" {
+		description = feed.Title
+	}
+	doc := rssDocument{
+		Version:   "2.0",
+		XmlnsAtom: namespaceAtom,
+		XmlnsDC:   namespaceDC,
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   description,
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+			Self:          rssSelf{Rel: "self", Href: feed.Self, Type: TypeRSS},
+		},
+	}
+	for _, item := range feed.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Summary,
+			Author:      item.Author,
+			GUID:        rssGUID{Value: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+// writeXML writes doc as an indented XML document.
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,88 @@
+// Package tracing configures the OpenTelemetry tracer of the API, and instruments what
+// the requests spend their time on: gorm queries, cache lookups and JSON encoding.
+// Spans of incoming requests are started by middleware.TraceID.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the service.name of the spans, unless OTEL_SERVICE_NAME sets another.
+const serviceName = "gin-books-api"
+
+// tracer starts the spans of the API.
+var tracer = otel.Tracer("gin-books-api")
+
+// Init installs the tracer provider and the W3C trace context propagator, and returns
+// a function flushing the spans not yet exported and stopping the provider.
+//
+// OTEL_TRACES_EXPORTER selects where spans are exported: "otlp" over HTTP, configured
+// by the OTEL_EXPORTER_OTLP_* variables; "stdout", one JSON document per span; or
+// "none", the default. Spans are recorded whatever the exporter, so that requests have
+// a trace ID in logs and error responses either way. OTEL_TRACES_SAMPLER may sample
+// out some of them.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	switch exporter := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); exporter {
+	case "", "none":
+	case "otlp":
+		client, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(client))
+	case "stdout":
+		client, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(client))
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q: must be otlp, stdout or none", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("Tracing failed", "error", err)
+	}))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name, a child of the span of ctx if it has one.
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, options...)
+}
+
+// End records err on span, if not nil, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
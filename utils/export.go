@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ExportFormat describes a download format of the exports.
+type ExportFormat struct {
+	ContentType string
+	Extension   string
+	Compress    bool // Whether gzip helps; XLSX is already a zip archive
+	NewWriter   func(w io.Writer) ExportWriter
+}
+
+// ExportFormats are the formats accepted by ?format= on export routes.
+var ExportFormats = map[string]ExportFormat{
+	"csv": {
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		Compress:    true,
+		NewWriter:   func(w io.Writer) ExportWriter { return &csvExportWriter{writer: csv.NewWriter(w)} },
+	},
+	"ndjson": {
+		ContentType: "application/x-ndjson",
+		Extension:   "ndjson",
+		Compress:    true,
+		NewWriter:   func(w io.Writer) ExportWriter { return &ndjsonExportWriter{encoder: json.NewEncoder(w)} },
+	},
+	"xlsx": {
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   "xlsx",
+		NewWriter:   func(w io.Writer) ExportWriter { return &xlsxExportWriter{out: w} },
+	},
+}
+
+// ExportWriter encodes the rows of an export as they are read.
+type ExportWriter interface {
+	Header(columns []string) error
+	Row(values []interface{}) error
+	// Close writes whatever the format keeps until the end.
+	Close() error
+}
+
+// exportValue converts a value read from the database into its plain form.
+func exportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return value
+}
+
+// spreadsheetValue returns value as a spreadsheet cell holds it: text starting with =, +,
+// - or @, or with a tab or carriage return, which spreadsheets may run as a formula, such
+// as a review reading =HYPERLINK(...), is prefixed with ' so that it is shown as text.
+func spreadsheetValue(value interface{}) interface{} {
+	text, ok := value.(string)
+	if ok && text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return value
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (w *csvExportWriter) Header(columns []string) error {
+	w.record = make([]string, len(columns))
+	return w.writer.Write(columns)
+}
+
+func (w *csvExportWriter) Row(values []interface{}) error {
+	for i, value := range values {
+		if value == nil {
+			w.record[i] = ""
+		} else {
+			w.record[i] = fmt.Sprint(spreadsheetValue(exportValue(value)))
+		}
+	}
+	return w.writer.Write(w.record)
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (w *ndjsonExportWriter) Header(columns []string) error {
+	w.columns = columns
+	return nil
+}
+
+func (w *ndjsonExportWriter) Row(values []interface{}) error {
+	object := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		object[w.columns[i]] = exportValue(value)
+	}
+	return w.encoder.Encode(object)
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// xlsxExportWriter writes a single sheet through excelize's stream writer, which keeps
+// large sheets in a temporary file rather than in memory.
+type xlsxExportWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func (w *xlsxExportWriter) Header(columns []string) error {
+	w.file = excelize.NewFile()
+	stream, err := w.file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return err
+	}
+	w.stream = stream
+
+	cells := make([]interface{}, len(columns))
+	for i, column := range columns {
+		cells[i] = column
+	}
+	return w.Row(cells)
+}
+
+func (w *xlsxExportWriter) Row(values []interface{}) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		cells[i] = spreadsheetValue(exportValue(value))
+	}
+	return w.stream.SetRow(cell, cells)
+}
+
+func (w *xlsxExportWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
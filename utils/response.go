@@ -1,15 +1,91 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gin-books-api/apperrors"
+	"gin-books-api/middleware"
+	"gin-books-api/tracing"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// JSONResponse sends a JSON response with the given status code and data.
+// ProblemContentType is the media type of error responses (RFC 7807).
+const ProblemContentType = "application/problem+json"
+
+// FieldError describes why one field of a request was rejected.
+type FieldError = apperrors.FieldError
+
+// Problem is an RFC 7807 problem details document, extended with a stable error
+// code, the request's trace ID and the rejected fields.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	TraceID  string       `json:"trace_id,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// JSONResponse sends a JSON response with the given status code and data. Encoding
+// data is traced as a span of its own, since large lists take a while to encode.
 func JSONResponse(c *gin.Context, statusCode int, data interface{}) {
-	c.JSON(statusCode, data)
+	_, span := tracing.Start(c.Request.Context(), "json.Marshal")
+	body, err := json.Marshal(data)
+	span.SetAttributes(attribute.Int("json.bytes", len(body)))
+	tracing.End(span, err)
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+	c.Data(statusCode, "application/json; charset=utf-8", body)
+}
+
+// ErrorResponse sends err as an application/problem+json response. Domain errors keep
+// their status, code and message; any other error is logged and reported as a 500
+// without exposing its text.
+func ErrorResponse(c *gin.Context, err error) {
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		appErr = apperrors.Internal(err)
+	}
+
+	status := appErr.Kind.Status()
+	traceID := c.GetString(middleware.TraceIDKey)
+	if status >= http.StatusInternalServerError && appErr.Err != nil {
+		slog.ErrorContext(c.Request.Context(), "Request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", appErr.Err)
+	}
+
+	c.Render(status, problemRender{Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   appErr.Message,
+		Instance: c.Request.URL.Path,
+		Code:     appErr.Code,
+		TraceID:  traceID,
+		Errors:   appErr.Details,
+	}})
+}
+
+// problemRender renders a Problem with the application/problem+json content type.
+type problemRender struct {
+	problem Problem
+}
+
+// Render implements render.Render.
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
 }
 
-// ErrorResponse sends an error response with the given status code and error message.
-func ErrorResponse(c *gin.Context, statusCode int, message string) {
-	c.JSON(statusCode, gin.H{"error": message})
+// WriteContentType implements render.Render.
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
 }
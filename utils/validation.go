@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationErrors converts the error returned when binding a request body into
+// field violations. It returns nil when err is not a validation or decoding error.
+func ValidationErrors(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			details = append(details, FieldError{
+				Field:   fieldPath(fieldErr.Namespace()),
+				Code:    fieldErr.Tag(),
+				Message: validationMessage(fieldErr),
+			})
+		}
+		return details
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}
+	}
+
+	return nil
+}
+
+// fieldPath drops the struct name from a validator namespace ("BookRequest.tags[0].name").
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// validationMessage explains a failed validation rule.
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "isbn":
+		return "must be a valid ISBN-10 or ISBN-13"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag such as en or pt-BR"
+	case "oneof":
+		return "must be one of: " + fieldErr.Param()
+	case "min", "gte":
+		if isLength(fieldErr) {
+			return fmt.Sprintf("must contain at least %s items or characters", fieldErr.Param())
+		}
+		return "must be at least " + fieldErr.Param()
+	case "max", "lte":
+		if isLength(fieldErr) {
+			return fmt.Sprintf("must contain at most %s items or characters", fieldErr.Param())
+		}
+		return "must be at most " + fieldErr.Param()
+	case "gt":
+		return "must be greater than " + fieldErr.Param()
+	case "unique":
+		return "must not contain duplicates"
+	default:
+		return fmt.Sprintf("failed the %s rule", fieldErr.Tag())
+	}
+}
+
+// isLength reports whether a min/max rule applies to a length rather than a value.
+func isLength(fieldErr validator.FieldError) bool {
+	switch fieldErr.Kind().String() {
+	case "string", "slice", "array", "map":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"gin-books-api/handlers"
+	"gin-books-api/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion is a version of the API. Every version serves the routes of
+// registerRoutes; a version whose responses differ from the previous one replaces the
+// handlers of the routes concerned and shares the others.
+type apiVersion struct {
+	name string
+
+	// Handlers replacing the shared ones, keyed by "METHOD /path" as registered
+	overrides map[string]gin.HandlerFunc
+
+	// Set once the version is deprecated in favour of the version under successor
+	deprecation time.Time
+	sunset      time.Time
+	successor   string
+}
+
+// prefix returns the path prefix of the version, e.g. /api/v1.
+func (v apiVersion) prefix() string {
+	return "/api/" + v.name
+}
+
+// apiVersions are the versions of the API, oldest first.
+var apiVersions = []apiVersion{
+	{name: "v1"},
+	{name: "v2", overrides: map[string]gin.HandlerFunc{
+		// The list of authors is paginated
+		"GET /authors": handlers.GetAuthorsPage,
+	}},
+}
+
+// The routes at the root, from before the API was versioned, are deprecated in favour
+// of v1 and will be removed after the sunset.
+var (
+	legacyDeprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset      = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// routeGroup registers routes on a version, using the handlers it overrides.
+type routeGroup struct {
+	*gin.RouterGroup
+	overrides map[string]gin.HandlerFunc
+}
+
+func (g routeGroup) handle(method, path string, handler gin.HandlerFunc) {
+	if override, ok := g.overrides[method+" "+path]; ok {
+		handler = override
+	}
+	g.RouterGroup.Handle(method, path, handler)
+}
+
+func (g routeGroup) GET(path string, handler gin.HandlerFunc) {
+	g.handle(http.MethodGet, path, handler)
+}
+
+func (g routeGroup) POST(path string, handler gin.HandlerFunc) {
+	g.handle(http.MethodPost, path, handler)
+}
+
+func (g routeGroup) PUT(path string, handler gin.HandlerFunc) {
+	g.handle(http.MethodPut, path, handler)
+}
+
+func (g routeGroup) PATCH(path string, handler gin.HandlerFunc) {
+	g.handle(http.MethodPatch, path, handler)
+}
+
+func (g routeGroup) DELETE(path string, handler gin.HandlerFunc) {
+	g.handle(http.MethodDelete, path, handler)
+}
+
+// apiRoutes lists the routes of r for the OpenAPI document, with the version serving
+// each. The legacy routes at the root are marked as aliases of v1.
+func apiRoutes(r *gin.Engine) []openapi.Route {
+	registered := r.Routes()
+	v1 := make(map[string]bool)
+	for _, route := range registered {
+		v1[route.Method+" "+route.Path] = strings.HasPrefix(route.Path, apiVersions[0].prefix()+"/")
+	}
+
+	routes := make([]openapi.Route, len(registered))
+	for i, route := range registered {
+		routes[i] = openapi.Route{Method: route.Method, Path: route.Path, Handler: route.Handler}
+		for _, version := range apiVersions {
+			if strings.HasPrefix(route.Path, version.prefix()+"/") {
+				routes[i].Version = version.prefix()
+				routes[i].Deprecated = !version.deprecation.IsZero()
+			}
+		}
+		routes[i].Alias = routes[i].Version == "" && v1[route.Method+" "+apiVersions[0].prefix()+route.Path]
+	}
+	return routes
+}